@@ -1,60 +1,1177 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io"
 	"loadtester/internal/client"
 	"loadtester/internal/config"
+	"loadtester/internal/data"
+	"loadtester/internal/errors"
+	"loadtester/internal/failures"
+	"loadtester/internal/har"
+	"loadtester/internal/mockserver"
+	"loadtester/internal/rawreq"
 	"loadtester/internal/runner"
+	"loadtester/internal/scenario"
+	"loadtester/internal/schema"
+	"loadtester/internal/stages"
 	"loadtester/internal/stats"
+	"loadtester/internal/tmpl"
+	"loadtester/internal/tracing"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http/httptest"
+	neturl "net/url"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
-func parseAndValidateFlags() (config.RequestConfig, int, int, bool, error) {
+// options holds everything parsed from the command line.
+type options struct {
+	config      config.RequestConfig
+	requests    int
+	concurrency int
+	stages      []config.Stage  // run these in order instead of a single test when set
+	compare     []compareTarget // test these side by side instead of -url when set
+	outputJSON  bool
+	compactJSON bool
+	statusLine  bool
+	rawTimesOut string
+	influxOut   string
+	seriesCSV   string
+	markdownOut string
+	webhook     string
+	userAgents  int // number of User-Agents picked from at random, if any
+
+	saveFailures       string
+	saveFailuresLimit  int
+	saveFailureHeaders bool
+	redactHeaders      []string
+	color              string
+
+	otelEndpoint   string
+	otelSampleRate float64
+	pprofAddr      string
+
+	verifyPercentiles bool // cross-check the reported percentiles against the raw samples
+
+	selfTest *mockserver.Options // run against an in-process server when set
+}
+
+// compareTarget is one of the targets of a side-by-side comparison.
+type compareTarget struct {
+	label string
+	url   string
+}
+
+func parseAndValidateFlags() (options, error) {
 	url := flag.String("url", "http://localhost:8080", "Target URL to test")
-	requests := flag.Int("requests", 100, "Total number of requests")
+	var headers stringList
+	flag.Var(&headers, "header", "Request header as \"Name: value\" (repeatable); values may use {{uuid}}, {{timestamp}} and {{env \"NAME\"}}")
+	host := flag.String("host", "", "Host header to send, overriding the URL's host (the connection still goes to the URL)")
+	method := flag.String("method", "GET", "HTTP method to use")
+	cacheBust := flag.String("cache-bust", "", "Query parameter to add with a unique value per request to bypass caches")
+	sizeHistogram := flag.Bool("size-histogram", false, "Report a histogram of response sizes")
+	cacheHeader := flag.String("cache-header", "", "Response header to tally cache outcomes from (e.g. X-Cache or Age)")
+	var compare stringList
+	flag.Var(&compare, "compare", "Target to test side by side with the others, as URL or label=URL (repeat for each target; replaces -url)")
+	scenarioFile := flag.String("scenario", "", "JSON file of steps each iteration runs in order, sharing cookies (replaces -url)")
+	harFile := flag.String("har", "", "HAR file whose recorded requests each iteration replays in order, sharing cookies (replaces -url)")
+	replayTiming := flag.Bool("replay-timing", false, "Send each -har request at its recorded offset from the first instead of back to back")
+	rawRequest := flag.String("raw-request", "", "File holding a raw HTTP request (request line, headers, blank line, body) to send instead of -method, -data and the URL's path")
+	body := flag.String("data", "", "Request body to send with every request")
+	contentType := flag.String("content-type", "", "Content-Type for request bodies (default: detect JSON, else text/plain; \"none\" to omit)")
+	compressRequest := flag.Bool("compress-request", false, "Gzip request bodies and send them with Content-Encoding: gzip")
+	bodySize := flag.String("body-size", "", "Send a generated body of random bytes of this size with every request (e.g. 512KB, 1MB)")
+	dataCSV := flag.String("data-csv", "", "CSV file whose rows, cycled across requests, fill in the -data body template's {{.column}} fields")
+	userAgents := flag.String("user-agents", "", "File of User-Agent strings, one per line, to pick from at random for each request")
+	dataLines := flag.String("data-lines", "", "File of request bodies (one per line, or a JSON array) cycled across requests")
+	requests := countFlag("requests", 100, "Total number of requests (accepts k and m suffixes, e.g. 500k)")
 	concurrency := flag.Int("concurrency", 10, "Number of concurrent workers")
-	expectedCode := flag.Int("status", 200, "Expected HTTP status code")
-	expectedBody := flag.String("body", "", "Expected response body content")
+	users := flag.Int("users", 0, "Number of virtual users (workers); same as -concurrency")
+	connections := flag.Int("connections", 0, "Maximum simultaneous connections per host shared by all users (0 for one per user)")
+	connectRate := flag.Float64("connect-rate", 0, "Open at most this many new TCP connections per second (0 is unlimited)")
+	dialRetries := flag.Int("dial-retries", 0, "Retry a failed TCP connect this many times before reporting a connection error")
+	maxIdleConns := flag.Int("max-idle-conns", 0, "Idle keep-alive connections to keep per host (0 matches -concurrency)")
+	connPerWorker := flag.Bool("conn-per-worker", false, "Pin each worker to a dedicated connection, so the server sees exactly -concurrency connections")
+	duration := flag.Duration("duration", 0, "Run for this long instead of a fixed number of requests (e.g. 2h)")
+	rateJitter := flag.String("rate-jitter", "", "Randomize the gaps between paced requests: a fraction such as 0.2 (±20%), or \"poisson\" for Poisson arrivals")
+	seed := flag.Uint64("seed", 0, "Seed for random choices such as -rate-jitter, to repeat a run exactly (default: random, printed)")
+	warmupDuration := flag.Duration("warmup-duration", 0, "With -duration, discard results completed in this first part of the run (e.g. 10s)")
+	stagesFile := flag.String("stages", "", "JSON file of stages (concurrency, rate, duration) to run one after another")
+	targetSuccesses := flag.Int("target-successes", 0, "Keep sending until this many requests have succeeded, ignoring failures (-requests is ignored)")
+	maxRPS := flag.Float64("max-rps", 0, "Hard ceiling on requests sent per second across all workers, whatever the concurrency (0 for none)")
+	untilErrors := flag.Int("until-errors", 0, "Keep sending until this many requests have failed, e.g. to capture an intermittent failure with -save-failures (-requests is ignored)")
+	maxDuration := flag.Duration("max-duration", 0, "Hard cap on total test time; stops the run and reports partial results")
+	drainTimeout := flag.Duration("drain-timeout", 0, "When the run is stopped, wait up to this long for in-flight requests before abandoning them (0 aborts them at once)")
+	reportInterval := flag.Duration("report-interval", 0, "Print an interim summary at this interval while the test runs (e.g. 5m)")
+	noProgress := flag.Bool("no-progress", false, "Don't print progress lines while the test runs (also set by LOADTESTER_NO_PROGRESS or CI)")
+	expectedCode := flag.Int("status", 0, "Expected HTTP status code (default: 201 for POST, 204 for DELETE, 200 otherwise)")
+	allowRedirects := flag.Bool("allow-redirects", false, "Count 3xx responses as successes without following them")
+	var expectedBodies stringList
+	flag.Var(&expectedBodies, "body", "Expected response body content (repeatable; any match succeeds)")
+	bodyFile := flag.String("body-file", "", "File whose content the response body must contain, for large expected payloads")
+	bodyNotContains := flag.String("body-not-contains", "", "Text that must not appear in the response body")
+	jsonSchema := flag.String("json-schema", "", "JSON Schema file every response body must validate against")
+	minResponseSize := byteSizeFlag("min-response-size", 0, "Minimum response body size in bytes, e.g. 512 or 2KB (0 disables)")
+	maxLatency := flag.Duration("max-latency", 0, "Fail requests slower than this as Slow Response errors (e.g. 500ms; 0 disables)")
 	timeout := flag.Int("timeout", 5, "Request timeout in seconds")
+	retries := flag.Int("retries", 0, "Retry a failed request up to this many times")
+	retryOn := flag.String("retry-on", "dns,connection,timeout,network", "Comma-separated error codes to retry (e.g. dns,timeout,server_error)")
+	retryBackoff := flag.Duration("retry-backoff", 100*time.Millisecond, "Base of the jittered exponential backoff between retries")
+	adaptiveTimeout := flag.String("adaptive-timeout", "", "After warm-up, time requests out at this multiple of the warm-up P99 (e.g. 3x)")
+	adaptiveWarmup := flag.Int("adaptive-warmup", 100, "Successful responses to observe before applying -adaptive-timeout")
+	saveFailures := flag.String("save-failures", "", "Directory to write a dump of each failed request to")
+	saveFailuresLimit := flag.Int("save-failures-limit", 100, "Maximum number of failure dumps to write")
+	saveFailureHeaders := flag.Bool("save-failure-headers", false, "Include request and response headers in failure dumps")
+	redactHeaders := flag.String("redact-headers", strings.Join(failures.DefaultRedact, ","), "Comma-separated headers whose values are redacted in failure dumps")
+	rawTimesOut := flag.String("raw-times-out", "", "Write every response time to this file (nanoseconds, one per line)")
+	influxOut := flag.String("influx-out", "", "Write the summary and per-second series to this file in InfluxDB line protocol")
+	seriesCSV := flag.String("timeseries-csv", "", "Write per-second requests, errors, RPS and rolling P95 to this CSV file")
+	webhook := flag.String("webhook", "", "POST the JSON summary to this URL when the run finishes, e.g. a Slack or Teams incoming webhook")
+	verifyPercentiles := flag.Bool("verify-percentiles", false, "Debug: cross-check the reported percentiles against the raw samples and warn if they diverge")
+	markdownOut := flag.String("md-out", "", "Write the summary, status codes and errors as Markdown tables to this file")
 	outputJSON := flag.Bool("json", false, "Output results in JSON format")
+	statusLine := flag.Bool("status-line", false, "Print a final RESULT key=value line to stderr for wrapper scripts, whatever the output format")
+	compactJSON := flag.Bool("json-compact", false, "Output the key metrics as a single line of JSON, for appending to logs")
+	excludeFirst := flag.Bool("exclude-first", false, "Leave the first request's cold-start latency out of the latency stats")
+	latencyTarget := flag.Duration("latency-target", 0, "Report the share of requests succeeding at or under this latency (e.g. 100ms)")
+	apdexTarget := flag.Duration("apdex-target", 0, "Apdex target time T used to compute the Apdex score (e.g. 200ms)")
+	maxBodySize := byteSizeFlag("max-body-size", config.DefaultMaxBodySize, "Maximum response body bytes to read, e.g. 10MB (0 for unlimited)")
+	discardBody := flag.Bool("discard-body", false, "Count response bytes without buffering the body (disables body validation)")
+	stream := flag.Bool("stream", false, "Treat responses as streams: read only the first -stream-bytes, then close (disables body validation)")
+	streamBytes := byteSizeFlag("stream-bytes", 1, "Bytes to read from each response with -stream, e.g. 64KB")
+	certExpiryWarn := flag.Duration("cert-expiry-warn", 30*24*time.Hour, "Warn when the server's TLS certificate expires within this window (0 disables)")
+	dnsServer := flag.String("dns-server", "", "DNS server (host:port) to resolve the target through")
+	dnsRoundRobin := flag.Int("dns-round-robin", 0, "Resolve each host this many times up front and open connections to its addresses in turn")
+	clientCert := flag.String("client-cert", "", "PEM client certificate for mutual TLS (requires -client-key)")
+	clientKey := flag.String("client-key", "", "PEM private key for -client-cert")
+	caCert := flag.String("ca-cert", "", "PEM file of root CAs to verify the server against instead of the system pool")
+	caCertAppend := flag.Bool("ca-cert-append", false, "Trust the -ca-cert CAs in addition to the system pool rather than instead of it")
+	tlsMinVersion := flag.String("tls-min-version", "", "Minimum TLS version to offer: 1.0, 1.1, 1.2 or 1.3")
+	tlsCiphers := flag.String("tls-ciphers", "", "Comma-separated cipher suites to offer for TLS 1.2 and below (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)")
+	selfTest := flag.Bool("self-test", false, "Run against a built-in echo server instead of -url")
+	selfTestDelay := flag.Duration("self-test-delay", 10*time.Millisecond, "Response delay of the -self-test server")
+	selfTestErrorRate := flag.Float64("self-test-error-rate", 0, "Fraction of -self-test requests answered with a 500")
+	color := flag.String("color", "auto", "Color the text report: auto, always or never")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/HTTP collector (host:port) to export request traces to")
+	otelSampleRate := flag.Float64("otel-sample-rate", 0.01, "Fraction of requests to trace when -otel-endpoint is set")
+	pprofAddr := flag.String("pprof-addr", "", "Serve net/http/pprof endpoints on this address during the run (e.g. localhost:6060)")
 
 	flag.Parse()
 
 	// Validation
+	if err := validateURL(*url); err != nil {
+		return options{}, err
+	}
+	if *webhook != "" {
+		if err := validateURL(*webhook); err != nil {
+			return options{}, fmt.Errorf("webhook: %w", err)
+		}
+	}
 	if *requests < 1 {
-		return config.RequestConfig{}, 0, 0, false, fmt.Errorf("requests must be >= 1, got %d", *requests)
+		return options{}, fmt.Errorf("requests must be >= 1, got %d", *requests)
+	}
+	if *users < 0 {
+		return options{}, fmt.Errorf("users must be >= 0, got %d", *users)
+	}
+	if *users > 0 {
+		if flagSet("concurrency") && *concurrency != *users {
+			return options{}, fmt.Errorf("users and concurrency are the same setting, got %d and %d", *users, *concurrency)
+		}
+		*concurrency = *users
+	}
+	if *connectRate < 0 {
+		return options{}, fmt.Errorf("connect-rate must be >= 0, got %v", *connectRate)
+	}
+	if *dialRetries < 0 {
+		return options{}, fmt.Errorf("dial-retries must be >= 0, got %d", *dialRetries)
+	}
+	if *connections < 0 {
+		return options{}, fmt.Errorf("connections must be >= 0, got %d", *connections)
 	}
 	if *concurrency < 1 {
-		return config.RequestConfig{}, 0, 0, false, fmt.Errorf("concurrency must be >= 1, got %d", *concurrency)
+		return options{}, fmt.Errorf("concurrency must be >= 1, got %d", *concurrency)
+	}
+	if *selfTestErrorRate < 0 || *selfTestErrorRate > 1 {
+		return options{}, fmt.Errorf("self-test-error-rate must be in [0, 1], got %v", *selfTestErrorRate)
+	}
+	if *saveFailuresLimit < 1 {
+		return options{}, fmt.Errorf("save-failures-limit must be >= 1, got %d", *saveFailuresLimit)
+	}
+	if *maxIdleConns < 0 {
+		return options{}, fmt.Errorf("max-idle-conns must be >= 0, got %d", *maxIdleConns)
+	}
+	if *connPerWorker && (*connections > 0 || *maxIdleConns > 0) {
+		return options{}, fmt.Errorf("conn-per-worker cannot be combined with connections or max-idle-conns")
+	}
+	if *maxLatency < 0 {
+		return options{}, fmt.Errorf("max-latency must be >= 0, got %v", *maxLatency)
 	}
 	if *timeout < 1 {
-		return config.RequestConfig{}, 0, 0, false, fmt.Errorf("timeout must be >= 1, got %d", *timeout)
+		return options{}, fmt.Errorf("timeout must be >= 1, got %d", *timeout)
+	}
+	if *retries < 0 {
+		return options{}, fmt.Errorf("retries must be >= 0, got %d", *retries)
+	}
+	if *retryBackoff < 0 {
+		return options{}, fmt.Errorf("retry-backoff must be >= 0, got %v", *retryBackoff)
+	}
+	retryTypes, err := parseErrorCodes(*retryOn)
+	if err != nil {
+		return options{}, fmt.Errorf("retry-on: %w", err)
+	}
+	adaptiveMultiplier, err := parseMultiplier(*adaptiveTimeout)
+	if err != nil {
+		return options{}, fmt.Errorf("adaptive-timeout must be a multiplier >= 1 like 3x, got %q", *adaptiveTimeout)
+	}
+	if *adaptiveWarmup < 1 {
+		return options{}, fmt.Errorf("adaptive-warmup must be >= 1, got %d", *adaptiveWarmup)
+	}
+	if *duration < 0 {
+		return options{}, fmt.Errorf("duration must be >= 0, got %v", *duration)
+	}
+	if *warmupDuration < 0 {
+		return options{}, fmt.Errorf("warmup-duration must be >= 0, got %v", *warmupDuration)
+	}
+	if *warmupDuration > 0 && *duration == 0 {
+		return options{}, fmt.Errorf("warmup-duration requires duration")
+	}
+	if *warmupDuration > 0 && *warmupDuration >= *duration {
+		return options{}, fmt.Errorf("warmup-duration must be shorter than duration (%v)", *duration)
+	}
+	if *targetSuccesses < 0 {
+		return options{}, fmt.Errorf("target-successes must be >= 0, got %d", *targetSuccesses)
+	}
+	if *maxRPS < 0 {
+		return options{}, fmt.Errorf("max-rps must be >= 0, got %v", *maxRPS)
+	}
+	if *untilErrors < 0 {
+		return options{}, fmt.Errorf("until-errors must be >= 0, got %d", *untilErrors)
+	}
+	if *untilErrors > 0 && *targetSuccesses > 0 {
+		return options{}, fmt.Errorf("until-errors cannot be combined with target-successes")
+	}
+	if *maxDuration < 0 {
+		return options{}, fmt.Errorf("max-duration must be >= 0, got %v", *maxDuration)
+	}
+	if *drainTimeout < 0 {
+		return options{}, fmt.Errorf("drain-timeout must be >= 0, got %v", *drainTimeout)
+	}
+	if *reportInterval < 0 {
+		return options{}, fmt.Errorf("report-interval must be >= 0, got %v", *reportInterval)
+	}
+	if *latencyTarget < 0 {
+		return options{}, fmt.Errorf("latency-target must be >= 0, got %v", *latencyTarget)
+	}
+	if *apdexTarget < 0 {
+		return options{}, fmt.Errorf("apdex-target must be >= 0, got %v", *apdexTarget)
+	}
+	if *minResponseSize < 0 {
+		return options{}, fmt.Errorf("min-response-size must be >= 0, got %d", *minResponseSize)
+	}
+	if *maxBodySize < 0 {
+		return options{}, fmt.Errorf("max-body-size must be >= 0, got %d", *maxBodySize)
+	}
+	if *bodyFile != "" {
+		content, err := os.ReadFile(*bodyFile)
+		if err != nil {
+			return options{}, fmt.Errorf("reading body-file: %w", err)
+		}
+		if len(content) == 0 {
+			return options{}, fmt.Errorf("body-file %s is empty", *bodyFile)
+		}
+		expectedBodies = append(expectedBodies, string(content))
+	}
+	if *discardBody && (len(expectedBodies) > 0 || *bodyNotContains != "" || *jsonSchema != "") {
+		return options{}, fmt.Errorf("discard-body cannot be combined with body validation")
+	}
+	if *streamBytes < 1 {
+		return options{}, fmt.Errorf("stream-bytes must be >= 1, got %d", *streamBytes)
+	}
+	if *stream && (len(expectedBodies) > 0 || *bodyNotContains != "" || *jsonSchema != "") {
+		return options{}, fmt.Errorf("stream cannot be combined with body validation")
+	}
+	var streamLimit int64
+	if *stream {
+		streamLimit = *streamBytes
+	}
+	if *color != "auto" && *color != "always" && *color != "never" {
+		return options{}, fmt.Errorf("color must be auto, always or never, got %q", *color)
+	}
+	if *outputJSON && *compactJSON {
+		return options{}, fmt.Errorf("json cannot be combined with json-compact")
+	}
+	if *otelSampleRate <= 0 || *otelSampleRate > 1 {
+		return options{}, fmt.Errorf("otel-sample-rate must be in (0, 1], got %v", *otelSampleRate)
+	}
+	if *dnsRoundRobin < 0 {
+		return options{}, fmt.Errorf("dns-round-robin must be >= 0, got %d", *dnsRoundRobin)
+	}
+	if *dnsServer != "" {
+		if _, _, err := net.SplitHostPort(*dnsServer); err != nil {
+			return options{}, fmt.Errorf("dns-server must be host:port, got %q", *dnsServer)
+		}
+	}
+
+	tlsConfig, err := loadTLSConfig(tlsOptions{
+		certFile:   *clientCert,
+		keyFile:    *clientKey,
+		caFile:     *caCert,
+		caAppend:   *caCertAppend,
+		minVersion: *tlsMinVersion,
+		ciphers:    *tlsCiphers,
+	})
+	if err != nil {
+		return options{}, err
+	}
+
+	// -duration alone runs open-ended; with an explicit -requests the total
+	// is paced across the duration instead
+	numRequests := *requests
+	if *duration > 0 && !flagSet("requests") {
+		numRequests = 0
+	}
+
+	var jitter float64
+	poisson := false
+	if *rateJitter != "" {
+		if !(*duration > 0 && flagSet("requests")) && *stagesFile == "" {
+			return options{}, fmt.Errorf("rate-jitter requires a paced run: -duration with -requests, or stages")
+		}
+		if strings.EqualFold(*rateJitter, "poisson") {
+			poisson = true
+		} else if jitter, err = strconv.ParseFloat(*rateJitter, 64); err != nil || jitter < 0 || jitter > 1 {
+			return options{}, fmt.Errorf("rate-jitter must be a fraction between 0 and 1 or \"poisson\", got %q", *rateJitter)
+		}
+	}
+	runSeed := *seed
+	if !flagSet("seed") {
+		runSeed = rand.Uint64()
 	}
 
 	cfg := config.RequestConfig{
-		URL:            *url,
-		ExpectedStatus: *expectedCode,
-		ExpectedBody:   *expectedBody,
-		Timeout:        time.Duration(*timeout) * time.Second,
+		URL:             *url,
+		Method:          strings.ToUpper(*method),
+		Host:            *host,
+		Body:            *body,
+		ContentType:     *contentType,
+		CompressRequest: *compressRequest,
+		ExpectedStatus:  *expectedCode,
+		BodyNotContains: *bodyNotContains,
+		AllowRedirects:  *allowRedirects,
+		MinResponseSize: *minResponseSize,
+		MaxLatency:      *maxLatency,
+		Timeout:         time.Duration(*timeout) * time.Second,
+		Retries:         *retries,
+		RetryOn:         retryTypes,
+		RetryBackoff:    *retryBackoff,
+		Concurrency:     *concurrency,
+		MaxIdleConns:    *maxIdleConns,
+		ConnPerWorker:   *connPerWorker,
+		MaxConns:        *connections,
+		DialRetries:     *dialRetries,
+		ConnectRate:     *connectRate,
+		AdaptiveTimeout: adaptiveMultiplier,
+		AdaptiveWarmup:  *adaptiveWarmup,
+		Duration:        *duration,
+		WarmupDuration:  *warmupDuration,
+		RateJitter:      jitter,
+		PoissonArrivals: poisson,
+		Seed:            runSeed,
+		ReportInterval:  *reportInterval,
+		NoProgress:      *noProgress,
+		MaxDuration:     *maxDuration,
+		DrainTimeout:    *drainTimeout,
+		TargetSuccesses: *targetSuccesses,
+		UntilErrors:     *untilErrors,
+		MaxRPS:          *maxRPS,
+		DNSServer:       *dnsServer,
+		DNSRoundRobin:   *dnsRoundRobin,
+		TLS:             tlsConfig,
+		ExcludeFirst:    *excludeFirst,
+		LatencyTarget:   *latencyTarget,
+		ApdexTarget:     *apdexTarget,
+		CertExpiryWarn:  *certExpiryWarn,
+		MaxBodySize:     *maxBodySize,
+		DiscardBody:     *discardBody,
+		StreamBytes:     streamLimit,
+		CacheBustParam:  *cacheBust,
+		CacheHeader:     *cacheHeader,
+		SizeHistogram:   *sizeHistogram,
+	}
+	if *jsonSchema != "" {
+		validator, err := schema.Load(*jsonSchema)
+		if err != nil {
+			return options{}, err
+		}
+		cfg.BodyValidator = validator
+	}
+	for _, h := range headers {
+		header, err := parseHeader(h)
+		if err != nil {
+			return options{}, err
+		}
+		cfg.Headers = append(cfg.Headers, header)
+	}
+	userAgentCount := 0
+	if *userAgents != "" {
+		if slices.ContainsFunc(cfg.Headers, func(h config.Header) bool { return strings.EqualFold(h.Name, "User-Agent") }) {
+			return options{}, fmt.Errorf("user-agents cannot be combined with a User-Agent header")
+		}
+		picker, err := data.OpenPicker(*userAgents, runSeed)
+		if err != nil {
+			return options{}, err
+		}
+		cfg.Headers = append(cfg.Headers, config.Header{Name: "User-Agent", Value: picker.Pick})
+		userAgentCount = picker.Len()
+	}
+	if *rawRequest != "" {
+		if flagSet("method") || *body != "" || *dataLines != "" || *bodySize != "" || *scenarioFile != "" || *harFile != "" || len(compare) > 0 {
+			return options{}, fmt.Errorf("raw-request cannot be combined with method, data, data-lines, body-size, scenario, har or compare")
+		}
+		base := ""
+		if flagSet("url") {
+			base = *url
+		}
+		req, err := rawreq.Load(*rawRequest, base)
+		if err != nil {
+			return options{}, err
+		}
+		if err := validateURL(req.URL); err != nil {
+			return options{}, fmt.Errorf("raw-request: %w", err)
+		}
+		cfg.URL, cfg.Method, cfg.Body = req.URL, req.Method, req.Body
+		if cfg.Host == "" {
+			cfg.Host = req.Host
+		}
+		// -header flags take precedence over the file's headers
+		for _, h := range req.Headers {
+			if !slices.ContainsFunc(cfg.Headers, func(flagged config.Header) bool { return strings.EqualFold(flagged.Name, h.Name) }) {
+				cfg.Headers = append(cfg.Headers, h)
+			}
+		}
+	}
+	if len(expectedBodies) > 0 {
+		cfg.ExpectedBody, cfg.ExpectedBodies = expectedBodies[0], expectedBodies[1:]
+	}
+	if *body != "" && *dataLines != "" {
+		return options{}, fmt.Errorf("data cannot be combined with data-lines")
+	}
+	if *bodySize != "" {
+		size, err := parseByteSize(*bodySize)
+		if err != nil || size < 1 {
+			return options{}, fmt.Errorf("body-size must be a size like 512KB or 1MB, got %q", *bodySize)
+		}
+		if *body != "" || *dataLines != "" || *scenarioFile != "" || *harFile != "" {
+			return options{}, fmt.Errorf("body-size cannot be combined with data, data-lines, scenario or har")
+		}
+		cfg.BodyFunc = data.RandomBody(size)
+		if cfg.ContentType == "" {
+			cfg.ContentType = "application/octet-stream"
+		}
+	}
+	if *scenarioFile != "" {
+		if *dataLines != "" {
+			return options{}, fmt.Errorf("scenario cannot be combined with data-lines")
+		}
+		sc, err := scenario.Load(*scenarioFile)
+		if err != nil {
+			return options{}, err
+		}
+		for _, step := range sc.Steps {
+			if err := validateURL(step.URL); err != nil {
+				return options{}, fmt.Errorf("scenario step %q: %w", step.Name, err)
+			}
+		}
+		cfg.Steps = sc.Steps
+	}
+	if *harFile != "" {
+		if *scenarioFile != "" || *dataLines != "" {
+			return options{}, fmt.Errorf("har cannot be combined with scenario or data-lines")
+		}
+		steps, err := har.Load(*harFile)
+		if err != nil {
+			return options{}, err
+		}
+		for _, step := range steps {
+			if err := validateURL(step.URL); err != nil {
+				return options{}, fmt.Errorf("har entry %q: %w", step.Name, err)
+			}
+		}
+		cfg.Steps = steps
+		cfg.ReplayTiming = *replayTiming
+	} else if *replayTiming {
+		return options{}, fmt.Errorf("replay-timing requires har")
 	}
-	return cfg, *requests, *concurrency, *outputJSON, nil
+	if *dataLines != "" {
+		source, err := data.Open(*dataLines)
+		if err != nil {
+			return options{}, err
+		}
+		cfg.BodySource = source
+	}
+	if *dataCSV != "" {
+		if *body == "" {
+			return options{}, fmt.Errorf("data-csv requires data as the body template")
+		}
+		if *dataLines != "" || *scenarioFile != "" || *harFile != "" {
+			return options{}, fmt.Errorf("data-csv cannot be combined with data-lines, scenario or har")
+		}
+		source, err := data.OpenCSV(*dataCSV, *body)
+		if err != nil {
+			return options{}, err
+		}
+		cfg.BodySource = source
+	}
+	var runStages []config.Stage
+	if *stagesFile != "" {
+		if *duration > 0 || *targetSuccesses > 0 || *untilErrors > 0 || flagSet("requests") {
+			return options{}, fmt.Errorf("stages cannot be combined with duration, target-successes, until-errors or requests")
+		}
+		runStages, err = stages.Load(*stagesFile)
+		if err != nil {
+			return options{}, err
+		}
+		// Size the shared connection pool for the busiest stage
+		for _, stage := range runStages {
+			cfg.Concurrency = max(cfg.Concurrency, stage.Concurrency)
+		}
+	}
+	var targets []compareTarget
+	if len(compare) > 0 {
+		if len(compare) < 2 {
+			return options{}, fmt.Errorf("compare needs at least 2 targets, got %d", len(compare))
+		}
+		if flagSet("url") || *scenarioFile != "" || *harFile != "" || *stagesFile != "" || *selfTest {
+			return options{}, fmt.Errorf("compare cannot be combined with url, scenario, har, stages or self-test")
+		}
+		if *compactJSON || *rawTimesOut != "" || *influxOut != "" || *seriesCSV != "" || *markdownOut != "" || *webhook != "" || *verifyPercentiles {
+			return options{}, fmt.Errorf("compare cannot be combined with json-compact, raw-times-out, influx-out, timeseries-csv, md-out, webhook or verify-percentiles")
+		}
+		seen := make(map[string]bool)
+		for _, c := range compare {
+			target := parseCompareTarget(c)
+			if err := validateURL(target.url); err != nil {
+				return options{}, fmt.Errorf("compare: %w", err)
+			}
+			if seen[target.label] {
+				return options{}, fmt.Errorf("compare label %q is used more than once", target.label)
+			}
+			seen[target.label] = true
+			targets = append(targets, target)
+		}
+	}
+	var selfTestOpts *mockserver.Options
+	if *selfTest {
+		selfTestOpts = &mockserver.Options{Delay: *selfTestDelay, ErrorRate: *selfTestErrorRate}
+	}
+	return options{
+		selfTest:    selfTestOpts,
+		config:      cfg.WithDefaults(),
+		requests:    numRequests,
+		concurrency: *concurrency,
+		stages:      runStages,
+		compare:     targets,
+		outputJSON:  *outputJSON,
+		compactJSON: *compactJSON,
+		statusLine:  *statusLine,
+		rawTimesOut: *rawTimesOut,
+		influxOut:   *influxOut,
+		seriesCSV:   *seriesCSV,
+		markdownOut: *markdownOut,
+		webhook:     *webhook,
+		userAgents:  userAgentCount,
+
+		saveFailures:       *saveFailures,
+		saveFailuresLimit:  *saveFailuresLimit,
+		saveFailureHeaders: *saveFailureHeaders,
+		redactHeaders:      splitList(*redactHeaders),
+		color:              *color,
+
+		otelEndpoint:   *otelEndpoint,
+		otelSampleRate: *otelSampleRate,
+		pprofAddr:      *pprofAddr,
+
+		verifyPercentiles: *verifyPercentiles,
+	}, nil
 }
 
-func main() {
-	cfg, requests, concurrency, outputJSON, err := parseAndValidateFlags()
+// supportedSchemes are the URL schemes requests can be sent to.
+var supportedSchemes = []string{"http", "https"}
+
+// validateURL fails fast on URLs every request would fail on, such as a
+// typo'd or non-HTTP scheme.
+func validateURL(raw string) error {
+	u, err := neturl.Parse(raw)
 	if err != nil {
+		return fmt.Errorf("invalid url %q: %v", raw, err)
+	}
+	if !slices.Contains(supportedSchemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("url scheme must be one of %s, got %q in %q", strings.Join(supportedSchemes, ", "), u.Scheme, raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("url %q has no host", raw)
+	}
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ", ") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCompareTarget parses a -compare value, either "label=URL" or a bare
+// URL, which is then its own label.
+func parseCompareTarget(s string) compareTarget {
+	// A URL's query may contain "=", but never before its scheme
+	if label, url, ok := strings.Cut(s, "="); ok && label != "" && !strings.ContainsAny(label, ":/?") {
+		return compareTarget{label: label, url: url}
+	}
+	return compareTarget{label: s, url: s}
+}
+
+// parseHeader parses a "Name: value" header flag, compiling any placeholders
+// in the value.
+func parseHeader(s string) (config.Header, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" || strings.ContainsAny(name, " \t") {
+		return config.Header{}, fmt.Errorf("header must be \"Name: value\", got %q", s)
+	}
+	if strings.EqualFold(name, "Host") {
+		return config.Header{}, fmt.Errorf("use -host to override the Host header")
+	}
+	t, err := tmpl.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return config.Header{}, fmt.Errorf("header %s: %w", name, err)
+	}
+	return config.Header{Name: name, Value: t.String}, nil
+}
+
+// parseErrorCodes parses a comma-separated list of error codes such as
+// "dns,timeout".
+func parseErrorCodes(s string) ([]errors.ErrorType, error) {
+	var types []errors.ErrorType
+	for _, code := range splitList(s) {
+		errorType, ok := errors.ParseCode(code)
+		if !ok {
+			return nil, fmt.Errorf("unknown error code %q", code)
+		}
+		types = append(types, errorType)
+	}
+	return types, nil
+}
+
+// unit is a number suffix and the multiple it stands for.
+type unit struct {
+	suffix string
+	size   int64
+}
+
+// byteUnits are the size suffixes accepted by parseByteSize, in binary
+// multiples to match the MB figures in the report. Longer suffixes come
+// first so "KB" isn't taken for "B".
+var byteUnits = []unit{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"B", 1},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+}
+
+// countUnits are the suffixes accepted by parseCount.
+var countUnits = []unit{
+	{"K", 1_000},
+	{"M", 1_000_000},
+}
+
+// parseSuffixed parses a whole number with an optional suffix from units,
+// case-insensitively, e.g. "2m" or "512 KB".
+func parseSuffixed(s string, units []unit) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range units {
+		if strings.HasSuffix(s, unit.suffix) {
+			s, multiplier = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix)), unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n > math.MaxInt64/multiplier || n < math.MinInt64/multiplier {
+		return 0, strconv.ErrRange
+	}
+	return n * multiplier, nil
+}
+
+// parseByteSize parses sizes like "1MB", "512KB", "2k" or a plain number of
+// bytes.
+func parseByteSize(s string) (int64, error) {
+	n, err := parseSuffixed(s, byteUnits)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n, nil
+}
+
+// parseCount parses counts like "500k", "2m" or a plain number, where k is a
+// thousand and m a million.
+func parseCount(s string) (int, error) {
+	n, err := parseSuffixed(s, countUnits)
+	if err != nil || n != int64(int(n)) {
+		return 0, fmt.Errorf("invalid count %q (use a whole number, optionally with a k or m suffix)", s)
+	}
+	return int(n), nil
+}
+
+// countValue is an int flag that accepts the suffixes of parseCount.
+type countValue int
+
+func (v *countValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *countValue) Set(s string) error {
+	n, err := parseCount(s)
+	*v = countValue(n)
+	return err
+}
+
+// countFlag is flag.Int for counts written like "500k".
+func countFlag(name string, value int, usage string) *int {
+	p := &value
+	flag.Var((*countValue)(p), name, usage)
+	return p
+}
+
+// byteSizeValue is an int64 flag that accepts the suffixes of
+// parseByteSize. Negative sizes parse, so they get the range errors of
+// parseAndValidateFlags.
+type byteSizeValue int64
+
+func (v *byteSizeValue) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+func (v *byteSizeValue) Set(s string) error {
+	n, err := parseSuffixed(s, byteUnits)
+	if err != nil {
+		return fmt.Errorf("invalid size %q (use a number of bytes, optionally with a KB, MB or GB suffix)", s)
+	}
+	*v = byteSizeValue(n)
+	return nil
+}
+
+// byteSizeFlag is flag.Int64 for sizes written like "10MB".
+func byteSizeFlag(name string, value int64, usage string) *int64 {
+	p := &value
+	flag.Var((*byteSizeValue)(p), name, usage)
+	return p
+}
+
+// parseMultiplier parses values like "3x" or "2.5"; empty means disabled.
+func parseMultiplier(s string) (float64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	m, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "x"), 64)
+	if err != nil || m < 1 {
+		return 0, fmt.Errorf("invalid multiplier %q", s)
+	}
+	return m, nil
+}
+
+// tlsOptions are the TLS-related flags.
+type tlsOptions struct {
+	certFile, keyFile, caFile string
+	caAppend                  bool // add caFile to the system pool instead of replacing it
+	minVersion                string
+	ciphers                   string
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// loadTLSConfig builds the client TLS settings from the TLS flags. It
+// returns nil when none are set.
+func loadTLSConfig(opts tlsOptions) (*tls.Config, error) {
+	if opts == (tlsOptions{}) {
+		return nil, nil
+	}
+	certFile, keyFile, caFile := opts.certFile, opts.keyFile, opts.caFile
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("client-cert and client-key must be given together")
+	}
+	if opts.caAppend && caFile == "" {
+		return nil, fmt.Errorf("ca-cert-append needs ca-cert")
+	}
+	cfg := &tls.Config{}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca-cert: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if opts.caAppend {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				return nil, fmt.Errorf("loading system roots for ca-cert-append: %w", err)
+			}
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca-cert %s contains no PEM certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if opts.minVersion != "" {
+		version, ok := tlsVersions[opts.minVersion]
+		if !ok {
+			return nil, fmt.Errorf("tls-min-version must be 1.0, 1.1, 1.2 or 1.3, got %q", opts.minVersion)
+		}
+		cfg.MinVersion = version
+	}
+	if opts.ciphers != "" {
+		suites, err := parseCipherSuites(opts.ciphers)
+		if err != nil {
+			return nil, err
+		}
+		cfg.CipherSuites = suites
+	}
+	return cfg, nil
+}
+
+// parseCipherSuites resolves comma-separated cipher suite names, including
+// insecure ones since probing how a server handles them is a valid test.
+func parseCipherSuites(s string) ([]uint16, error) {
+	ids := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		ids[suite.Name] = suite.ID
+	}
+	var suites []uint16
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := ids[name]
+		if !ok {
+			return nil, fmt.Errorf("tls-ciphers: unknown cipher suite %q", name)
+		}
+		suites = append(suites, id)
+	}
+	return suites, nil
+}
+
+// writeRawTimes saves the full response time dataset to path.
+func writeRawTimes(path string, s stats.LoadTestStats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writing raw times: %w", err)
+	}
+	if err := stats.WriteResponseTimes(f, s); err != nil {
+		f.Close()
+		return fmt.Errorf("writing raw times: %w", err)
+	}
+	return f.Close()
+}
+
+// writeInflux saves s to path in InfluxDB line protocol, tagged with the
+// target of cfg. A scenario has no single target, so its points are untagged.
+func writeInflux(path string, s stats.LoadTestStats, cfg config.RequestConfig, end time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writing influx output: %w", err)
+	}
+	var tags stats.InfluxTags
+	if len(cfg.Steps) == 0 {
+		tags = stats.InfluxTags{URL: cfg.URL, Method: cfg.Method}
+	}
+	if err := stats.WriteInfluxLines(f, s, tags, end); err != nil {
+		f.Close()
+		return fmt.Errorf("writing influx output: %w", err)
+	}
+	return f.Close()
+}
+
+// writeTimeSeriesCSV saves the per-second time series of s to path.
+func writeTimeSeriesCSV(path string, s stats.LoadTestStats, end time.Time) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writing time series: %w", err)
+	}
+	if err := stats.WriteTimeSeriesCSV(f, s, end); err != nil {
+		f.Close()
+		return fmt.Errorf("writing time series: %w", err)
+	}
+	return f.Close()
+}
+
+// writeMarkdown saves the Markdown report of s to path.
+func writeMarkdown(path string, s stats.LoadTestStats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("writing markdown report: %w", err)
+	}
+	if err := stats.WriteMarkdown(f, s); err != nil {
+		f.Close()
+		return fmt.Errorf("writing markdown report: %w", err)
+	}
+	return f.Close()
+}
+
+// useColor resolves the -color mode; auto colors only when stdout is a
+// terminal and NO_COLOR is unset.
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// interruptContext returns a context cancelled by the first interrupt or
+// SIGTERM, so the run stops, drains and reports. Notification stops after
+// that first signal, so a second one kills the process as usual.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			cancel(fmt.Errorf("received %v", sig))
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel(nil)
+	}
+}
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		if err := serve(os.Args[2:]); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := run(); err != nil {
 		// Print error and exit with non-zero code
 		fmt.Println("Error:", err)
 		os.Exit(1)
 	}
+}
+
+// run runs the load test described by the command line. Errors are returned
+// rather than exiting, so the deferred cleanup, such as flushing traces and
+// closing files, always runs.
+func run() error {
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		return err
+	}
+	cfg := opts.config
+	if opts.selfTest != nil {
+		server := httptest.NewServer(mockserver.Handler(*opts.selfTest))
+		defer server.Close()
+		cfg.URL = server.URL
+		fmt.Printf("Self-test: echo server with %v delay and %.0f%% injected errors\n", opts.selfTest.Delay, opts.selfTest.ErrorRate*100)
+	}
+	if source, ok := cfg.BodySource.(io.Closer); ok {
+		defer source.Close()
+	}
+	if opts.userAgents > 0 {
+		fmt.Printf("User-Agents: picked at random from %d (seed %d)\n", opts.userAgents, cfg.Seed)
+	}
+	if cfg.MaxBodySize == 0 && !cfg.DiscardBody {
+		fmt.Printf("Warning: -max-body-size 0 buffers whole responses in memory; with %d concurrent workers this can use a lot of memory (consider -discard-body)\n", opts.concurrency)
+	}
+
+	if opts.otelEndpoint != "" {
+		shutdown, err := tracing.Setup(context.Background(), opts.otelEndpoint, opts.otelSampleRate)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			// Flush sampled spans before exiting
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				fmt.Println("Warning: exporting traces failed:", err)
+			}
+		}()
+	}
 
-	results_stats := runner.RunLoadTest(cfg, requests, concurrency, client.MakeRequest)
+	if opts.pprofAddr != "" {
+		addr, stop, err := startPprof(opts.pprofAddr)
+		if err != nil {
+			return fmt.Errorf("pprof: %w", err)
+		}
+		defer stop()
+		fmt.Printf("Profiling: http://%s/debug/pprof/\n", addr)
+	}
+
+	var saver *failures.Saver
+	if opts.saveFailures != "" {
+		saver, err = failures.NewSaver(opts.saveFailures, opts.saveFailuresLimit, opts.saveFailureHeaders, opts.redactHeaders)
+		if err != nil {
+			return err
+		}
+	}
+	// newRequester builds the request function for cfg on a client of its
+	// own, so runs made side by side never share connections
+	newRequester := func(cfg config.RequestConfig) func(config.RequestConfig) client.TestResult {
+		makeRequest := client.NewClient(cfg).MakeRequest
+		if cfg.Retries > 0 {
+			makeRequest = client.WithRetries(makeRequest)
+		}
+		if saver != nil {
+			// Outermost, so only the final attempt of a retried request is saved
+			makeRequest = saver.Wrap(makeRequest)
+		}
+		return makeRequest
+	}
 
-	if outputJSON {
+	if len(opts.compare) > 0 {
+		labels := make([]string, len(opts.compare))
+		configs := make([]config.RequestConfig, len(opts.compare))
+		requesters := make([]func(config.RequestConfig) client.TestResult, len(opts.compare))
+		for i, target := range opts.compare {
+			labels[i] = target.label
+			configs[i] = cfg
+			configs[i].URL = target.url
+			requesters[i] = newRequester(configs[i])
+		}
+		ctx, stop := interruptContext()
+		defer stop()
+		results := runner.RunCompare(ctx, configs, opts.requests, opts.concurrency, requesters)
+		if opts.outputJSON {
+			stats.PrintJSONComparison(labels, results)
+		} else {
+			stats.PrintComparison(labels, results, stats.PrintOptions{Color: useColor(opts.color)})
+		}
+		return nil
+	}
+
+	makeRequest := newRequester(cfg)
+	ctx, stop := interruptContext()
+	defer stop()
+	var results_stats stats.LoadTestStats
+	if len(opts.stages) > 0 {
+		results_stats = runner.RunStages(ctx, cfg, opts.stages, opts.concurrency, makeRequest)
+	} else {
+		results_stats = runner.RunLoadTestContext(ctx, cfg, opts.requests, opts.concurrency, makeRequest)
+	}
+	end := time.Now()
+
+	if opts.rawTimesOut != "" {
+		if err := writeRawTimes(opts.rawTimesOut, results_stats); err != nil {
+			return err
+		}
+	}
+	if opts.influxOut != "" {
+		if err := writeInflux(opts.influxOut, results_stats, cfg, end); err != nil {
+			return err
+		}
+	}
+
+	if opts.seriesCSV != "" {
+		if err := writeTimeSeriesCSV(opts.seriesCSV, results_stats, end); err != nil {
+			return err
+		}
+	}
+
+	if opts.markdownOut != "" {
+		if err := writeMarkdown(opts.markdownOut, results_stats); err != nil {
+			return err
+		}
+	}
+
+	if opts.verifyPercentiles {
+		// On stderr, so JSON output stays parseable
+		mismatches := stats.VerifyPercentiles(results_stats)
+		for _, mismatch := range mismatches {
+			fmt.Fprintln(os.Stderr, "Warning: percentile mismatch:", mismatch)
+		}
+		if len(mismatches) == 0 {
+			fmt.Fprintf(os.Stderr, "Percentiles verified against %d samples\n", len(results_stats.ResponseTimes))
+		}
+	}
+
+	if opts.outputJSON {
 		stats.PrintJSONStats(results_stats)
+	} else if opts.compactJSON {
+		if err := stats.WriteCompactJSON(os.Stdout, results_stats); err != nil {
+			return err
+		}
 	} else {
-		stats.PrintDetailedStats(results_stats)
+		stats.PrintDetailedStats(results_stats, stats.PrintOptions{Color: useColor(opts.color)})
+	}
+	if opts.statusLine {
+		fmt.Fprintln(os.Stderr, stats.StatusLine(results_stats))
+	}
+	if opts.webhook != "" {
+		// The run's result stands whether or not the notification got through
+		if err := postWebhook(opts.webhook, results_stats); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: webhook failed:", err)
+		}
 	}
+	return nil
 }
@@ -1,8 +1,21 @@
 package main
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
 	"flag"
+	"io"
+	"loadtester/internal/config"
+	"loadtester/internal/stats"
+	"math/big"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -16,10 +29,11 @@ func TestParseAndValidateFlags_Defaults(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd"}
 
-	cfg, requests, concurrency, outputJSON, err := parseAndValidateFlags()
+	opts, err := parseAndValidateFlags()
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
+	cfg, requests, concurrency, outputJSON := opts.config, opts.requests, opts.concurrency, opts.outputJSON
 	if cfg.URL != "http://localhost:8080" || requests != 100 || concurrency != 10 || cfg.ExpectedStatus != 200 || cfg.ExpectedBody != "" || cfg.Timeout != 5*time.Second || outputJSON != false {
 		t.Errorf("Default flag values not parsed correctly: %+v, %d, %d, %v", cfg, requests, concurrency, outputJSON)
 	}
@@ -29,10 +43,11 @@ func TestParseAndValidateFlags_CustomValues(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd", "-url=http://test", "-requests=42", "-concurrency=7", "-status=404", "-body=hello", "-timeout=2", "-json=true"}
 
-	cfg, requests, concurrency, outputJSON, err := parseAndValidateFlags()
+	opts, err := parseAndValidateFlags()
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
+	cfg, requests, concurrency, outputJSON := opts.config, opts.requests, opts.concurrency, opts.outputJSON
 	if cfg.URL != "http://test" || requests != 42 || concurrency != 7 || cfg.ExpectedStatus != 404 || cfg.ExpectedBody != "hello" || cfg.Timeout != 2*time.Second || outputJSON != true {
 		t.Errorf("Custom flag values not parsed correctly: %+v, %d, %d, %v", cfg, requests, concurrency, outputJSON)
 	}
@@ -42,7 +57,7 @@ func TestParseAndValidateFlags_NegativeConcurrency(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd", "-concurrency=-1"}
 
-	_, _, _, _, err := parseAndValidateFlags()
+	_, err := parseAndValidateFlags()
 	if err == nil || err.Error() != "concurrency must be >= 1, got -1" {
 		t.Errorf("Expected error for negative concurrency, got: %v", err)
 	}
@@ -52,7 +67,7 @@ func TestParseAndValidateFlags_ZeroRequests(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd", "-requests=0"}
 
-	_, _, _, _, err := parseAndValidateFlags()
+	_, err := parseAndValidateFlags()
 	if err == nil || err.Error() != "requests must be >= 1, got 0" {
 		t.Errorf("Expected error for zero requests, got: %v", err)
 	}
@@ -62,7 +77,7 @@ func TestParseAndValidateFlags_TimeoutNegative(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd", "-timeout=-5"}
 
-	_, _, _, _, err := parseAndValidateFlags()
+	_, err := parseAndValidateFlags()
 	if err == nil || err.Error() != "timeout must be >= 1, got -5" {
 		t.Errorf("Expected error for negative timeout, got: %v", err)
 	}
@@ -72,11 +87,1129 @@ func TestParseAndValidateFlags_ConfigConstruction(t *testing.T) {
 	resetFlags()
 	os.Args = []string{"cmd", "-url=http://test", "-status=201", "-body=abc", "-timeout=3"}
 
-	cfg, _, _, _, err := parseAndValidateFlags()
+	opts, err := parseAndValidateFlags()
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
+	cfg := opts.config
 	if cfg.URL != "http://test" || cfg.ExpectedStatus != 201 || cfg.ExpectedBody != "abc" || cfg.Timeout != 3*time.Second {
 		t.Errorf("Config not constructed correctly: %+v", cfg)
 	}
 }
+
+func TestParseAndValidateFlags_DNSServer(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-dns-server=8.8.8.8:53"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.DNSServer != "8.8.8.8:53" {
+		t.Errorf("Expected DNS server 8.8.8.8:53, got %q", cfg.DNSServer)
+	}
+}
+
+func TestParseAndValidateFlags_DNSServerMissingPort(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-dns-server=8.8.8.8"}
+
+	_, err := parseAndValidateFlags()
+	if err == nil || err.Error() != `dns-server must be host:port, got "8.8.8.8"` {
+		t.Errorf("Expected error for DNS server without port, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_DNSRoundRobin(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-dns-round-robin=3"}
+	if opts, err := parseAndValidateFlags(); err != nil || opts.config.DNSRoundRobin != 3 {
+		t.Errorf("Expected 3 lookups per host, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-dns-round-robin=-1"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "dns-round-robin must be >= 0, got -1" {
+		t.Errorf("Expected error for negative lookups, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_LatencyTarget(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-latency-target=100ms"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.LatencyTarget != 100*time.Millisecond {
+		t.Errorf("Expected latency target 100ms, got %v", cfg.LatencyTarget)
+	}
+}
+
+func TestParseAndValidateFlags_DataLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bodies.txt")
+	if err := os.WriteFile(path, []byte("first\nsecond\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write data file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-method=post", "-data-lines=" + path}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.Method != "POST" {
+		t.Errorf("Expected method POST, got %q", cfg.Method)
+	}
+	if cfg.BodySource == nil {
+		t.Fatal("Expected a body source to be configured")
+	}
+	if body, _ := cfg.BodySource.Next(); body != "first" {
+		t.Errorf("Expected first body %q, got %q", "first", body)
+	}
+}
+
+func TestParseAndValidateFlags_DataLinesMissing(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-data-lines=" + filepath.Join(t.TempDir(), "missing")}
+
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for missing data file")
+	}
+}
+
+func TestParseAndValidateFlags_MaxBodySize(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.MaxBodySize != config.DefaultMaxBodySize {
+		t.Errorf("Expected default max body size %d, got %d", config.DefaultMaxBodySize, cfg.MaxBodySize)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-max-body-size=-1"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "max-body-size must be >= 0, got -1" {
+		t.Errorf("Expected error for negative max body size, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_DiscardWithBody(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-discard-body", "-body=OK"}
+
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error when combining -discard-body with -body")
+	}
+}
+
+func TestParseAndValidateFlags_Color(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-color=always"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !useColor(opts.color) {
+		t.Error("Expected -color=always to enable color")
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-color=sometimes"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != `color must be auto, always or never, got "sometimes"` {
+		t.Errorf("Expected error for invalid color mode, got: %v", err)
+	}
+}
+
+func TestUseColor_Never(t *testing.T) {
+	if useColor("never") {
+		t.Error("Expected -color=never to disable color")
+	}
+}
+
+func TestParseAndValidateFlags_BodyNotContains(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-body-not-contains=error"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.BodyNotContains != "error" {
+		t.Errorf("Expected forbidden body text %q, got %q", "error", opts.config.BodyNotContains)
+	}
+}
+
+func TestParseAndValidateFlags_OtelSampleRate(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-otel-endpoint=localhost:4318", "-otel-sample-rate=0.5"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.otelEndpoint != "localhost:4318" || opts.otelSampleRate != 0.5 {
+		t.Errorf("OTel options not parsed correctly: %q, %v", opts.otelEndpoint, opts.otelSampleRate)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-otel-sample-rate=0"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "otel-sample-rate must be in (0, 1], got 0" {
+		t.Errorf("Expected error for zero sample rate, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_DurationAndReportInterval(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=2h", "-report-interval=5m"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.Duration != 2*time.Hour || opts.config.ReportInterval != 5*time.Minute {
+		t.Errorf("Duration flags not parsed correctly: %v, %v", opts.config.Duration, opts.config.ReportInterval)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=-1s"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "duration must be >= 0, got -1s" {
+		t.Errorf("Expected error for negative duration, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_WarmupDuration(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=1m", "-warmup-duration=10s"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.WarmupDuration != 10*time.Second {
+		t.Errorf("Expected a 10s warm-up, got %v", opts.config.WarmupDuration)
+	}
+
+	for _, args := range [][]string{
+		{"-warmup-duration=10s"},
+		{"-duration=10s", "-warmup-duration=10s"},
+		{"-duration=1m", "-warmup-duration=-1s"},
+	} {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_RateJitter(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=1m", "-requests=600", "-rate-jitter=0.2", "-seed=42"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.RateJitter != 0.2 || opts.config.PoissonArrivals || opts.config.Seed != 42 {
+		t.Errorf("Expected ±20%% jitter with seed 42, got %+v", opts.config)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=1m", "-requests=600", "-rate-jitter=Poisson"}
+	if opts, err := parseAndValidateFlags(); err != nil || !opts.config.PoissonArrivals {
+		t.Errorf("Expected Poisson arrivals, got %v", err)
+	}
+
+	for _, args := range [][]string{
+		{"-rate-jitter=0.2"},
+		{"-duration=1m", "-rate-jitter=0.2"},
+		{"-duration=1m", "-requests=600", "-rate-jitter=1.5"},
+		{"-duration=1m", "-requests=600", "-rate-jitter=lots"},
+	} {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_Webhook(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-webhook=https://hooks.example.com/T000/B000"}
+	if opts, err := parseAndValidateFlags(); err != nil || opts.webhook != "https://hooks.example.com/T000/B000" {
+		t.Errorf("Expected the webhook URL to be set, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-webhook=hooks.example.com"}
+	if _, err := parseAndValidateFlags(); err == nil || !strings.HasPrefix(err.Error(), "webhook: ") {
+		t.Errorf("Expected an invalid webhook URL error, got %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_MinResponseSize(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-min-response-size=512"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.MinResponseSize != 512 {
+		t.Errorf("Expected min response size 512, got %d", opts.config.MinResponseSize)
+	}
+}
+
+func TestParseAndValidateFlags_AdaptiveTimeout(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-adaptive-timeout=3x", "-adaptive-warmup=50"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.AdaptiveTimeout != 3 || opts.config.AdaptiveWarmup != 50 {
+		t.Errorf("Adaptive timeout not parsed correctly: %v, %d", opts.config.AdaptiveTimeout, opts.config.AdaptiveWarmup)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-adaptive-timeout=0.5x"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for adaptive timeout multiplier below 1")
+	}
+}
+
+func TestParseAndValidateFlags_RepeatedBody(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-body=variant A", "-body=variant B"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bodies := opts.config.AcceptedBodies()
+	if len(bodies) != 2 || bodies[0] != "variant A" || bodies[1] != "variant B" {
+		t.Errorf("Expected both bodies to be accepted, got %v", bodies)
+	}
+}
+
+// writeCertPair writes a self-signed certificate and its key to dir.
+func writeCertPair(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestParseAndValidateFlags_ClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertPair(t, dir)
+
+	resetFlags()
+	os.Args = []string{"cmd", "-client-cert=" + certFile, "-client-key=" + keyFile, "-ca-cert=" + certFile}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tlsConfig := opts.config.TLS
+	if tlsConfig == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.RootCAs == nil {
+		t.Errorf("Expected a client certificate and root pool, got %+v", tlsConfig)
+	}
+}
+
+func TestParseAndValidateFlags_CACertAppend(t *testing.T) {
+	certFile, _ := writeCertPair(t, t.TempDir())
+
+	resetFlags()
+	os.Args = []string{"cmd", "-ca-cert=" + certFile}
+	only, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resetFlags()
+	os.Args = []string{"cmd", "-ca-cert=" + certFile, "-ca-cert-append"}
+	appended, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-ca-cert-append"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for -ca-cert-append without -ca-cert")
+	}
+
+	system, err := x509.SystemCertPool()
+	if err != nil || system.Equal(x509.NewCertPool()) {
+		t.Skip("No system roots to append to")
+	}
+	if appended.config.TLS.RootCAs.Equal(only.config.TLS.RootCAs) {
+		t.Error("Expected the appended pool to hold the system roots as well")
+	}
+}
+
+func TestParseAndValidateFlags_ClientCertificateInvalid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCertPair(t, dir)
+	missing := filepath.Join(dir, "missing.pem")
+
+	cases := [][]string{
+		{"-client-cert=" + certFile},
+		{"-client-key=" + keyFile},
+		{"-client-cert=" + missing, "-client-key=" + keyFile},
+		{"-client-cert=" + keyFile, "-client-key=" + keyFile},
+		{"-ca-cert=" + missing},
+		{"-ca-cert=" + keyFile},
+	}
+	for _, args := range cases {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd"}
+	if opts, _ := parseAndValidateFlags(); opts.config.TLS != nil {
+		t.Error("Expected no TLS config when no certificate flags are set")
+	}
+}
+
+func TestParseAndValidateFlags_PacedRequests(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=1m"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.requests != 0 {
+		t.Errorf("Expected -duration alone to be open-ended, got %d requests", opts.requests)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-duration=1m", "-requests=10000"}
+	opts, err = parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.requests != 10000 || opts.config.Duration != time.Minute {
+		t.Errorf("Expected 10000 requests paced over 1m, got %d over %v", opts.requests, opts.config.Duration)
+	}
+}
+
+func TestParseAndValidateFlags_Scenario(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(`{"steps": [{"name": "home", "url": "http://test/"}]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write scenario file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-scenario=" + path}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(opts.config.Steps) != 1 || opts.config.Steps[0].Name != "home" {
+		t.Errorf("Expected scenario steps to be loaded, got %+v", opts.config.Steps)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-scenario=" + path, "-data-lines=" + path}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -scenario with -data-lines")
+	}
+}
+
+func TestParseAndValidateFlags_HAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	content := `{"log": {"entries": [{"request": {"method": "GET", "url": "http://test/", "headers": []}, "response": {"status": 200}}]}}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write HAR file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-har=" + path}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(opts.config.Steps) != 1 || opts.config.Steps[0].URL != "http://test/" {
+		t.Errorf("Expected HAR entries to be loaded as steps, got %+v", opts.config.Steps)
+	}
+	if opts.config.ReplayTiming {
+		t.Error("Expected recorded timing to be ignored without -replay-timing")
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-har=" + path, "-replay-timing"}
+	if opts, err := parseAndValidateFlags(); err != nil || !opts.config.ReplayTiming {
+		t.Errorf("Expected -replay-timing to be set, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-har=" + path, "-scenario=" + path}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -har with -scenario")
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-replay-timing"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for -replay-timing without -har")
+	}
+}
+
+func TestWriteRawTimes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "times.txt")
+	if err := writeRawTimes(path, stats.LoadTestStats{ResponseTimes: []time.Duration{time.Millisecond}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "1000000\n" {
+		t.Errorf("Expected one time per line, got %q", content)
+	}
+
+	if err := writeRawTimes(filepath.Join(t.TempDir(), "missing", "times.txt"), stats.LoadTestStats{}); err == nil {
+		t.Error("Expected error for an unwritable path")
+	}
+}
+
+func TestWriteTimeSeriesCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "series.csv")
+	s := stats.LoadTestStats{TestDuration: time.Second, TimeSeries: []stats.Window{{Requests: 3}}}
+	if err := writeTimeSeriesCSV(path, s, time.Unix(1, 0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); strings.Count(string(content), "\n") != 2 {
+		t.Errorf("Expected a header and one row, got %q", content)
+	}
+
+	if err := writeTimeSeriesCSV(filepath.Join(t.TempDir(), "missing", "series.csv"), s, time.Now()); err == nil {
+		t.Error("Expected error for an unwritable path")
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.md")
+	if err := writeMarkdown(path, stats.LoadTestStats{TotalRequests: 1, SuccessfulReqs: 1}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); !strings.Contains(string(content), "| Total requests | 1 |") {
+		t.Errorf("Expected a Markdown summary table, got %q", content)
+	}
+
+	if err := writeMarkdown(filepath.Join(t.TempDir(), "missing", "report.md"), stats.LoadTestStats{}); err == nil {
+		t.Error("Expected error for an unwritable path")
+	}
+}
+
+func TestParseAndValidateFlags_DataAndContentType(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-method=POST", "-data={\"id\":1}", "-content-type=application/vnd.api+json"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.Body != `{"id":1}` || opts.config.ContentType != "application/vnd.api+json" {
+		t.Errorf("Body flags not parsed correctly: %q, %q", opts.config.Body, opts.config.ContentType)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-data=x", "-data-lines=bodies.txt"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -data with -data-lines")
+	}
+}
+
+func TestParseAndValidateFlags_MaxIdleConns(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-concurrency=20", "-max-idle-conns=5"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.Concurrency != 20 || opts.config.MaxIdleConns != 5 {
+		t.Errorf("Connection flags not parsed correctly: %d, %d", opts.config.Concurrency, opts.config.MaxIdleConns)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-max-idle-conns=-1"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "max-idle-conns must be >= 0, got -1" {
+		t.Errorf("Expected error for negative max-idle-conns, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_Retries(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-retries=2", "-retry-on=timeout, server_error", "-retry-backoff=50ms"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.Retries != 2 || cfg.RetryBackoff != 50*time.Millisecond || len(cfg.RetryOn) != 2 || cfg.RetryOn[1] != "Server Error" {
+		t.Errorf("Retry flags not parsed correctly: %d, %v, %v", cfg.Retries, cfg.RetryBackoff, cfg.RetryOn)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-retry-on=timeout,bogus"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != `retry-on: unknown error code "bogus"` {
+		t.Errorf("Expected error for unknown retry code, got: %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_TLSVersionAndCiphers(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-tls-min-version=1.2", "-tls-ciphers=TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_RSA_WITH_RC4_128_SHA"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	tlsConfig := opts.config.TLS
+	if tlsConfig == nil || tlsConfig.MinVersion != tls.VersionTLS12 || len(tlsConfig.CipherSuites) != 2 {
+		t.Fatalf("TLS flags not parsed correctly: %+v", tlsConfig)
+	}
+
+	for _, arg := range []string{"-tls-min-version=1.4", "-tls-ciphers=TLS_MADE_UP"} {
+		resetFlags()
+		os.Args = []string{"cmd", arg}
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("Expected error for %s", arg)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_URLScheme(t *testing.T) {
+	cases := map[string]string{
+		"ftp://files.test/a":  `url scheme must be one of http, https, got "ftp" in "ftp://files.test/a"`,
+		"htp://typo.test":     `url scheme must be one of http, https, got "htp" in "htp://typo.test"`,
+		"localhost:8080/path": `url scheme must be one of http, https, got "localhost" in "localhost:8080/path"`,
+		"http://":             `url "http://" has no host`,
+	}
+	for rawURL, want := range cases {
+		resetFlags()
+		os.Args = []string{"cmd", "-url=" + rawURL}
+		if _, err := parseAndValidateFlags(); err == nil || err.Error() != want {
+			t.Errorf("%s: expected error %q, got %v", rawURL, want, err)
+		}
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-url=HTTPS://example.test"}
+	if _, err := parseAndValidateFlags(); err != nil {
+		t.Errorf("Expected an uppercase https scheme to be accepted, got %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_ScenarioStepScheme(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(`{"steps": [{"name": "upload", "url": "ftp://files.test/"}]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write scenario file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-scenario=" + path}
+	if _, err := parseAndValidateFlags(); err == nil || !strings.HasPrefix(err.Error(), `scenario step "upload": url scheme`) {
+		t.Errorf("Expected scheme error naming the step, got %v", err)
+	}
+}
+
+func TestParseAndValidateFlags_UsersAndConnections(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-users=100", "-connections=10"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.concurrency != 100 || opts.config.Concurrency != 100 || opts.config.MaxConns != 10 {
+		t.Errorf("Expected 100 users sharing 10 connections, got %d users and %d connections", opts.concurrency, opts.config.MaxConns)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-users=100", "-concurrency=5"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for conflicting -users and -concurrency")
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-connections=-1"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "connections must be >= 0, got -1" {
+		t.Errorf("Expected error for negative connections, got: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-conn-per-worker"}
+	if opts, err := parseAndValidateFlags(); err != nil || !opts.config.ConnPerWorker {
+		t.Errorf("Expected -conn-per-worker to be set, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-conn-per-worker", "-connections=2"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -conn-per-worker with -connections")
+	}
+}
+
+func TestParseAndValidateFlags_SelfTest(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-self-test", "-self-test-delay=5ms", "-self-test-error-rate=0.25"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.selfTest == nil || opts.selfTest.Delay != 5*time.Millisecond || opts.selfTest.ErrorRate != 0.25 {
+		t.Errorf("Self-test options not parsed correctly: %+v", opts.selfTest)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-self-test-error-rate=2"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for an error rate above 1")
+	}
+}
+
+func TestParseAndValidateFlags_BodyFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "expected.json")
+	if err := os.WriteFile(path, []byte(`{"items": [1, 2, 3]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write body file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-body-file=" + path}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.ExpectedBody != `{"items": [1, 2, 3]}` {
+		t.Errorf("Expected body loaded from file, got %q", opts.config.ExpectedBody)
+	}
+
+	empty := filepath.Join(dir, "empty.json")
+	os.WriteFile(empty, nil, 0o644)
+	for _, arg := range []string{"-body-file=" + empty, "-body-file=" + filepath.Join(dir, "missing.json")} {
+		resetFlags()
+		os.Args = []string{"cmd", arg}
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("Expected error for %s", arg)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_DialRetries(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-dial-retries=3"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.DialRetries != 3 {
+		t.Errorf("Expected 3 dial retries, got %d", opts.config.DialRetries)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-dial-retries=-1"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for negative dial-retries")
+	}
+}
+
+func TestParseAndValidateFlags_Stream(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-stream", "-stream-bytes=16"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.StreamBytes != 16 {
+		t.Errorf("Expected StreamBytes 16, got %d", opts.config.StreamBytes)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-stream", "-body=OK"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error when combining -stream with -body")
+	}
+}
+
+func TestParseAndValidateFlags_DefaultStatusByMethod(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-method=DELETE"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.ExpectedStatus != 204 {
+		t.Errorf("Expected DELETE to default to status 204, got %d", opts.config.ExpectedStatus)
+	}
+}
+
+func TestParseAndValidateFlags_JSONCompact(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-json-compact"}
+
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !opts.compactJSON || opts.outputJSON {
+		t.Errorf("Expected only compact JSON output, got compact=%v json=%v", opts.compactJSON, opts.outputJSON)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-json", "-json-compact"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -json with -json-compact")
+	}
+}
+
+func TestParseAndValidateFlags_Stages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stages.json")
+	content := `{"stages": [{"concurrency": 5, "duration": "1s"}, {"concurrency": 40, "duration": "1s"}]}`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write stages file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-stages=" + path}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(opts.stages) != 2 || opts.config.Concurrency != 40 {
+		t.Errorf("Expected 2 stages with the pool sized for 40 workers, got %+v and %d", opts.stages, opts.config.Concurrency)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-stages=" + path, "-duration=10s"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -stages with -duration")
+	}
+}
+
+func TestParseAndValidateFlags_Compare(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-compare=v1=http://v1.test/", "-compare=http://v2.test/?a=b"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []compareTarget{{"v1", "http://v1.test/"}, {"http://v2.test/?a=b", "http://v2.test/?a=b"}}
+	if !slices.Equal(opts.compare, want) {
+		t.Errorf("Expected targets %+v, got %+v", want, opts.compare)
+	}
+
+	invalid := [][]string{
+		{"-compare=http://v1.test/"},
+		{"-compare=a=http://v1.test/", "-compare=a=http://v2.test/"},
+		{"-compare=http://v1.test/", "-compare=ftp://v2.test/"},
+		{"-compare=http://v1.test/", "-compare=http://v2.test/", "-url=http://v3.test/"},
+		{"-compare=http://v1.test/", "-compare=http://v2.test/", "-json-compact"},
+	}
+	for _, args := range invalid {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_RawRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "request.http")
+	content := "PUT /items/1 HTTP/1.1\r\nHost: api.test\r\nAccept: text/plain\r\nX-Env: file\r\n\r\nhello"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write request file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-raw-request=" + path, "-url=http://127.0.0.1:9000", "-header=X-Env: flag"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cfg := opts.config
+	if cfg.Method != "PUT" || cfg.URL != "http://127.0.0.1:9000/items/1" || cfg.Host != "api.test" || cfg.Body != "hello" {
+		t.Errorf("Expected the file's request sent to -url with its Host, got %s %s (host %q, body %q)", cfg.Method, cfg.URL, cfg.Host, cfg.Body)
+	}
+	if len(cfg.Headers) != 2 || cfg.Headers[0].Value() != "flag" || cfg.Headers[1].Name != "Accept" {
+		t.Errorf("Expected -header to win over the file's X-Env, got %+v", cfg.Headers)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-raw-request=" + path, "-data=x"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -raw-request with -data")
+	}
+}
+
+func TestParseAndValidateFlags_JSONSchema(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "object", "required": ["id"]}`), 0o644); err != nil {
+		t.Fatalf("Failed to write schema file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-json-schema=" + path}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.BodyValidator == nil || opts.config.BodyValidator.Validate(`{"id": 1}`) != nil {
+		t.Errorf("Expected a compiled schema validator, got %v", opts.config.BodyValidator)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-json-schema=" + path, "-discard-body"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -json-schema with -discard-body")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	t.Setenv("LOADTESTER_TOKEN", "s3cret")
+	h, err := parseHeader(`Authorization:  Bearer {{env "LOADTESTER_TOKEN"}} `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if h.Name != "Authorization" || h.Value() != "Bearer s3cret" {
+		t.Errorf("Expected Authorization: Bearer s3cret, got %s: %s", h.Name, h.Value())
+	}
+
+	for _, s := range []string{"X-Missing-Colon", ": value", "Bad Name: x", "Host: example.test", "X-ID: {{nope}}"} {
+		if _, err := parseHeader(s); err == nil {
+			t.Errorf("parseHeader(%q): expected error", s)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	cases := map[string]int64{"100": 100, "10B": 10, "512KB": 512 << 10, "1MB": 1 << 20, "2gb": 2 << 30, " 3 mb ": 3 << 20}
+	for in, want := range cases {
+		if got, err := parseByteSize(in); err != nil || got != want {
+			t.Errorf("parseByteSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "MB", "1.5MB", "-1KB", "ten"} {
+		if _, err := parseByteSize(in); err == nil {
+			t.Errorf("parseByteSize(%q): expected error", in)
+		}
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	cases := map[string]int{"100": 100, "1k": 1000, "500K": 500_000, "2m": 2_000_000, " 3 M ": 3_000_000}
+	for in, want := range cases {
+		if got, err := parseCount(in); err != nil || got != want {
+			t.Errorf("parseCount(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "k", "1.5k", "2g", "1kb", "ten", "99999999999999999m"} {
+		if _, err := parseCount(in); err == nil {
+			t.Errorf("parseCount(%q): expected error", in)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_Suffixes(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-requests=5k", "-max-body-size=2MB", "-min-response-size=1k", "-stream", "-stream-bytes=64KB"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.requests != 5000 {
+		t.Errorf("Expected 5000 requests, got %d", opts.requests)
+	}
+	if cfg := opts.config; cfg.MaxBodySize != 2<<20 || cfg.MinResponseSize != 1<<10 || cfg.StreamBytes != 64<<10 {
+		t.Errorf("Expected sizes 2MB, 1KB and 64KB, got %d, %d and %d", cfg.MaxBodySize, cfg.MinResponseSize, cfg.StreamBytes)
+	}
+
+	// Garbage is rejected while parsing the flag
+	var count countValue
+	if err := count.Set("5x"); err == nil {
+		t.Error("Expected an error for -requests=5x")
+	}
+	var size byteSizeValue
+	if err := size.Set("2TB"); err == nil {
+		t.Error("Expected an error for an unknown size suffix")
+	}
+}
+
+func TestParseAndValidateFlags_BodySize(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-method=PUT", "-body-size=2KB"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.BodyFunc == nil || opts.config.ContentType != "application/octet-stream" {
+		t.Fatalf("Expected a generated octet-stream body, got %+v", opts.config)
+	}
+	if body, _ := io.ReadAll(opts.config.BodyFunc()); len(body) != 2048 {
+		t.Errorf("Expected a 2048-byte body, got %d bytes", len(body))
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-body-size=1MB", "-data=x"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining -body-size with -data")
+	}
+}
+
+func TestParseAndValidateFlags_UserAgents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agents.txt")
+	if err := os.WriteFile(path, []byte("# browsers\nagent-a\nagent-b\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write User-Agent file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-user-agents=" + path, "-seed=3"}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.userAgents != 2 {
+		t.Errorf("Expected 2 User-Agents, got %d", opts.userAgents)
+	}
+	headers := opts.config.Headers
+	if len(headers) != 1 || headers[0].Name != "User-Agent" {
+		t.Fatalf("Expected a User-Agent header, got %+v", headers)
+	}
+	for i := 0; i < 20; i++ {
+		if agent := headers[0].Value(); agent != "agent-a" && agent != "agent-b" {
+			t.Fatalf("Expected a User-Agent from the file, got %q", agent)
+		}
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-user-agents=" + path, "-header=user-agent: fixed"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining user-agents with a User-Agent header")
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-user-agents=" + filepath.Join(t.TempDir(), "missing.txt")}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error for a missing User-Agent file")
+	}
+}
+
+func TestParseAndValidateFlags_DataCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte("name,email\nalice,a@test\nbob,b@test\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write CSV file: %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-method=post", "-data-csv=" + path, `-data={"name":"{{.name}}","email":"{{.email}}"}`}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source := opts.config.BodySource
+	if source == nil {
+		t.Fatal("Expected a body source to be configured")
+	}
+	for _, want := range []string{`{"name":"alice","email":"a@test"}`, `{"name":"bob","email":"b@test"}`, `{"name":"alice","email":"a@test"}`} {
+		if body, _ := source.Next(); body != want {
+			t.Errorf("Expected body %q, got %q", want, body)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"-data-csv=" + path},
+		{"-data-csv=" + path, "-data={{.phone}}"},
+		{"-data-csv=" + path, "-data={{.name}}", "-har=session.har"},
+	} {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_VerifyPercentiles(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-verify-percentiles"}
+	if opts, err := parseAndValidateFlags(); err != nil || !opts.verifyPercentiles {
+		t.Errorf("Expected percentile verification to be enabled, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-verify-percentiles", "-compare=http://a", "-compare=http://b"}
+	if _, err := parseAndValidateFlags(); err == nil {
+		t.Error("Expected error combining verify-percentiles with compare")
+	}
+}
+
+func TestParseAndValidateFlags_UntilErrors(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-until-errors=50", "-save-failures=" + t.TempDir()}
+	opts, err := parseAndValidateFlags()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.config.UntilErrors != 50 {
+		t.Errorf("Expected to run until 50 errors, got %d", opts.config.UntilErrors)
+	}
+
+	for _, args := range [][]string{
+		{"-until-errors=-1"},
+		{"-until-errors=5", "-target-successes=5"},
+	} {
+		resetFlags()
+		os.Args = append([]string{"cmd"}, args...)
+		if _, err := parseAndValidateFlags(); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+}
+
+func TestParseAndValidateFlags_MaxRPS(t *testing.T) {
+	resetFlags()
+	os.Args = []string{"cmd", "-max-rps=25.5"}
+	if opts, err := parseAndValidateFlags(); err != nil || opts.config.MaxRPS != 25.5 {
+		t.Errorf("Expected a ceiling of 25.5 requests/sec, got %v", err)
+	}
+
+	resetFlags()
+	os.Args = []string{"cmd", "-max-rps=-1"}
+	if _, err := parseAndValidateFlags(); err == nil || err.Error() != "max-rps must be >= 0, got -1" {
+		t.Errorf("Expected error for a negative ceiling, got: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestStartPprof(t *testing.T) {
+	addr, stop, err := startPprof("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/debug/pprof/heap?debug=1")
+	if err != nil {
+		t.Fatalf("Failed to fetch heap profile: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	stop()
+	if _, err := http.Get("http://" + addr.String() + "/debug/pprof/"); err == nil {
+		t.Error("Expected the server to be stopped")
+	}
+}
+
+func TestStartPprof_AddressInUse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	if _, _, err := startPprof(ln.Addr().String()); err == nil {
+		t.Error("Expected error for an address in use")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"loadtester/internal/mockserver"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// serveOptions holds the flags of the serve subcommand.
+type serveOptions struct {
+	addr string
+	mock mockserver.Options
+}
+
+// parseServeFlags parses the arguments following "serve".
+func parseServeFlags(args []string) (serveOptions, error) {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	port := fs.Int("port", 8080, "Port to listen on")
+	host := fs.String("listen", "", "Address to listen on (default: all interfaces)")
+	delay := fs.Duration("delay", 0, "Delay before every response (e.g. 50ms)")
+	errorRate := fs.Float64("error-rate", 0, "Fraction of requests answered with a 500 (e.g. 0.1)")
+	if err := fs.Parse(args); err != nil {
+		return serveOptions{}, err
+	}
+
+	if *port < 0 || *port > 65535 {
+		return serveOptions{}, fmt.Errorf("port must be in [0, 65535], got %d", *port)
+	}
+	if *delay < 0 {
+		return serveOptions{}, fmt.Errorf("delay must be >= 0, got %v", *delay)
+	}
+	if *errorRate < 0 || *errorRate > 1 {
+		return serveOptions{}, fmt.Errorf("error-rate must be in [0, 1], got %v", *errorRate)
+	}
+	return serveOptions{
+		addr: net.JoinHostPort(*host, strconv.Itoa(*port)),
+		mock: mockserver.Options{Delay: *delay, ErrorRate: *errorRate},
+	}, nil
+}
+
+// serve runs the mock server until it fails, e.g. because the port is taken.
+func serve(args []string) error {
+	opts, err := parseServeFlags(args)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Mock server listening on %s (%v delay, %.0f%% errors)\n", opts.addr, opts.mock.Delay, opts.mock.ErrorRate*100)
+	return http.ListenAndServe(opts.addr, mockserver.Handler(opts.mock))
+}
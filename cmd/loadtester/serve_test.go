@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseServeFlags(t *testing.T) {
+	opts, err := parseServeFlags([]string{"-port=9090", "-delay=50ms", "-error-rate=0.1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if opts.addr != ":9090" || opts.mock.Delay != 50*time.Millisecond || opts.mock.ErrorRate != 0.1 {
+		t.Errorf("Serve flags not parsed correctly: %+v", opts)
+	}
+
+	opts, _ = parseServeFlags([]string{"-listen=127.0.0.1"})
+	if opts.addr != "127.0.0.1:8080" {
+		t.Errorf("Expected the default port on the given address, got %q", opts.addr)
+	}
+}
+
+func TestParseServeFlags_Invalid(t *testing.T) {
+	for _, args := range [][]string{{"-port=70000"}, {"-delay=-1s"}, {"-error-rate=1.5"}, {"-bogus"}} {
+		if _, err := parseServeFlags(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"loadtester/internal/stats"
+	"net/http"
+	"time"
+)
+
+// webhookTimeout bounds the whole webhook call, so an unreachable endpoint
+// can't hold up the end of a run.
+const webhookTimeout = 10 * time.Second
+
+// postWebhook posts the summary of s as JSON to url, as an incoming webhook
+// expects. Any response other than 2xx is an error.
+func postWebhook(url string, s stats.LoadTestStats) error {
+	payload, err := stats.WebhookJSON(s)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
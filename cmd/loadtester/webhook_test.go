@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"loadtester/internal/stats"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPostWebhook(t *testing.T) {
+	var got map[string]any
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &got)
+	}))
+	defer server.Close()
+
+	if err := postWebhook(server.URL, stats.LoadTestStats{TotalRequests: 3, SuccessfulReqs: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if contentType != "application/json" || got["TotalRequests"] != 3.0 || got["text"] == nil {
+		t.Errorf("Expected the JSON summary, got %q %v", contentType, got)
+	}
+}
+
+func TestPostWebhook_Failure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid_token", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	if err := postWebhook(server.URL, stats.LoadTestStats{}); err == nil || err.Error() != "webhook returned 403 Forbidden" {
+		t.Errorf("Expected an error naming the status, got %v", err)
+	}
+}
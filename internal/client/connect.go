@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"loadtester/internal/ratelimit"
+	"net"
+	"slices"
+	"sync"
+	"time"
+)
+
+// withConnectRate limits dial to rate new connections per second, spaced
+// evenly so a run ramps its connection pool up gradually instead of opening
+// every worker's connection at once. The time each dial was held back is
+// recorded on the request's phases. A rate of zero leaves dial unlimited.
+func withConnectRate(dial dialFunc, rate float64) dialFunc {
+	if rate <= 0 {
+		return dial
+	}
+	limiter := ratelimit.New(rate)
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if wait := limiter.Reserve(); wait > 0 {
+			if p, ok := ctx.Value(phasesKey{}).(*phases); ok {
+				p.addConnectWait(wait)
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// roundRobin spreads new connections evenly across every address of a host,
+// resolved once up front, rather than leaving the choice to the resolver.
+type roundRobin struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	lookups    int // queries per host, as a server may answer each with a subset
+
+	mu    sync.Mutex
+	addrs map[string][]string // host -> its addresses, sorted
+	next  map[string]int      // host -> index of the address to dial next
+}
+
+// pick returns the address to dial host on next, resolving it on first use.
+func (rr *roundRobin) pick(ctx context.Context, host string) (string, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	addrs, ok := rr.addrs[host]
+	if !ok {
+		for i := 0; i < rr.lookups; i++ {
+			found, err := rr.lookupHost(ctx, host)
+			if err != nil {
+				return "", err
+			}
+			for _, addr := range found {
+				if !slices.Contains(addrs, addr) {
+					addrs = append(addrs, addr)
+				}
+			}
+		}
+		slices.Sort(addrs)
+		rr.addrs[host] = addrs
+	}
+	addr := addrs[rr.next[host]%len(addrs)]
+	rr.next[host]++
+	return addr, nil
+}
+
+// withRoundRobin makes dial connect to the addresses of each host in turn,
+// gathered from lookups queries to resolver (nil for the system resolver)
+// made the first time the host is dialed. Zero lookups leaves dial
+// unchanged, as does an address that is already an IP.
+func withRoundRobin(dial dialFunc, resolver *net.Resolver, lookups int) dialFunc {
+	if lookups <= 0 {
+		return dial
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return roundRobinDial(dial, resolver.LookupHost, lookups)
+}
+
+// roundRobinDial is withRoundRobin with the lookup function supplied.
+func roundRobinDial(dial dialFunc, lookupHost func(context.Context, string) ([]string, error), lookups int) dialFunc {
+	rr := &roundRobin{
+		lookupHost: lookupHost,
+		lookups:    lookups,
+		addrs:      make(map[string][]string),
+		next:       make(map[string]int),
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, address)
+		}
+		ip, err := rr.pick(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(ip, port))
+	}
+}
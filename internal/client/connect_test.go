@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"loadtester/internal/config"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMakeRequest_ConnectRate(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond) // outlast the dials, so each worker needs its own connection
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    4,
+		ConnectRate:    20, // one connection every 50ms
+	}
+	c := NewClient(cfg)
+
+	results := make([]TestResult, 4)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.MakeRequest(cfg)
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("Expected 4 connections at 20/s to take at least 150ms, took %v", elapsed)
+	}
+	var waited int
+	var longest time.Duration
+	for _, result := range results {
+		if !result.Success {
+			t.Fatalf("Unexpected failure: %s", result.ErrorMessage)
+		}
+		if result.ConnectWait > 0 {
+			waited++
+			longest = max(longest, result.ConnectWait)
+		}
+	}
+	if waited != 3 || longest < 140*time.Millisecond {
+		t.Errorf("Expected 3 dials held back up to 150ms, got %d up to %v", waited, longest)
+	}
+}
+
+func TestRoundRobinDial(t *testing.T) {
+	// Each lookup answers with a subset, as some DNS servers do
+	answers := [][]string{{"10.0.0.2", "10.0.0.1"}, {"10.0.0.3", "10.0.0.1"}}
+	var lookups int
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		if host != "svc.test" {
+			t.Errorf("Expected a lookup of svc.test, got %q", host)
+		}
+		answer := answers[lookups%len(answers)]
+		lookups++
+		return answer, nil
+	}
+	var dialed []string
+	dial := roundRobinDial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, nil
+	}, lookup, 2)
+
+	for i := 0; i < 4; i++ {
+		dial(context.Background(), "tcp", "svc.test:443")
+	}
+	dial(context.Background(), "tcp", "192.0.2.7:80")
+
+	want := []string{"10.0.0.1:443", "10.0.0.2:443", "10.0.0.3:443", "10.0.0.1:443", "192.0.2.7:80"}
+	if !slices.Equal(dialed, want) {
+		t.Errorf("Expected dials %v, got %v", want, dialed)
+	}
+	if lookups != 2 {
+		t.Errorf("Expected the host to be resolved once with 2 lookups, got %d lookups", lookups)
+	}
+}
+
+func TestRoundRobinDial_LookupError(t *testing.T) {
+	dial := roundRobinDial(func(ctx context.Context, network, address string) (net.Conn, error) {
+		t.Errorf("Expected no dial after a failed lookup, got %s", address)
+		return nil, nil
+	}, func(ctx context.Context, host string) ([]string, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}, 1)
+
+	if _, err := dial(context.Background(), "tcp", "missing.test:80"); err == nil {
+		t.Error("Expected the lookup error to be returned")
+	}
+}
+
+func TestMakeRequest_DNSRoundRobin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	cfg := config.RequestConfig{
+		URL:            "http://localhost:" + port,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+		DNSRoundRobin:  1,
+	}
+	result := NewClient(cfg).MakeRequest(cfg)
+	// Addresses are dialed in sorted order, so 127.0.0.1 comes before any ::1
+	if !result.Success || result.RemoteAddr != "127.0.0.1" {
+		t.Errorf("Expected a connection to 127.0.0.1, got %q (%s)", result.RemoteAddr, result.ErrorMessage)
+	}
+}
@@ -1,53 +1,305 @@
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
+	"encoding/json"
+	"fmt"
 	"io"
 	"loadtester/internal/config"
 	"loadtester/internal/errors"
 	"net"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 type TestResult struct {
+	Step         string // scenario step the request belongs to, empty outside scenarios
+	Tag          string // tag of that step, empty when untagged
+	First        bool   // the first result of the run to complete, set by the runner
+	Method       string // request method and URL as sent
+	URL          string
 	Success      bool
 	StatusCode   int
-	ResponseTime time.Duration
+	ResponseTime time.Duration // until the response headers arrived
+	BodyTime     time.Duration // reading the response body after that
 	ErrorType    errors.ErrorType
 	ErrorMessage string
 	ResponseSize int64
+	DNSTime      time.Duration
+	ConnectTime  time.Duration // TCP connect duration; zero when a connection was reused
+	RemoteAddr   string        // server IP the connection went to; empty when none was obtained
+	Protocol     string        // e.g. "HTTP/1.1" or "HTTP/2.0"
+	CacheStatus  string        // cache outcome read from config.CacheHeader, see cacheStatus
+	RetryAfter   time.Duration // delay asked for by a Retry-After header on a 429 or 503; zero when absent
+	TLSVersion   string        // negotiated TLS version, e.g. "TLS 1.3"; empty for plain HTTP
+	TLSCipher    string        // negotiated cipher suite name
+	CertExpiry   time.Time     // NotAfter of the server's leaf certificate; zero for plain HTTP
+	WaitTime     time.Duration // time spent queued for a worker slot before sending
+	ConnectWait  time.Duration // time a dial was held back by config.ConnectRate
+	ThrottleWait time.Duration // time the request was held back by config.MaxRPS
+	// Request body bytes sent on the wire, and before compression (the two
+	// are equal unless the body was compressed)
+	RequestSize    int64
+	RequestRawSize int64
+	// Connection use: exactly one of these is set once a connection was obtained
+	NewConnection    bool // the request dialed a fresh connection
+	ReusedConnection bool // the request was sent on an idle keep-alive connection
+	Attempts         int  // tries made when retries are enabled, including the first
+	// What was sent and received, for failed requests when config.CaptureFailures is set
+	Exchange *Exchange
 }
 
-func MakeRequest(config config.RequestConfig) TestResult {
-	start := time.Now()
+// Exchange records a request and whatever response came back for it.
+type Exchange struct {
+	Method          string
+	URL             string
+	Host            string
+	RequestHeaders  http.Header
+	Proto           string // empty when no response was received
+	Status          string // e.g. "500 Internal Server Error"
+	ResponseHeaders http.Header
+	Body            string // as read, so subject to the body size limit
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
-	defer cancel()
+// Client sends requests over a single transport, so keep-alive connections
+// are reused across every request of a run. It is safe for concurrent use.
+type Client struct {
+	httpClient *http.Client
+
+	// With config.ConnPerWorker, each worker instead gets a client of its own
+	// holding one connection per host, created on its first request
+	newWorkerClient func() *http.Client
+	mu              sync.Mutex
+	workerClients   map[int]*http.Client
+}
+
+// NewClient builds a client from the run-level settings in config: the DNS
+// server, TLS settings and concurrency (which sizes the idle connection pool).
+// With config.Transport set, the client sends everything on it instead.
+func NewClient(config config.RequestConfig) *Client {
+	if config.Transport != nil {
+		return &Client{httpClient: &http.Client{CheckRedirect: checkRedirect(config), Transport: config.Transport}}
+	}
+	// Built once, so every transport shares the connect rate limit
+	resolver := newResolver(config.DNSServer)
+	dial := withDialRetries(withConnectRate(withRoundRobin((&net.Dialer{
+		Timeout:   5 * time.Second, // Connection timeout
+		KeepAlive: 30 * time.Second,
+		Resolver:  resolver,
+	}).DialContext, resolver, config.DNSRoundRobin), config.ConnectRate), config.DialRetries)
+	newHTTPClient := func(idleConns, maxConns int) *http.Client {
+		return &http.Client{
+			CheckRedirect: checkRedirect(config),
+			// Each request carries its own deadline, see makeRequest
+			Transport: &http.Transport{
+				DialContext:           dial,
+				TLSHandshakeTimeout:   10 * time.Second,
+				ResponseHeaderTimeout: 10 * time.Second,
+				ExpectContinueTimeout: 1 * time.Second,
+				MaxIdleConns:          idleConns, // Limit max idle connections
+				MaxIdleConnsPerHost:   idleConns,
+				MaxConnsPerHost:       maxConns,
+				TLSClientConfig:       tlsConfig(config),
+			},
+		}
+	}
+
+	c := &Client{httpClient: newHTTPClient(IdleConnsPerHost(config), config.MaxConns)}
+	if config.ConnPerWorker {
+		c.newWorkerClient = func() *http.Client { return newHTTPClient(1, 1) }
+		c.workerClients = make(map[int]*http.Client)
+	}
+	return c
+}
+
+// clientFor returns the HTTP client to send config's request on: the
+// worker's own with config.ConnPerWorker, else the shared one.
+func (c *Client) clientFor(config config.RequestConfig) *http.Client {
+	if c.newWorkerClient == nil {
+		return c.httpClient
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	httpClient, ok := c.workerClients[config.Worker]
+	if !ok {
+		httpClient = c.newWorkerClient()
+		c.workerClients[config.Worker] = httpClient
+	}
+	return httpClient
+}
+
+// closeIdleConnections closes the idle connections of every transport.
+func (c *Client) closeIdleConnections() {
+	c.httpClient.CloseIdleConnections()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, httpClient := range c.workerClients {
+		httpClient.CloseIdleConnections()
+	}
+}
+
+// checkRedirect returns the redirect policy for config: with AllowRedirects
+// set the redirect response itself is returned rather than followed, and nil
+// otherwise, which follows up to 10 redirects.
+func checkRedirect(config config.RequestConfig) func(*http.Request, []*http.Request) error {
+	if !config.AllowRedirects {
+		return nil
+	}
+	return func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+}
+
+// dialFunc is the signature of net.Dialer.DialContext.
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// dialRetryDelay is the pause between TCP connect attempts.
+const dialRetryDelay = 50 * time.Millisecond
+
+// withDialRetries retries a failed connect up to retries more times, so a
+// momentary blip such as a refused connection doesn't fail the request.
+func withDialRetries(dial dialFunc, retries int) dialFunc {
+	if retries <= 0 {
+		return dial
+	}
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		for attempt := 0; err != nil && attempt < retries; attempt++ {
+			timer := time.NewTimer(dialRetryDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, err
+			}
+			conn, err = dial(ctx, network, address)
+		}
+		return conn, err
+	}
+}
 
-	client := &http.Client{
-		Timeout: config.Timeout,
-		Transport: &http.Transport{
-			DialContext: (&net.Dialer{
-				Timeout:   5 * time.Second, // Connection timeout
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ResponseHeaderTimeout: 10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-			MaxIdleConns:          config.Concurrency, // Limit max idle connections
-			MaxIdleConnsPerHost:   config.Concurrency,
-			// Skip TLS verification for testing (optional)
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: false},
+// newResolver returns a resolver that sends all lookups to server, or nil to
+// use the system resolver when server is empty.
+func newResolver(server string) *net.Resolver {
+	if server == "" {
+		return nil
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: 5 * time.Second}
+			return d.DialContext(ctx, network, server)
 		},
 	}
+}
+
+// IdleConnsPerHost is the number of idle keep-alive connections a Client
+// built from config keeps open per host. By default that is one per worker,
+// but never more than the connection limit.
+func IdleConnsPerHost(config config.RequestConfig) int {
+	switch {
+	case config.MaxIdleConns > 0:
+		return config.MaxIdleConns
+	case config.MaxConns > 0 && (config.Concurrency == 0 || config.MaxConns < config.Concurrency):
+		return config.MaxConns
+	case config.Concurrency > 0:
+		return config.Concurrency
+	default:
+		return http.DefaultMaxIdleConnsPerHost
+	}
+}
+
+// MakeRequest sends a single request on a client of its own, or on
+// config.Transport when set. Load tests should share a Client instead so that
+// connections are reused.
+func MakeRequest(config config.RequestConfig) TestResult {
+	c := NewClient(config)
+	if config.Transport == nil {
+		// The caller's transport keeps its connections for later requests
+		defer c.closeIdleConnections()
+	}
+	return c.MakeRequest(config)
+}
+
+// MakeRequest sends a single request and checks the response against the
+// expectations in config.
+func (c *Client) MakeRequest(config config.RequestConfig) TestResult {
+	parent := config.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, span := startSpan(parent, config)
+	phases := &phases{}
+	var exchange *Exchange
+	if config.CaptureFailures {
+		exchange = &Exchange{}
+	}
+	ctx = context.WithValue(httptrace.WithClientTrace(ctx, phases.clientTrace()), phasesKey{}, phases)
+	result := c.makeRequest(ctx, config, exchange)
+	if exchange != nil && !result.Success {
+		result.Exchange = exchange
+	}
+	result.Method, result.URL = config.Method, config.URL
+	if result.Method == "" {
+		result.Method = http.MethodGet
+	}
+
+	times := phases.snapshot()
+	result.DNSTime = times.dnsTime()
+	result.ConnectTime = times.connectTime()
+	result.ConnectWait = times.connectWait
+	if times.gotConn {
+		result.ReusedConnection = times.reused
+		result.NewConnection = !times.reused
+		result.RemoteAddr = times.remoteAddr
+	}
+	endSpan(span, result, times)
+	return result
+}
+
+// makeRequest sends the request, filling in exchange as it goes when it is
+// not nil.
+func (c *Client) makeRequest(ctx context.Context, config config.RequestConfig, exchange *Exchange) TestResult {
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, config.Timeout)
+	defer cancel()
 
 	// Create request with context
-	req, err := http.NewRequestWithContext(ctx, "GET", config.URL, nil)
+	method := config.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var reqBody io.Reader
+	if config.BodyFunc != nil {
+		reqBody = config.BodyFunc()
+	} else if config.Body != "" {
+		reqBody = strings.NewReader(config.Body)
+	}
+	rawSize := int64(-1)
+	if config.CompressRequest && reqBody != nil {
+		var err error
+		reqBody, rawSize, err = gzipBody(reqBody)
+		if err != nil {
+			return TestResult{
+				Success:      false,
+				ResponseTime: time.Since(start),
+				ErrorType:    errors.ErrorTypeDataSource,
+				ErrorMessage: fmt.Sprintf("Data source error: compressing request body: %v", err),
+			}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, config.URL, reqBody)
 	if err != nil {
 		responseTime := time.Since(start)
-		errorType, errorMsg := errors.CategorizeError(err, 0, config.ExpectedStatus, config.ExpectedBody, "")
+		errorType, errorMsg := errors.CategorizeError(err, errors.Response{}, expectations(config))
 		return TestResult{
 			Success:      false,
 			StatusCode:   0,
@@ -59,47 +311,238 @@ func MakeRequest(config config.RequestConfig) TestResult {
 
 	// Add User-Agent for identification
 	req.Header.Set("User-Agent", "Go-Load-Tester/1.0")
+	if config.BodyFunc != nil {
+		// A generated body can't be inspected without consuming it
+		if config.ContentType != "" && config.ContentType != "none" {
+			req.Header.Set("Content-Type", config.ContentType)
+		}
+	} else if config.Body != "" {
+		if contentType := bodyContentType(config); contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	if rawSize >= 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	// Custom headers replace the defaults above, and may be given repeatedly
+	for _, h := range config.Headers {
+		req.Header.Del(h.Name)
+	}
+	for _, h := range config.Headers {
+		req.Header.Add(h.Name, h.Value())
+	}
+	// Known for in-memory bodies, which every body is once compressed
+	requestSize := max(req.ContentLength, 0)
+	requestRawSize := requestSize
+	if rawSize >= 0 {
+		requestRawSize = rawSize
+	}
+	// Go sends req.Host rather than a Host header, so override it here
+	if config.Host != "" {
+		req.Host = config.Host
+	}
+	injectTraceContext(ctx, req)
+	if exchange != nil {
+		exchange.Method = req.Method
+		exchange.URL = req.URL.String()
+		exchange.Host = req.Host
+		exchange.RequestHeaders = req.Header.Clone()
+	}
 
 	// Make the request
-	resp, err := client.Do(req)
+	httpClient := c.clientFor(config)
+	if config.Jar != nil {
+		// Same transport, so the connections are still shared
+		httpClient = &http.Client{Transport: httpClient.Transport, CheckRedirect: httpClient.CheckRedirect, Jar: config.Jar}
+	}
+	resp, err := httpClient.Do(req)
 	responseTime := time.Since(start)
 
 	if err != nil {
-		errorType, errorMsg := errors.CategorizeError(err, 0, config.ExpectedStatus, config.ExpectedBody, "")
+		errorType, errorMsg := errors.CategorizeError(err, errors.Response{}, expectations(config))
 		return TestResult{
-			Success:      false,
-			StatusCode:   0,
-			ResponseTime: responseTime,
-			ErrorType:    errorType,
-			ErrorMessage: errorMsg,
+			Success:        false,
+			StatusCode:     0,
+			ResponseTime:   responseTime,
+			ErrorType:      errorType,
+			ErrorMessage:   errorMsg,
+			RequestSize:    requestSize,
+			RequestRawSize: requestRawSize,
 		}
 	}
 	defer resp.Body.Close()
+	if exchange != nil {
+		exchange.Proto = resp.Proto
+		exchange.Status = resp.Status
+		exchange.ResponseHeaders = resp.Header.Clone()
+	}
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 10*1024*1024))
+	body, size, err := readBody(resp.Body, config)
+	bodyTime := time.Since(start) - responseTime
 	if err != nil {
-		errorType, errorMsg := errors.CategorizeError(err, 0, config.ExpectedStatus, config.ExpectedBody, "")
+		errorType, errorMsg := errors.CategorizeError(err, errors.Response{}, expectations(config))
 		return TestResult{
-			Success:      false,
-			StatusCode:   resp.StatusCode,
-			ResponseTime: responseTime,
-			ErrorType:    errorType,
-			ErrorMessage: errorMsg,
-			ResponseSize: int64(len(body)),
+			Success:        false,
+			StatusCode:     resp.StatusCode,
+			ResponseTime:   responseTime,
+			BodyTime:       bodyTime,
+			ErrorType:      errorType,
+			ErrorMessage:   errorMsg,
+			ResponseSize:   size,
+			RequestSize:    requestSize,
+			RequestRawSize: requestRawSize,
+			Protocol:       resp.Proto,
+			CacheStatus:    cacheStatus(resp.Header, config.CacheHeader),
+		}
+	}
+
+	var tlsVersion, tlsCipher string
+	var certExpiry time.Time
+	if resp.TLS != nil {
+		tlsVersion = tls.VersionName(resp.TLS.Version)
+		tlsCipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+		if len(resp.TLS.PeerCertificates) > 0 {
+			certExpiry = resp.TLS.PeerCertificates[0].NotAfter
 		}
 	}
 
 	bodyStr := string(body)
-	errorType, errorMsg := errors.CategorizeError(nil, resp.StatusCode, config.ExpectedStatus, config.ExpectedBody, bodyStr)
+	if exchange != nil {
+		exchange.Body = bodyStr
+	}
+	errorType, errorMsg := errors.CategorizeError(nil, errors.Response{StatusCode: resp.StatusCode, Body: bodyStr, Size: size, Latency: responseTime}, expectations(config))
 
 	success := errorType == ""
 
 	return TestResult{
-		Success:      success,
-		StatusCode:   resp.StatusCode,
-		ResponseTime: responseTime,
-		ErrorType:    errorType,
-		ErrorMessage: errorMsg,
-		ResponseSize: int64(len(body)),
+		Success:        success,
+		StatusCode:     resp.StatusCode,
+		ResponseTime:   responseTime,
+		BodyTime:       bodyTime,
+		ErrorType:      errorType,
+		ErrorMessage:   errorMsg,
+		ResponseSize:   size,
+		RequestSize:    requestSize,
+		RequestRawSize: requestRawSize,
+		Protocol:       resp.Proto,
+		CacheStatus:    cacheStatus(resp.Header, config.CacheHeader),
+		RetryAfter:     retryAfter(resp, time.Now()),
+		TLSVersion:     tlsVersion,
+		TLSCipher:      tlsCipher,
+		CertExpiry:     certExpiry,
+	}
+}
+
+// bodyContentType returns the Content-Type to send with config.Body. Unless
+// set explicitly it is detected: JSON bodies get application/json and
+// anything else text/plain.
+func bodyContentType(config config.RequestConfig) string {
+	switch config.ContentType {
+	case "none":
+		return ""
+	case "":
+		if json.Valid([]byte(config.Body)) {
+			return "application/json"
+		}
+		return "text/plain; charset=utf-8"
+	default:
+		return config.ContentType
+	}
+}
+
+// cacheStatus returns the value of the cache header named name, or "" when
+// name is empty. A missing header is reported as "(none)". An Age header is
+// reduced to HIT for a positive age and MISS otherwise, as raw ages would
+// scatter the breakdown.
+func cacheStatus(header http.Header, name string) string {
+	if name == "" {
+		return ""
+	}
+	value := strings.TrimSpace(header.Get(name))
+	if http.CanonicalHeaderKey(name) == "Age" {
+		if age, err := strconv.Atoi(value); err == nil && age > 0 {
+			return "HIT"
+		}
+		return "MISS"
+	}
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// retryAfter returns the delay a 429 or 503 response asks for in its
+// Retry-After header, given either in seconds or as an HTTP date relative to
+// now. It is zero for other responses and for a missing or invalid header.
+func retryAfter(resp *http.Response, now time.Time) time.Duration {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return 0
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now).Round(time.Second)
+	}
+	return 0
+}
+
+// gzipBody compresses body into a buffer of its own, so concurrent requests
+// never share compression state. It returns the uncompressed size as well.
+func gzipBody(body io.Reader) (io.Reader, int64, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	n, err := io.Copy(zw, body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, 0, err
+	}
+	return &buf, n, nil
+}
+
+// tlsConfig returns a copy of the configured TLS settings, so the transport
+// never shares mutable state with the caller.
+func tlsConfig(config config.RequestConfig) *tls.Config {
+	if config.TLS == nil {
+		return &tls.Config{}
+	}
+	return config.TLS.Clone()
+}
+
+// expectations collects the response checks configured for a request.
+func expectations(config config.RequestConfig) errors.Expectations {
+	expect := errors.Expectations{
+		Status:          config.ExpectedStatus,
+		Bodies:          config.AcceptedBodies(),
+		BodyNotContains: config.BodyNotContains,
+		MinSize:         config.MinResponseSize,
+		AllowRedirects:  config.AllowRedirects,
+		MaxLatency:      config.MaxLatency,
+	}
+	if config.BodyValidator != nil {
+		expect.Validate = config.BodyValidator.Validate
+	}
+	return expect
+}
+
+// readBody reads the response body up to the configured limit (zero means
+// unlimited). With DiscardBody set the bytes are only counted, never buffered.
+// With StreamBytes set only the start of the body is read, so endpoints that
+// never finish their response (SSE, chunked feeds) don't block the worker.
+func readBody(r io.Reader, config config.RequestConfig) ([]byte, int64, error) {
+	if config.MaxBodySize > 0 {
+		r = io.LimitReader(r, config.MaxBodySize)
+	}
+	if config.StreamBytes > 0 {
+		r = io.LimitReader(r, config.StreamBytes)
+	}
+	if config.DiscardBody {
+		n, err := io.Copy(io.Discard, r)
+		return nil, n, err
 	}
+	body, err := io.ReadAll(r)
+	return body, int64(len(body)), err
 }
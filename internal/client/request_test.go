@@ -1,8 +1,17 @@
 package client
 
 import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -35,6 +44,32 @@ func TestMakeRequest_Success(t *testing.T) {
 	if result.ResponseSize != int64(len("Hello, World!")) {
 		t.Errorf("Expected response size %d, got %d", len("Hello, World!"), result.ResponseSize)
 	}
+	if result.Protocol != "HTTP/1.1" {
+		t.Errorf("Expected protocol HTTP/1.1, got %q", result.Protocol)
+	}
+	if result.Method != http.MethodGet || result.URL != server.URL {
+		t.Errorf("Expected the result to record GET %s, got %s %s", server.URL, result.Method, result.URL)
+	}
+}
+
+func TestMakeRequest_BodyTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("slow body"))
+	}))
+	defer server.Close()
+
+	result := MakeRequest(config.RequestConfig{URL: server.URL, Timeout: 2 * time.Second, ExpectedStatus: http.StatusOK, Concurrency: 1})
+
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %v", result.ErrorMessage)
+	}
+	// The response time ends with the headers, the body time covers the rest
+	if result.ResponseTime >= 50*time.Millisecond || result.BodyTime < 50*time.Millisecond {
+		t.Errorf("Expected the slow body in the body time, got %v response and %v body", result.ResponseTime, result.BodyTime)
+	}
 }
 
 func TestMakeRequest_Timeout(t *testing.T) {
@@ -110,3 +145,741 @@ func TestMakeRequest_BadBody(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusOK, result.StatusCode)
 	}
 }
+
+func TestMakeRequest_CustomDNSServer(t *testing.T) {
+	// Nothing listens on this port, so every lookup through it must fail
+	cfg := config.RequestConfig{
+		URL:            "http://loadtester.invalid",
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+		DNSServer:      "127.0.0.1:1",
+	}
+
+	result := MakeRequest(cfg)
+
+	if result.Success {
+		t.Errorf("Expected failure when resolving through an unreachable DNS server, got success")
+	}
+	if result.StatusCode != 0 {
+		t.Errorf("Expected no status code, got %d", result.StatusCode)
+	}
+}
+
+func TestNewResolver_Default(t *testing.T) {
+	if newResolver("") != nil {
+		t.Error("Expected nil resolver when no DNS server is configured")
+	}
+}
+
+func TestMakeRequest_MethodAndBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || string(body) != `{"id":1}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Method:         http.MethodPost,
+		Body:           `{"id":1}`,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusCreated,
+		Concurrency:    1,
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success {
+		t.Errorf("Expected success, got failure: %v", result.ErrorMessage)
+	}
+}
+
+func TestMakeRequest_MaxBodySize(t *testing.T) {
+	payload := strings.Repeat("x", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name     string
+		limit    int64
+		discard  bool
+		wantSize int64
+	}{
+		{"limited", 100, false, 100},
+		{"unlimited", 0, false, 1000},
+		{"unlimited discard", 0, true, 1000},
+		{"limited discard", 100, true, 100},
+	}
+	for _, c := range cases {
+		cfg := config.RequestConfig{
+			URL:            server.URL,
+			Timeout:        2 * time.Second,
+			ExpectedStatus: http.StatusOK,
+			Concurrency:    1,
+			MaxBodySize:    c.limit,
+			DiscardBody:    c.discard,
+		}
+
+		result := MakeRequest(cfg)
+
+		if result.ResponseSize != c.wantSize {
+			t.Errorf("%s: expected response size %d, got %d", c.name, c.wantSize, result.ResponseSize)
+		}
+	}
+}
+
+func TestMakeRequest_HostOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "example.test" {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Host:           "example.test",
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success {
+		t.Errorf("Expected request to carry the overridden Host, got status %d", result.StatusCode)
+	}
+}
+
+func TestMakeRequest_Headers(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	n := 0
+	cfg := config.RequestConfig{
+		URL: server.URL,
+		Headers: []config.Header{
+			{Name: "User-Agent", Value: func() string { return "custom/1.0" }},
+			{Name: "X-Request-ID", Value: func() string { n++; return fmt.Sprint("req-", n) }},
+			{Name: "X-Tag", Value: func() string { return "a" }},
+			{Name: "X-Tag", Value: func() string { return "b" }},
+		},
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	c := NewClient(cfg)
+	c.MakeRequest(cfg)
+	if result := c.MakeRequest(cfg); !result.Success {
+		t.Fatalf("Unexpected failure: %s", result.ErrorMessage)
+	}
+	if ua := got.Values("User-Agent"); len(ua) != 1 || ua[0] != "custom/1.0" {
+		t.Errorf("Expected the custom User-Agent to replace the default, got %v", ua)
+	}
+	if id := got.Get("X-Request-ID"); id != "req-2" {
+		t.Errorf("Expected the header value to be produced per request, got %q", id)
+	}
+	if tags := got.Values("X-Tag"); len(tags) != 2 {
+		t.Errorf("Expected both values of a repeated header, got %v", tags)
+	}
+}
+
+func TestMakeRequest_ClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	cfg.TLS = &tls.Config{RootCAs: roots}
+	if result := MakeRequest(cfg); result.Success {
+		t.Error("Expected the handshake to fail without a client certificate")
+	}
+
+	cfg.TLS.Certificates = server.TLS.Certificates
+	if result := MakeRequest(cfg); !result.Success {
+		t.Errorf("Expected success with a client certificate, got %v", result.ErrorMessage)
+	}
+}
+
+func TestClient_ReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+	c := NewClient(cfg)
+
+	first := c.MakeRequest(cfg)
+	second := c.MakeRequest(cfg)
+
+	if first.ConnectTime <= 0 || second.ConnectTime != 0 {
+		t.Errorf("Expected a connect time only for the new connection, got %v and %v", first.ConnectTime, second.ConnectTime)
+	}
+	if !first.NewConnection || first.ReusedConnection {
+		t.Errorf("Expected the first request to open a connection, got %+v", first)
+	}
+	if !second.ReusedConnection || second.NewConnection {
+		t.Errorf("Expected the second request to reuse the connection, got %+v", second)
+	}
+	if first.RemoteAddr != "127.0.0.1" || second.RemoteAddr != "127.0.0.1" {
+		t.Errorf("Expected both requests to record the server's IP, got %q and %q", first.RemoteAddr, second.RemoteAddr)
+	}
+
+	// The package-level helper never shares connections
+	if result := MakeRequest(cfg); !result.NewConnection {
+		t.Errorf("Expected MakeRequest to open its own connection, got %+v", result)
+	}
+}
+
+func TestMakeRequest_EarlyHintsBeforeFinalResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("done"))
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   "done",
+		Concurrency:    1,
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success || result.StatusCode != http.StatusOK {
+		t.Errorf("Expected the final 200 to be recorded, got status %d: %v", result.StatusCode, result.ErrorMessage)
+	}
+}
+
+func TestMakeRequest_ContentType(t *testing.T) {
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		body, contentType, want string
+	}{
+		{`{"id":1}`, "", "application/json"},
+		{"id=1", "", "text/plain; charset=utf-8"},
+		{"id=1", "application/x-www-form-urlencoded", "application/x-www-form-urlencoded"},
+		{`{"id":1}`, "none", ""},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		got = "unset"
+		cfg := config.RequestConfig{
+			URL:            server.URL,
+			Method:         http.MethodPost,
+			Body:           c.body,
+			ContentType:    c.contentType,
+			Timeout:        2 * time.Second,
+			ExpectedStatus: http.StatusOK,
+			Concurrency:    1,
+		}
+
+		MakeRequest(cfg)
+
+		if got != c.want {
+			t.Errorf("body %q with -content-type %q: expected Content-Type %q, got %q", c.body, c.contentType, c.want, got)
+		}
+	}
+}
+
+func TestIdleConnsPerHost(t *testing.T) {
+	cases := []struct {
+		cfg  config.RequestConfig
+		want int
+	}{
+		{config.RequestConfig{}, http.DefaultMaxIdleConnsPerHost},
+		{config.RequestConfig{Concurrency: 50}, 50},
+		{config.RequestConfig{Concurrency: 50, MaxIdleConns: 10}, 10},
+		{config.RequestConfig{Concurrency: 50, MaxConns: 5}, 5},
+		{config.RequestConfig{Concurrency: 4, MaxConns: 8}, 4},
+	}
+	for _, c := range cases {
+		if got := IdleConnsPerHost(c.cfg); got != c.want {
+			t.Errorf("%+v: expected %d idle connections, got %d", c.cfg, c.want, got)
+		}
+	}
+}
+
+func TestMakeRequest_ReportsNegotiatedTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+		TLS: &tls.Config{
+			RootCAs:      roots,
+			MaxVersion:   tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256},
+		},
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %v", result.ErrorMessage)
+	}
+	if result.TLSVersion != "TLS 1.2" || result.TLSCipher != "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" {
+		t.Errorf("Expected the forced TLS settings to be reported, got %q %q", result.TLSVersion, result.TLSCipher)
+	}
+	if !result.CertExpiry.Equal(server.Certificate().NotAfter) {
+		t.Errorf("Expected certificate expiry %v, got %v", server.Certificate().NotAfter, result.CertExpiry)
+	}
+}
+
+func TestMakeRequest_BodyFunc(t *testing.T) {
+	var received []string
+	var contentTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, string(body))
+		contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	calls := 0
+	cfg := config.RequestConfig{
+		URL:    server.URL,
+		Method: http.MethodPost,
+		Body:   "static",
+		BodyFunc: func() io.Reader {
+			calls++
+			return strings.NewReader(fmt.Sprintf("generated %d", calls))
+		},
+		ContentType:    "application/x-protobuf",
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+	c := NewClient(cfg)
+
+	c.MakeRequest(cfg)
+	c.MakeRequest(cfg)
+
+	if len(received) != 2 || received[0] != "generated 1" || received[1] != "generated 2" {
+		t.Errorf("Expected a fresh generated body per request, got %q", received)
+	}
+	if contentTypes[0] != "application/x-protobuf" {
+		t.Errorf("Expected the explicit Content-Type, got %q", contentTypes[0])
+	}
+}
+
+func TestMakeRequest_CompressRequest(t *testing.T) {
+	payload := strings.Repeat(`{"id":1}`, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(zr)
+		if string(body) != payload {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:             server.URL,
+		Method:          http.MethodPost,
+		Body:            payload,
+		CompressRequest: true,
+		Timeout:         2 * time.Second,
+		ExpectedStatus:  http.StatusCreated,
+		Concurrency:     1,
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %v", result.ErrorMessage)
+	}
+	if result.RequestRawSize != int64(len(payload)) {
+		t.Errorf("Expected uncompressed size %d, got %d", len(payload), result.RequestRawSize)
+	}
+	if result.RequestSize == 0 || result.RequestSize >= result.RequestRawSize {
+		t.Errorf("Expected a compressed size below %d, got %d", result.RequestRawSize, result.RequestSize)
+	}
+
+	cfg.CompressRequest = false
+	cfg.ExpectedStatus = http.StatusUnsupportedMediaType
+	result = MakeRequest(cfg)
+	if result.RequestSize != int64(len(payload)) || result.RequestRawSize != result.RequestSize {
+		t.Errorf("Expected equal sizes without compression, got %d and %d", result.RequestSize, result.RequestRawSize)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	cases := []struct {
+		status int
+		header string
+		want   time.Duration
+	}{
+		{http.StatusTooManyRequests, "30", 30 * time.Second},
+		{http.StatusServiceUnavailable, "  5 ", 5 * time.Second},
+		{http.StatusTooManyRequests, now.Add(2 * time.Minute).Format(http.TimeFormat), 2 * time.Minute},
+		{http.StatusTooManyRequests, now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{http.StatusTooManyRequests, "soon", 0},
+		{http.StatusTooManyRequests, "", 0},
+		{http.StatusOK, "30", 0},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Header: http.Header{"Retry-After": {c.header}}}
+		if got := retryAfter(resp, now); got != c.want {
+			t.Errorf("retryAfter(%d, %q) = %v, want %v", c.status, c.header, got, c.want)
+		}
+	}
+}
+
+func TestMakeRequest_CacheHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		CacheHeader:    "x-cache",
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	if result := MakeRequest(cfg); result.CacheStatus != "HIT" {
+		t.Errorf("Expected cache status HIT, got %q", result.CacheStatus)
+	}
+}
+
+func TestCacheStatus(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-Cache", " MISS from edge ")
+	header.Set("Age", "120")
+	cases := []struct {
+		header http.Header
+		name   string
+		want   string
+	}{
+		{header, "", ""},
+		{header, "X-Cache", "MISS from edge"},
+		{header, "X-Cache-Status", "(none)"},
+		{header, "age", "HIT"},
+		{http.Header{"Age": {"0"}}, "Age", "MISS"},
+		{http.Header{}, "Age", "MISS"},
+	}
+	for _, c := range cases {
+		if got := cacheStatus(c.header, c.name); got != c.want {
+			t.Errorf("cacheStatus(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestMakeRequest_AllowRedirects(t *testing.T) {
+	var followed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/target" {
+			followed = true
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		http.Redirect(w, r, "/target", http.StatusFound)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL + "/short",
+		AllowRedirects: true,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	result := MakeRequest(cfg)
+
+	if !result.Success || result.StatusCode != http.StatusFound {
+		t.Errorf("Expected the 302 itself to succeed, got %d: %v", result.StatusCode, result.ErrorMessage)
+	}
+	if followed {
+		t.Error("Expected the redirect not to be followed")
+	}
+}
+
+func TestMakeRequest_CaptureFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", "edge-1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("try later"))
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:             server.URL,
+		Timeout:         2 * time.Second,
+		ExpectedStatus:  http.StatusOK,
+		Concurrency:     1,
+		CaptureFailures: true,
+	}
+
+	result := MakeRequest(cfg)
+
+	ex := result.Exchange
+	if ex == nil {
+		t.Fatal("Expected the failed exchange to be captured")
+	}
+	if ex.Method != http.MethodGet || ex.URL != server.URL || ex.RequestHeaders.Get("User-Agent") != "Go-Load-Tester/1.0" {
+		t.Errorf("Request not captured correctly: %+v", ex)
+	}
+	if ex.Status != "503 Service Unavailable" || ex.ResponseHeaders.Get("X-Served-By") != "edge-1" || ex.Body != "try later" {
+		t.Errorf("Response not captured correctly: %+v", ex)
+	}
+
+	cfg.ExpectedStatus = http.StatusServiceUnavailable
+	if result := MakeRequest(cfg); result.Exchange != nil {
+		t.Error("Expected no exchange for a successful request")
+	}
+}
+
+func TestClient_MaxConnsSharedByWorkers(t *testing.T) {
+	var mu sync.Mutex
+	remotes := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr] = true
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    8,
+		MaxConns:       2,
+	}
+	c := NewClient(cfg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				c.MakeRequest(cfg)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(remotes) > 2 {
+		t.Errorf("Expected at most 2 connections, got %d", len(remotes))
+	}
+}
+
+func TestClient_ConnPerWorker(t *testing.T) {
+	var mu sync.Mutex
+	remotes := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remotes[r.RemoteAddr]++
+		mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    4,
+		ConnPerWorker:  true,
+	}
+	c := NewClient(cfg)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			cfg := cfg
+			cfg.Worker = worker
+			for j := 0; j < 5; j++ {
+				if result := c.MakeRequest(cfg); !result.Success {
+					t.Errorf("Worker %d: unexpected failure: %s", worker, result.ErrorMessage)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every worker kept to its own connection throughout
+	if len(remotes) != 4 {
+		t.Errorf("Expected exactly 4 connections, got %d", len(remotes))
+	}
+	for remote, requests := range remotes {
+		if requests != 5 {
+			t.Errorf("Expected 5 requests on each connection, got %d on %s", requests, remote)
+		}
+	}
+}
+
+func TestWithDialRetries(t *testing.T) {
+	calls := 0
+	flaky := func(ctx context.Context, network, address string) (net.Conn, error) {
+		calls++
+		if calls <= 2 {
+			return nil, &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}
+		}
+		client, _ := net.Pipe()
+		return client, nil
+	}
+
+	conn, err := withDialRetries(flaky, 2)(context.Background(), "tcp", "test:80")
+	if err != nil || conn == nil || calls != 3 {
+		t.Errorf("Expected success on the third connect, got %v after %d calls", err, calls)
+	}
+
+	calls = 0
+	if _, err := withDialRetries(flaky, 1)(context.Background(), "tcp", "test:80"); err == nil || calls != 2 {
+		t.Errorf("Expected failure after 2 connects, got %v after %d calls", err, calls)
+	}
+}
+
+func TestMakeRequest_Stream(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("data: hello\n\n"))
+		w.(http.Flusher).Flush()
+		// Never finish the response, like an event stream
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+		StreamBytes:    5,
+	}
+
+	start := time.Now()
+	result := MakeRequest(cfg)
+
+	if !result.Success {
+		t.Fatalf("Expected a started stream to succeed, got %s: %s", result.ErrorType, result.ErrorMessage)
+	}
+	if result.ResponseSize != 5 {
+		t.Errorf("Expected 5 bytes read, got %d", result.ResponseSize)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the stream to be closed after the first bytes, took %v", elapsed)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestMakeRequest_Transport(t *testing.T) {
+	var mu sync.Mutex
+	var signed []string
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		signed = append(signed, req.Method+" "+req.URL.String())
+		mu.Unlock()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Proto:      "HTTP/1.1",
+			Header:     http.Header{},
+			Body:       io.NopCloser(strings.NewReader("mocked")),
+			Request:    req,
+		}, nil
+	})
+
+	// Nothing listens here; every request must go through the transport
+	cfg := config.RequestConfig{
+		URL:            "http://unreachable.invalid/api",
+		Timeout:        time.Second,
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   "mocked",
+		Transport:      transport,
+	}
+	c := NewClient(cfg)
+	for i := 0; i < 2; i++ {
+		if result := c.MakeRequest(cfg); !result.Success {
+			t.Fatalf("Expected the mocked response to pass, got %s", result.ErrorMessage)
+		}
+	}
+	if result := MakeRequest(cfg); !result.Success {
+		t.Fatalf("Expected the package-level helper to use the transport too, got %s", result.ErrorMessage)
+	}
+	if len(signed) != 3 || signed[0] != "GET http://unreachable.invalid/api" {
+		t.Errorf("Expected 3 requests through the transport, got %v", signed)
+	}
+}
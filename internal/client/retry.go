@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// DefaultRetryOn are the error types retried when config.RetryOn is empty:
+// transient network failures, but not HTTP statuses or body mismatches, which
+// a retry would just repeat.
+var DefaultRetryOn = []errors.ErrorType{
+	errors.ErrorTypeDNS,
+	errors.ErrorTypeConnection,
+	errors.ErrorTypeTimeout,
+	errors.ErrorTypeNetwork,
+}
+
+// WithRetries wraps makeRequest so that a failed request is retried up to
+// config.Retries times when its error type is one of config.RetryOn. Retries
+// wait a jittered exponential backoff starting at config.RetryBackoff. The
+// returned result is that of the last attempt.
+func WithRetries(makeRequest func(config.RequestConfig) TestResult) func(config.RequestConfig) TestResult {
+	return func(cfg config.RequestConfig) TestResult {
+		retryOn := cfg.RetryOn
+		if len(retryOn) == 0 {
+			retryOn = DefaultRetryOn
+		}
+		ctx := cfg.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		result := makeRequest(cfg)
+		attempts := 1
+		for ; attempts <= cfg.Retries && !result.Success && slices.Contains(retryOn, result.ErrorType); attempts++ {
+			timer := time.NewTimer(backoff(cfg.RetryBackoff, attempts))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				result.Attempts = attempts
+				return result
+			}
+			result = makeRequest(cfg)
+		}
+		result.Attempts = attempts
+		return result
+	}
+}
+
+// backoff returns the wait before retry number attempt (from 1): a random
+// duration up to base doubled for each earlier retry ("full jitter"), so
+// workers that failed together don't retry in lockstep.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	max := base << (attempt - 1)
+	if max <= 0 || max > time.Minute {
+		max = time.Minute
+	}
+	return rand.N(max) + 1
+}
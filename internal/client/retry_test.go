@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+)
+
+// failingRequests fails with errorType for the first n calls, then succeeds.
+func failingRequests(n int, errorType errors.ErrorType, calls *int) func(config.RequestConfig) TestResult {
+	return func(config.RequestConfig) TestResult {
+		*calls++
+		if *calls <= n {
+			return TestResult{ErrorType: errorType}
+		}
+		return TestResult{Success: true, StatusCode: 200}
+	}
+}
+
+func TestWithRetries_RetriesTransientErrors(t *testing.T) {
+	var calls int
+	makeRequest := WithRetries(failingRequests(2, errors.ErrorTypeTimeout, &calls))
+
+	result := makeRequest(config.RequestConfig{Retries: 3, RetryBackoff: time.Millisecond})
+
+	if !result.Success || calls != 3 || result.Attempts != 3 {
+		t.Errorf("Expected success on the third attempt, got %+v after %d calls", result, calls)
+	}
+}
+
+func TestWithRetries_GivesUp(t *testing.T) {
+	var calls int
+	makeRequest := WithRetries(failingRequests(10, errors.ErrorTypeConnection, &calls))
+
+	result := makeRequest(config.RequestConfig{Retries: 2, RetryBackoff: time.Millisecond})
+
+	if result.Success || calls != 3 || result.Attempts != 3 {
+		t.Errorf("Expected failure after 3 attempts, got %+v after %d calls", result, calls)
+	}
+}
+
+func TestWithRetries_OnlyConfiguredTypes(t *testing.T) {
+	var calls int
+	makeRequest := WithRetries(failingRequests(1, errors.ErrorTypeClientError, &calls))
+
+	result := makeRequest(config.RequestConfig{Retries: 3})
+	if result.Success || calls != 1 {
+		t.Errorf("Expected a 4xx not to be retried by default, got %d calls", calls)
+	}
+
+	calls = 0
+	result = makeRequest(config.RequestConfig{Retries: 3, RetryOn: []errors.ErrorType{errors.ErrorTypeClientError}})
+	if !result.Success || calls != 2 {
+		t.Errorf("Expected a 4xx to be retried when listed, got %d calls", calls)
+	}
+}
+
+func TestWithRetries_StopsWhenCancelled(t *testing.T) {
+	var calls int
+	makeRequest := WithRetries(failingRequests(10, errors.ErrorTypeTimeout, &calls))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	makeRequest(config.RequestConfig{Context: ctx, Retries: 5, RetryBackoff: time.Hour})
+
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("Expected no retries after cancellation, got %d calls", calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	for attempt := 1; attempt <= 4; attempt++ {
+		max := 10 * time.Millisecond << (attempt - 1)
+		for i := 0; i < 50; i++ {
+			if d := backoff(10*time.Millisecond, attempt); d <= 0 || d > max {
+				t.Fatalf("attempt %d: backoff %v outside (0, %v]", attempt, d, max)
+			}
+		}
+	}
+	if d := backoff(0, 3); d != 0 {
+		t.Errorf("Expected no backoff with a zero base, got %v", d)
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"loadtester/internal/config"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// phaseTimes are the connection phase timestamps of a single request. Zero
+// values mean the phase did not happen (e.g. no DNS lookup for an IP URL).
+type phaseTimes struct {
+	dnsStart, dnsDone         time.Time
+	connectStart, connectDone time.Time
+	tlsStart, tlsDone         time.Time
+	wroteRequest, firstByte   time.Time
+
+	gotConn bool // a connection was obtained for the request
+	reused  bool // ...and it was an idle keep-alive connection
+
+	remoteAddr string // server IP the connection went to
+
+	connectWait time.Duration // dials held back by the connect rate limit
+}
+
+func (t phaseTimes) dnsTime() time.Duration {
+	return between(t.dnsStart, t.dnsDone)
+}
+
+func (t phaseTimes) connectTime() time.Duration {
+	return between(t.connectStart, t.connectDone)
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+// phases collects phaseTimes from httptrace callbacks, which can fire on
+// transport goroutines after the request has already returned.
+type phases struct {
+	mu    sync.Mutex
+	times phaseTimes
+}
+
+func (p *phases) mark(t *time.Time) {
+	now := time.Now()
+	p.mu.Lock()
+	*t = now
+	p.mu.Unlock()
+}
+
+func (p *phases) gotConn(info httptrace.GotConnInfo) {
+	p.mu.Lock()
+	p.times.gotConn = true
+	p.times.reused = info.Reused
+	if addr, ok := info.Conn.RemoteAddr().(*net.TCPAddr); ok {
+		p.times.remoteAddr = addr.IP.String()
+	} else {
+		p.times.remoteAddr = info.Conn.RemoteAddr().String()
+	}
+	p.mu.Unlock()
+}
+
+func (p *phases) addConnectWait(d time.Duration) {
+	p.mu.Lock()
+	p.times.connectWait += d
+	p.mu.Unlock()
+}
+
+// phasesKey carries a request's phases in its context, for the dialer, which
+// sees the request's context values but isn't covered by httptrace.
+type phasesKey struct{}
+
+func (p *phases) snapshot() phaseTimes {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.times
+}
+
+func (p *phases) clientTrace() *httptrace.ClientTrace {
+	t := &p.times
+	return &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { p.mark(&t.dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { p.mark(&t.dnsDone) },
+		ConnectStart:         func(string, string) { p.mark(&t.connectStart) },
+		ConnectDone:          func(string, string, error) { p.mark(&t.connectDone) },
+		TLSHandshakeStart:    func() { p.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { p.mark(&t.tlsDone) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { p.mark(&t.wroteRequest) },
+		GotConn:              p.gotConn,
+		GotFirstResponseByte: func() { p.mark(&t.firstByte) },
+	}
+}
+
+var tracer = otel.Tracer("loadtester/client")
+
+// startSpan starts the root span for a request. Without a configured tracer
+// provider this is a no-op.
+func startSpan(ctx context.Context, config config.RequestConfig) (context.Context, trace.Span) {
+	method := config.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	return tracer.Start(ctx, "HTTP "+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("http.request.method", method),
+			attribute.String("url.full", config.URL),
+		),
+	)
+}
+
+// injectTraceContext propagates the span to the server so load-test traffic
+// can be correlated with server-side traces.
+func injectTraceContext(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
+
+// endSpan records the outcome and, for sampled requests, child spans for
+// each connection phase that took place.
+func endSpan(span trace.Span, result TestResult, times phaseTimes) {
+	if !span.IsRecording() {
+		span.End()
+		return
+	}
+
+	ctx := trace.ContextWithSpan(context.Background(), span)
+	phases := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"dns", times.dnsStart, times.dnsDone},
+		{"connect", times.connectStart, times.connectDone},
+		{"tls", times.tlsStart, times.tlsDone},
+		{"ttfb", times.wroteRequest, times.firstByte},
+	}
+	for _, phase := range phases {
+		if phase.start.IsZero() || phase.end.IsZero() {
+			continue
+		}
+		_, child := tracer.Start(ctx, phase.name, trace.WithTimestamp(phase.start))
+		child.End(trace.WithTimestamp(phase.end))
+	}
+
+	if result.StatusCode > 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", result.StatusCode))
+	}
+	span.SetAttributes(attribute.Int64("http.response.body.size", result.ResponseSize))
+	if !result.Success {
+		span.SetAttributes(attribute.String("error.type", string(result.ErrorType)))
+		span.SetStatus(codes.Error, result.ErrorMessage)
+	}
+	span.End()
+}
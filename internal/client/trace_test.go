@@ -0,0 +1,67 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"loadtester/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMakeRequest_Tracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())))
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		URL:            server.URL,
+		Timeout:        2 * time.Second,
+		ExpectedStatus: http.StatusOK,
+		Concurrency:    1,
+	}
+
+	result := MakeRequest(cfg)
+	if !result.Success {
+		t.Fatalf("Expected success, got failure: %v", result.ErrorMessage)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	root, ok := spans["HTTP GET"]
+	if !ok {
+		t.Fatalf("Expected a root request span, got %v", spans)
+	}
+	for _, name := range []string{"connect", "ttfb"} {
+		child, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s child span", name)
+			continue
+		}
+		if child.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("Expected %s span to be a child of the request span", name)
+		}
+	}
+	if traceparent == "" {
+		t.Error("Expected traceparent header to be propagated to the server")
+	}
+}
@@ -1,11 +1,189 @@
 package config
 
-import "time"
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"loadtester/internal/errors"
+	"net/http"
+	"strings"
+	"time"
+)
 
-type RequestConfig struct {
+// DefaultMaxBodySize is the response body read limit used by the CLI.
+const DefaultMaxBodySize = 10 * 1024 * 1024
+
+// BodySource supplies a request body for each request, e.g. from a data file.
+type BodySource interface {
+	Next() (string, error)
+}
+
+// BodyValidator checks a response body beyond substring matching, e.g.
+// against a JSON Schema. It must be safe for concurrent use.
+type BodyValidator interface {
+	Validate(body string) error
+}
+
+// Header is a custom request header. Value is called for every request, so
+// it can produce a fresh value each time, e.g. a request ID.
+type Header struct {
+	Name  string
+	Value func() string
+}
+
+// Step is one request of a scenario. URL and Body are always the step's own,
+// an empty Body sending none; other empty fields fall back to the run's
+// settings, except ExpectedBody which is only checked when set.
+type Step struct {
+	Name           string
 	URL            string
+	Method         string
+	Body           string
 	ExpectedStatus int
 	ExpectedBody   string
-	Timeout        time.Duration
-	Concurrency    int
+	Tag            string        // groups steps for reporting, e.g. "checkout"; empty is untagged
+	Timeout        time.Duration // overrides the run's timeout when set
+	Headers        []Header      // sent in addition to the run's, replacing any of the same name
+	// Pause after the step's request before the next one; with ThinkTimeMax
+	// set the pause is drawn uniformly from [ThinkTime, ThinkTimeMax]
+	ThinkTime    time.Duration
+	ThinkTimeMax time.Duration
+	// Offset from the start of the iteration at which the step was recorded,
+	// honoured when RequestConfig.ReplayTiming is set
+	At time.Duration
+}
+
+// Stage is one phase of a multi-stage run, e.g. a ramp, a peak or a
+// cooldown. A zero Concurrency uses the run's; a zero Rate keeps every worker
+// busy for the whole Duration instead of pacing requests.
+type Stage struct {
+	Name        string
+	Concurrency int
+	Duration    time.Duration
+	Rate        float64 // requests per second spread evenly across Duration
+}
+
+type RequestConfig struct {
+	Context         context.Context // parent context for the request; nil means background
+	URL             string
+	Method          string
+	Host            string   // Host header to send instead of the URL's host
+	Headers         []Header // sent with every request, replacing any default header of the same name
+	Body            string
+	BodyFunc        func() io.Reader // called for a fresh body on every request; takes precedence over Body
+	ContentType     string           // Content-Type for request bodies; empty detects it, "none" sends none
+	CompressRequest bool             // gzip request bodies and send them with Content-Encoding: gzip
+	ExpectedStatus  int
+	ExpectedBody    string
+	ExpectedBodies  []string // further alternatives; a match on any body counts
+	BodyNotContains string
+	AllowRedirects  bool          // count 3xx responses as successes instead of following them
+	BodyValidator   BodyValidator // further check of the response body; nil disables
+	MinResponseSize int64
+	MaxLatency      time.Duration // responses slower than this fail with a Slow Response error (zero disables)
+	Timeout         time.Duration
+	Retries         int                // extra attempts for a failed request (zero disables)
+	RetryOn         []errors.ErrorType // error types worth retrying; empty uses the client defaults
+	RetryBackoff    time.Duration      // base of the jittered exponential backoff between attempts
+	AdaptiveTimeout float64            // multiple of the warm-up P99 to time out at (zero disables)
+	AdaptiveWarmup  int                // successful responses to observe before adapting
+	Concurrency     int
+	MaxIdleConns    int           // idle keep-alive connections kept per host; zero matches Concurrency
+	MaxConns        int           // simultaneous connections per host; workers beyond it queue (zero is unlimited)
+	ConnPerWorker   bool          // give each worker one connection per host of its own instead of a shared pool
+	Worker          int           // index of the worker sending the request, set by the runner
+	DialRetries     int           // extra TCP connect attempts before a connection error is reported
+	ConnectRate     float64       // new TCP connections per second across the run (zero is unlimited)
+	Duration        time.Duration // run for this long instead of a fixed request count
+	WarmupDuration  time.Duration // discard results completed this early in the run (zero disables)
+	// Randomize the gaps between paced requests around their mean: by up to
+	// this fraction either way (0.2 is ±20%), or drawn from an exponential
+	// distribution with PoissonArrivals, as for independent users
+	RateJitter      float64
+	PoissonArrivals bool
+	Seed            uint64        // seeds the random choices of the run, so they repeat
+	ReportInterval  time.Duration // print an interim summary this often (zero disables)
+	NoProgress      bool          // suppress the progress lines printed while the run goes
+	MaxDuration     time.Duration // hard cap on the whole run in any mode (zero disables)
+	DrainTimeout    time.Duration // after a stop, let in-flight requests finish for up to this long (zero aborts them at once)
+	TargetSuccesses int           // keep sending until this many requests succeed (zero disables)
+	UntilErrors     int           // keep sending until this many requests fail (zero disables)
+	MaxRPS          float64       // ceiling on requests sent per second across all workers (zero disables)
+	DNSServer       string
+	DNSRoundRobin   int           // resolve each host this many times and dial its addresses in turn (zero disables)
+	TLS             *tls.Config   // client certificates and root CAs; nil uses the defaults
+	CertExpiryWarn  time.Duration // warn when the server certificate expires within this window (zero disables)
+	ExcludeFirst    bool          // leave the first request's cold-start latency out of the latency stats
+	LatencyTarget   time.Duration
+	ApdexTarget     time.Duration
+	BodySource      BodySource
+	CacheBustParam  string         // query parameter given a unique value per request
+	CacheHeader     string         // response header whose values are tallied, e.g. X-Cache
+	SizeHistogram   bool           // bucket response sizes into a histogram
+	Steps           []Step         // scenario run in order by each iteration instead of URL
+	ReplayTiming    bool           // send each step at its At offset instead of straight after the one before
+	Jar             http.CookieJar // cookies shared by the steps of one iteration
+	MaxBodySize     int64          // zero reads the whole body
+	DiscardBody     bool           // count response bytes without buffering them
+	StreamBytes     int64          // stop reading after this many bytes and close the stream; zero reads normally
+	CaptureFailures bool           // attach the request/response exchange to failed results
+
+	// Transport sends every request in place of the transport the client
+	// would build, e.g. to mock the target, instrument requests or sign them
+	// when embedding the tester. One transport is shared by all requests, so
+	// it should pool connections itself; the DNS, dial, TLS and connection
+	// pool settings above don't apply to it (nil builds the default)
+	Transport http.RoundTripper
+}
+
+// DefaultStatus returns the status expected from method when none is set
+// explicitly: 201 Created for POST, 204 No Content for DELETE and 200 OK for
+// everything else.
+func DefaultStatus(method string) int {
+	switch strings.ToUpper(method) {
+	case http.MethodPost:
+		return http.StatusCreated
+	case http.MethodDelete:
+		return http.StatusNoContent
+	default:
+		return http.StatusOK
+	}
+}
+
+// WithDefaults returns c with a zero ExpectedStatus replaced by the default
+// for its method. Steps without a status of their own inherit an explicit
+// ExpectedStatus, or else get the default for the method they are sent with:
+// their own, or the run's when they have none.
+func (c RequestConfig) WithDefaults() RequestConfig {
+	if c.Steps != nil {
+		steps := make([]Step, len(c.Steps))
+		for i, step := range c.Steps {
+			if step.ExpectedStatus == 0 {
+				step.ExpectedStatus = c.ExpectedStatus
+			}
+			if step.ExpectedStatus == 0 {
+				method := step.Method
+				if method == "" {
+					method = c.Method
+				}
+				step.ExpectedStatus = DefaultStatus(method)
+			}
+			steps[i] = step
+		}
+		c.Steps = steps
+	}
+	if c.ExpectedStatus == 0 {
+		c.ExpectedStatus = DefaultStatus(c.Method)
+	}
+	return c
+}
+
+// AcceptedBodies returns every body substring a response may match, or nil
+// when body validation is disabled.
+func (c RequestConfig) AcceptedBodies() []string {
+	var bodies []string
+	if c.ExpectedBody != "" {
+		bodies = append(bodies, c.ExpectedBody)
+	}
+	return append(bodies, c.ExpectedBodies...)
 }
@@ -0,0 +1,53 @@
+package config
+
+import "testing"
+
+func TestDefaultStatus(t *testing.T) {
+	cases := map[string]int{
+		"GET":    200,
+		"post":   201,
+		"DELETE": 204,
+		"PUT":    200,
+		"":       200,
+	}
+	for method, want := range cases {
+		if got := DefaultStatus(method); got != want {
+			t.Errorf("DefaultStatus(%q) = %d, want %d", method, got, want)
+		}
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	cfg := RequestConfig{
+		Method: "POST",
+		Steps: []Step{
+			{Name: "create", Method: "POST"},
+			{Name: "remove", Method: "DELETE"},
+			{Name: "fetch", Method: "GET", ExpectedStatus: 404},
+			{Name: "submit"},
+		},
+	}
+
+	resolved := cfg.WithDefaults()
+
+	if resolved.ExpectedStatus != 201 {
+		t.Errorf("Expected POST to default to 201, got %d", resolved.ExpectedStatus)
+	}
+	// A step without a method is sent with the run's POST
+	want := []int{201, 204, 404, 201}
+	for i, step := range resolved.Steps {
+		if step.ExpectedStatus != want[i] {
+			t.Errorf("Step %q: expected status %d, got %d", step.Name, want[i], step.ExpectedStatus)
+		}
+	}
+	if cfg.Steps[0].ExpectedStatus != 0 {
+		t.Error("Expected WithDefaults not to modify the original steps")
+	}
+
+	// An explicit run-wide status still applies to steps without their own
+	cfg.ExpectedStatus = 200
+	resolved = cfg.WithDefaults()
+	if resolved.ExpectedStatus != 200 || resolved.Steps[1].ExpectedStatus != 200 || resolved.Steps[2].ExpectedStatus != 404 {
+		t.Errorf("Expected explicit statuses to be honoured, got %d and steps %+v", resolved.ExpectedStatus, resolved.Steps)
+	}
+}
@@ -0,0 +1,120 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"loadtester/internal/tmpl"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// CSVSource renders request bodies from a template, filling in one row of a
+// CSV file per call to Next and starting over from the first row once the
+// file is exhausted. The first row names the columns, which the template
+// refers to as {{.name}}. Like Source, the file is streamed rather than
+// loaded into memory.
+type CSVSource struct {
+	mu      sync.Mutex
+	path    string
+	tmpl    *template.Template
+	file    *os.File
+	reader  *csv.Reader
+	columns []string
+}
+
+// OpenCSV opens path and checks that it has a header and at least one row,
+// and that the template renders with it, so unknown columns are reported up
+// front rather than per request.
+func OpenCSV(path, body string) (*CSVSource, error) {
+	t, err := template.New("").Funcs(tmpl.Funcs()).Option("missingkey=error").Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+	s := &CSVSource{path: path, tmpl: t}
+	if err := s.rewind(); err != nil {
+		return nil, err
+	}
+	if _, err := s.next(); err != nil {
+		s.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("CSV file %s contains no rows", path)
+		}
+		return nil, err
+	}
+	if err := s.rewind(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next returns the body rendered from the next row, wrapping around at the
+// end of the file. It is safe for concurrent use.
+func (s *CSVSource) Next() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, err := s.next()
+	if err == io.EOF {
+		if err := s.rewind(); err != nil {
+			return "", err
+		}
+		body, err = s.next()
+	}
+	return body, err
+}
+
+// Close releases the underlying file.
+func (s *CSVSource) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func (s *CSVSource) rewind() error {
+	if s.file != nil {
+		s.file.Close()
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("opening CSV file: %w", err)
+	}
+	s.file = f
+	s.reader = csv.NewReader(f)
+	s.reader.ReuseRecord = true
+
+	header, err := s.reader.Read()
+	if err == io.EOF {
+		return fmt.Errorf("CSV file %s has no header row", s.path)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing CSV file %s: %w", s.path, err)
+	}
+	s.columns = make([]string, len(header))
+	for i, name := range header {
+		s.columns[i] = strings.TrimSpace(name)
+	}
+	return nil
+}
+
+func (s *CSVSource) next() (string, error) {
+	record, err := s.reader.Read()
+	if err == io.EOF {
+		return "", io.EOF
+	}
+	if err != nil {
+		return "", fmt.Errorf("parsing CSV file %s: %w", s.path, err)
+	}
+	row := make(map[string]string, len(s.columns))
+	for i, name := range s.columns {
+		row[name] = record[i]
+	}
+	var b strings.Builder
+	if err := s.tmpl.Execute(&b, row); err != nil {
+		return "", fmt.Errorf("rendering body template: %w", err)
+	}
+	return b.String(), nil
+}
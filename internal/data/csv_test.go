@@ -0,0 +1,58 @@
+package data
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVSource_RowsCycle(t *testing.T) {
+	src, err := OpenCSV(testutil.WriteFile(t, "data", "name, age\nalice,30\n\n\"bob, jr\",41\r\n"), `{"name":"{{.name}}","age":{{.age}}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer src.Close()
+
+	want := []string{`{"name":"alice","age":30}`, `{"name":"bob, jr","age":41}`, `{"name":"alice","age":30}`}
+	for i, w := range want {
+		got, err := src.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error on body %d: %v", i, err)
+		}
+		if got != w {
+			t.Errorf("Body %d: expected %q, got %q", i, w, got)
+		}
+	}
+}
+
+func TestCSVSource_Placeholders(t *testing.T) {
+	src, err := OpenCSV(testutil.WriteFile(t, "data", "id\n7\n"), `{{.id}}-{{uuid}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer src.Close()
+
+	first, _ := src.Next()
+	second, _ := src.Next()
+	if len(first) != len("7-")+36 || first == second {
+		t.Errorf("Expected a fresh UUID per body, got %q and %q", first, second)
+	}
+}
+
+func TestOpenCSV_Invalid(t *testing.T) {
+	cases := map[string]struct{ content, body string }{
+		"empty file":     {"", "{{.id}}"},
+		"header only":    {"id\n", "{{.id}}"},
+		"unknown column": {"id\n1\n", "{{.name}}"},
+		"bad template":   {"id\n1\n", "{{.id"},
+		"ragged row":     {"id,name\n1\n", "{{.id}}"},
+	}
+	for name, c := range cases {
+		if _, err := OpenCSV(testutil.WriteFile(t, "data", c.content), c.body); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := OpenCSV(filepath.Join(t.TempDir(), "missing.csv"), "{{.id}}"); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
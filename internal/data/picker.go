@@ -0,0 +1,50 @@
+package data
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Picker hands out lines of a file at random, e.g. User-Agent strings. The
+// choices are drawn from a seeded source, so the same seed repeats them.
+type Picker struct {
+	mu    sync.Mutex
+	rng   *rand.Rand
+	lines []string
+}
+
+// OpenPicker reads the non-empty lines of path, skipping lines starting with
+// '#', and checks that there is at least one.
+func OpenPicker(path string, seed uint64) (*Picker, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading list: %w", err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("list %s contains no entries", path)
+	}
+	return &Picker{rng: rand.New(rand.NewPCG(seed, 0)), lines: lines}, nil
+}
+
+// Pick returns a line chosen uniformly at random. It is safe for concurrent
+// use.
+func (p *Picker) Pick() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lines[p.rng.IntN(len(p.lines))]
+}
+
+// Len returns the number of lines to pick from.
+func (p *Picker) Len() int {
+	return len(p.lines)
+}
@@ -0,0 +1,42 @@
+package data
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestPicker(t *testing.T) {
+	path := testutil.WriteFile(t, "data", "# desktop\nMozilla/5.0 (Windows NT 10.0)\r\n\nMozilla/5.0 (Macintosh)\n  curl/8.0  \n")
+	p, err := OpenPicker(path, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Len() != 3 {
+		t.Fatalf("Expected 3 entries without comments and blank lines, got %d", p.Len())
+	}
+
+	again, _ := OpenPicker(path, 1)
+	seen := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		line := p.Pick()
+		if line != again.Pick() {
+			t.Fatal("Expected the same seed to pick the same lines")
+		}
+		seen[line]++
+	}
+	for _, line := range []string{"Mozilla/5.0 (Windows NT 10.0)", "Mozilla/5.0 (Macintosh)", "curl/8.0"} {
+		if seen[line] == 0 {
+			t.Errorf("Expected %q to be picked, got %v", line, seen)
+		}
+	}
+}
+
+func TestOpenPicker_Invalid(t *testing.T) {
+	if _, err := OpenPicker(testutil.WriteFile(t, "data", "# only a comment\n\n"), 1); err == nil {
+		t.Error("Expected error for a list without entries")
+	}
+	if _, err := OpenPicker(filepath.Join(t.TempDir(), "missing.txt"), 1); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
@@ -0,0 +1,21 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"math/rand/v2"
+)
+
+// RandomBody returns a body generator for requests carrying size bytes of
+// random data. The data is generated once and shared: each call returns a
+// fresh reader over the same read-only buffer, so concurrent requests never
+// interfere. Random bytes don't compress, so the full size goes over the wire.
+func RandomBody(size int64) func() io.Reader {
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = byte(rand.N(256))
+	}
+	return func() io.Reader {
+		return bytes.NewReader(buf)
+	}
+}
@@ -0,0 +1,35 @@
+package data
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestRandomBody(t *testing.T) {
+	body := RandomBody(1024)
+
+	var wg sync.WaitGroup
+	reads := make([][]byte, 4)
+	for i := range reads {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reads[i], _ = io.ReadAll(body())
+		}()
+	}
+	wg.Wait()
+
+	for i, read := range reads {
+		if len(read) != 1024 {
+			t.Errorf("Read %d: expected 1024 bytes, got %d", i, len(read))
+		}
+		if !bytes.Equal(read, reads[0]) {
+			t.Errorf("Read %d: expected every reader to see the same data", i)
+		}
+	}
+	if bytes.Equal(reads[0], make([]byte, 1024)) {
+		t.Error("Expected random data, got all zeros")
+	}
+}
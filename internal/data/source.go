@@ -0,0 +1,139 @@
+package data
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Source streams request bodies from a file, one per call to Next, starting
+// over from the top once the file is exhausted. The file is either a JSON
+// array (each element is one body) or plain text (each non-empty line is one
+// body). Only the current position is held in memory, so files larger than
+// memory are fine.
+type Source struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	isJSON bool
+	reader *bufio.Reader
+	dec    *json.Decoder
+}
+
+// Open opens path and checks that it contains at least one body.
+func Open(path string) (*Source, error) {
+	s := &Source{path: path}
+	if err := s.rewind(); err != nil {
+		return nil, err
+	}
+	if _, err := s.next(); err != nil {
+		s.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("data file %s contains no bodies", path)
+		}
+		return nil, err
+	}
+	if err := s.rewind(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Next returns the next body, wrapping around at the end of the file. It is
+// safe for concurrent use.
+func (s *Source) Next() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, err := s.next()
+	if err == io.EOF {
+		if err := s.rewind(); err != nil {
+			return "", err
+		}
+		body, err = s.next()
+	}
+	return body, err
+}
+
+// Close releases the underlying file.
+func (s *Source) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func (s *Source) rewind() error {
+	if s.file != nil {
+		s.file.Close()
+	}
+	f, err := os.Open(s.path)
+	if err != nil {
+		return fmt.Errorf("opening data file: %w", err)
+	}
+	s.file = f
+	s.reader = bufio.NewReader(f)
+	s.dec = nil
+
+	// A leading '[' means the file is a JSON array of bodies
+	for {
+		b, err := s.reader.ReadByte()
+		if err == io.EOF {
+			s.isJSON = false
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading data file: %w", err)
+		}
+		if strings.IndexByte(" \t\r\n", b) >= 0 {
+			continue
+		}
+		s.reader.UnreadByte()
+		s.isJSON = b == '['
+		break
+	}
+
+	if s.isJSON {
+		s.dec = json.NewDecoder(s.reader)
+		if _, err := s.dec.Token(); err != nil {
+			return fmt.Errorf("parsing data file %s: %w", s.path, err)
+		}
+	}
+	return nil
+}
+
+func (s *Source) next() (string, error) {
+	if s.isJSON {
+		if !s.dec.More() {
+			return "", io.EOF
+		}
+		var raw json.RawMessage
+		if err := s.dec.Decode(&raw); err != nil {
+			return "", fmt.Errorf("parsing data file %s: %w", s.path, err)
+		}
+		// String elements are sent as their contents, anything else verbatim
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			return str, nil
+		}
+		return string(raw), nil
+	}
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			return line, nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return "", io.EOF
+			}
+			return "", fmt.Errorf("reading data file: %w", err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource_LinesCycle(t *testing.T) {
+	src, err := Open(testutil.WriteFile(t, "data", "one\n\ntwo\r\nthree"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer src.Close()
+
+	want := []string{"one", "two", "three", "one", "two"}
+	for i, w := range want {
+		got, err := src.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error on body %d: %v", i, err)
+		}
+		if got != w {
+			t.Errorf("Body %d: expected %q, got %q", i, w, got)
+		}
+	}
+}
+
+func TestSource_JSONArray(t *testing.T) {
+	src, err := Open(testutil.WriteFile(t, "data", ` [{"id": 1}, "plain", {"id": 2}]`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer src.Close()
+
+	want := []string{`{"id": 1}`, "plain", `{"id": 2}`, `{"id": 1}`}
+	for i, w := range want {
+		got, err := src.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error on body %d: %v", i, err)
+		}
+		if got != w {
+			t.Errorf("Body %d: expected %q, got %q", i, w, got)
+		}
+	}
+}
+
+func TestOpen_Empty(t *testing.T) {
+	if _, err := Open(testutil.WriteFile(t, "data", "\n\n")); err == nil {
+		t.Error("Expected error for data file without bodies")
+	}
+	if _, err := Open(testutil.WriteFile(t, "data", "[]")); err == nil {
+		t.Error("Expected error for empty JSON array")
+	}
+}
+
+func TestOpen_Missing(t *testing.T) {
+	if _, err := Open(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for missing data file")
+	}
+}
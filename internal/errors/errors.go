@@ -2,10 +2,13 @@ package errors
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
 	"net"
+	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type ErrorType string
@@ -20,13 +23,89 @@ const (
 	ErrorTypeNetwork        ErrorType = "Network"
 	ErrorTypeServerError    ErrorType = "Server Error"
 	ErrorTypeClientError    ErrorType = "Client Error"
+	ErrorTypeRateLimited    ErrorType = "Rate Limited"
 	ErrorTypeRedirect       ErrorType = "Redirect"
 	ErrorTypeHTTPStatus     ErrorType = "HTTP Status"
 	ErrorTypeBodyValidation ErrorType = "Body Validation"
+	ErrorTypeDataSource     ErrorType = "Data Source"
+	ErrorTypeSlowResponse   ErrorType = "Slow Response"
+	ErrorTypeAbandoned      ErrorType = "Abandoned"
+	// ErrorTypeAborted marks a request cut short because the run was
+	// stopped, not a failure of the target; it is left out of the stats
+	ErrorTypeAborted ErrorType = "Aborted"
 )
 
-func CategorizeError(err error, statusCode int, expectedStatus int, expectedBody, responseBody string) (ErrorType, string) {
+// codes are the stable machine identifiers for each error type. Display
+// names may change; codes are part of the JSON output contract and must not.
+var codes = map[ErrorType]string{
+	ErrorTypeNone:           "",
+	ErrorTypeDNS:            "dns",
+	ErrorTypeConnection:     "connection",
+	ErrorTypeTimeout:        "timeout",
+	ErrorTypeTLS:            "tls",
+	ErrorTypeURL:            "url",
+	ErrorTypeNetwork:        "network",
+	ErrorTypeServerError:    "server_error",
+	ErrorTypeClientError:    "client_error",
+	ErrorTypeRateLimited:    "rate_limited",
+	ErrorTypeRedirect:       "redirect",
+	ErrorTypeHTTPStatus:     "http_status",
+	ErrorTypeBodyValidation: "body_validation",
+	ErrorTypeDataSource:     "data_source",
+	ErrorTypeSlowResponse:   "slow_response",
+	ErrorTypeAbandoned:      "abandoned",
+	ErrorTypeAborted:        "aborted",
+}
+
+// Code returns the stable machine-readable identifier of t, e.g.
+// "server_error". Automation should key off codes, not display names.
+func (t ErrorType) Code() string {
+	if code, ok := codes[t]; ok {
+		return code
+	}
+	return strings.ReplaceAll(strings.ToLower(string(t)), " ", "_")
+}
+
+// ParseCode returns the error type with the given machine code, as returned
+// by Code.
+func ParseCode(code string) (ErrorType, bool) {
+	for errorType, c := range codes {
+		if c == code && c != "" {
+			return errorType, true
+		}
+	}
+	return ErrorTypeNone, false
+}
+
+// Response is what was observed for a request that got an HTTP response.
+type Response struct {
+	StatusCode int
+	Body       string
+	Size       int64         // bytes read, which may exceed len(Body) when the body is discarded
+	Latency    time.Duration // measured response time
+}
+
+// Expectations are the checks a response must pass to count as a success.
+type Expectations struct {
+	Status          int
+	Bodies          []string      // the response body must contain at least one of these
+	BodyNotContains string        // must not appear in the response body
+	MinSize         int64         // minimum response size in bytes (zero disables)
+	AllowRedirects  bool          // any 3xx passes, skipping the status and body checks
+	MaxLatency      time.Duration // slower responses fail even if otherwise valid (zero disables)
+	// Validate checks the response body further, e.g. against a JSON Schema
+	// (nil disables)
+	Validate func(body string) error
+}
+
+func CategorizeError(err error, resp Response, expect Expectations) (ErrorType, string) {
+	statusCode := resp.StatusCode
 	if err != nil {
+		// Only stopping the run cancels a request; timeouts exceed a deadline
+		if stderrors.Is(err, context.Canceled) {
+			return ErrorTypeAborted, "Request aborted: the run was stopped"
+		}
+
 		// Network-level errors
 		if netErr, ok := err.(net.Error); ok {
 			if netErr.Timeout() {
@@ -57,7 +136,7 @@ func CategorizeError(err error, statusCode int, expectedStatus int, expectedBody
 		}
 
 		// Context cancellation (timeouts we set)
-		if err == context.DeadlineExceeded {
+		if stderrors.Is(err, context.DeadlineExceeded) {
 			return ErrorTypeTimeout, "Request deadline exceeded"
 		}
 
@@ -65,23 +144,63 @@ func CategorizeError(err error, statusCode int, expectedStatus int, expectedBody
 		return ErrorTypeNetwork, fmt.Sprintf("Network error: %v", err)
 	}
 
+	// The redirect itself is what's under test, its body is incidental
+	if expect.AllowRedirects && statusCode >= 300 && statusCode < 400 {
+		return ErrorTypeNone, ""
+	}
+
 	// HTTP-level errors (got response but wrong status)
-	if statusCode != expectedStatus {
+	if statusCode != expect.Status {
 		if statusCode >= 500 {
 			return ErrorTypeServerError, fmt.Sprintf("Server error (HTTP %d)", statusCode)
+		} else if statusCode == http.StatusTooManyRequests {
+			// Throttling is a capacity signal, not a broken request
+			return ErrorTypeRateLimited, "Rate limited (HTTP 429)"
 		} else if statusCode >= 400 {
 			return ErrorTypeClientError, fmt.Sprintf("Client error (HTTP %d)", statusCode)
 		} else if statusCode >= 300 {
 			return ErrorTypeRedirect, fmt.Sprintf("Unexpected redirect (HTTP %d)", statusCode)
+		} else if statusCode >= 100 && statusCode < 200 {
+			// The client skips interim 1xx responses (100 Continue, 103 Early
+			// Hints), so a 1xx here was final, e.g. 101 Switching Protocols
+			return ErrorTypeHTTPStatus, fmt.Sprintf("Unexpected informational response (HTTP %d, expected %d)", statusCode, expect.Status)
 		} else {
-			return ErrorTypeHTTPStatus, fmt.Sprintf("Unexpected status code: %d (expected %d)", statusCode, expectedStatus)
+			return ErrorTypeHTTPStatus, fmt.Sprintf("Unexpected status code: %d (expected %d)", statusCode, expect.Status)
 		}
 	}
 
 	// Body validation errors
-	if expectedBody != "" && !strings.Contains(responseBody, expectedBody) {
-		return ErrorTypeBodyValidation, fmt.Sprintf("Response body doesn't contain expected text: '%s'", expectedBody)
+	if expect.MinSize > 0 && resp.Size < expect.MinSize {
+		return ErrorTypeBodyValidation, fmt.Sprintf("Response body too small: %d bytes (expected at least %d)", resp.Size, expect.MinSize)
+	}
+	if len(expect.Bodies) > 0 && !containsAny(resp.Body, expect.Bodies) {
+		if len(expect.Bodies) == 1 {
+			return ErrorTypeBodyValidation, fmt.Sprintf("Response body doesn't contain expected text: '%s'", expect.Bodies[0])
+		}
+		return ErrorTypeBodyValidation, fmt.Sprintf("Response body doesn't contain any of the expected texts: '%s'", strings.Join(expect.Bodies, "', '"))
+	}
+	if expect.BodyNotContains != "" && strings.Contains(resp.Body, expect.BodyNotContains) {
+		return ErrorTypeBodyValidation, fmt.Sprintf("Response body should not contain text: '%s'", expect.BodyNotContains)
+	}
+	if expect.Validate != nil {
+		if err := expect.Validate(resp.Body); err != nil {
+			return ErrorTypeBodyValidation, fmt.Sprintf("Response body failed validation: %v", err)
+		}
+	}
+
+	// Latency SLA, checked last so a wrong response is reported as such
+	if expect.MaxLatency > 0 && resp.Latency > expect.MaxLatency {
+		return ErrorTypeSlowResponse, fmt.Sprintf("Response too slow: %v (max %v)", resp.Latency.Round(time.Millisecond), expect.MaxLatency)
 	}
 
 	return ErrorTypeNone, "" // No error
 }
+
+func containsAny(s string, substrs []string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,11 +6,12 @@ import (
 	"net"
 	"net/url"
 	"testing"
+	"time"
 )
 
 func TestCategorizeError_Timeout(t *testing.T) {
 	err := context.DeadlineExceeded
-	etype, msg := CategorizeError(err, 200, 200, "", "")
+	etype, msg := CategorizeError(err, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeTimeout {
 		t.Errorf("Expected Timeout, got %v", etype)
 	}
@@ -19,9 +20,31 @@ func TestCategorizeError_Timeout(t *testing.T) {
 	}
 }
 
+func TestCategorizeError_ContextErrors(t *testing.T) {
+	// As returned by http.Client.Do when the request's context ends
+	wrap := func(err error) error { return &url.Error{Op: "Get", URL: "http://test", Err: err} }
+	cases := []struct {
+		err  error
+		want ErrorType
+	}{
+		{context.Canceled, ErrorTypeAborted},
+		{wrap(context.Canceled), ErrorTypeAborted},
+		{context.DeadlineExceeded, ErrorTypeTimeout},
+		{wrap(context.DeadlineExceeded), ErrorTypeTimeout},
+	}
+	for _, c := range cases {
+		if etype, _ := CategorizeError(c.err, Response{}, Expectations{Status: 200}); etype != c.want {
+			t.Errorf("%v: expected %v, got %v", c.err, c.want, etype)
+		}
+	}
+	if ErrorTypeAborted.Code() != "aborted" {
+		t.Errorf("Expected code aborted, got %q", ErrorTypeAborted.Code())
+	}
+}
+
 func TestCategorizeError_DNS(t *testing.T) {
 	dnsErr := &net.DNSError{Err: "no such host"}
-	etype, msg := CategorizeError(dnsErr, 200, 200, "", "")
+	etype, msg := CategorizeError(dnsErr, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeDNS {
 		t.Errorf("Expected DNS, got %v", etype)
 	}
@@ -32,7 +55,7 @@ func TestCategorizeError_DNS(t *testing.T) {
 
 func TestCategorizeError_Connection(t *testing.T) {
 	opErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
-	etype, msg := CategorizeError(opErr, 200, 200, "", "")
+	etype, msg := CategorizeError(opErr, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeConnection {
 		t.Errorf("Expected Connection, got %v", etype)
 	}
@@ -43,7 +66,7 @@ func TestCategorizeError_Connection(t *testing.T) {
 
 func TestCategorizeError_URL(t *testing.T) {
 	urlErr := &url.Error{Op: "parse", URL: ":://bad-url", Err: errors.New("invalid URL")}
-	etype, msg := CategorizeError(urlErr, 200, 200, "", "")
+	etype, msg := CategorizeError(urlErr, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeURL {
 		t.Errorf("Expected URL, got %v", etype)
 	}
@@ -54,7 +77,7 @@ func TestCategorizeError_URL(t *testing.T) {
 
 func TestCategorizeError_TLS(t *testing.T) {
 	tlsErr := errors.New("tls: handshake failure")
-	etype, msg := CategorizeError(tlsErr, 200, 200, "", "")
+	etype, msg := CategorizeError(tlsErr, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeTLS {
 		t.Errorf("Expected TLS, got %v", etype)
 	}
@@ -65,7 +88,7 @@ func TestCategorizeError_TLS(t *testing.T) {
 
 func TestCategorizeError_Network(t *testing.T) {
 	netErr := errors.New("some network error")
-	etype, msg := CategorizeError(netErr, 200, 200, "", "")
+	etype, msg := CategorizeError(netErr, Response{}, Expectations{Status: 200})
 	if etype != ErrorTypeNetwork {
 		t.Errorf("Expected Network, got %v", etype)
 	}
@@ -75,7 +98,7 @@ func TestCategorizeError_Network(t *testing.T) {
 }
 
 func TestCategorizeError_ServerError(t *testing.T) {
-	etype, msg := CategorizeError(nil, 500, 200, "", "")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 500}, Expectations{Status: 200})
 	if etype != ErrorTypeServerError {
 		t.Errorf("Expected Server Error, got %v", etype)
 	}
@@ -85,7 +108,7 @@ func TestCategorizeError_ServerError(t *testing.T) {
 }
 
 func TestCategorizeError_ClientError(t *testing.T) {
-	etype, msg := CategorizeError(nil, 404, 200, "", "")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 404}, Expectations{Status: 200})
 	if etype != ErrorTypeClientError {
 		t.Errorf("Expected Client Error, got %v", etype)
 	}
@@ -94,8 +117,21 @@ func TestCategorizeError_ClientError(t *testing.T) {
 	}
 }
 
+func TestCategorizeError_RateLimited(t *testing.T) {
+	etype, msg := CategorizeError(nil, Response{StatusCode: 429}, Expectations{Status: 200})
+	if etype != ErrorTypeRateLimited {
+		t.Errorf("Expected Rate Limited, got %v", etype)
+	}
+	if msg != "Rate limited (HTTP 429)" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 429}, Expectations{Status: 429}); etype != ErrorTypeNone {
+		t.Errorf("Expected None when 429 is the expected status, got %v", etype)
+	}
+}
+
 func TestCategorizeError_Redirect(t *testing.T) {
-	etype, msg := CategorizeError(nil, 302, 200, "", "")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 302}, Expectations{Status: 200})
 	if etype != ErrorTypeRedirect {
 		t.Errorf("Expected Redirect, got %v", etype)
 	}
@@ -104,8 +140,20 @@ func TestCategorizeError_Redirect(t *testing.T) {
 	}
 }
 
+func TestCategorizeError_AllowRedirects(t *testing.T) {
+	expect := Expectations{Status: 200, Bodies: []string{"welcome"}, AllowRedirects: true}
+	for _, status := range []int{301, 302, 307, 308} {
+		if etype, msg := CategorizeError(nil, Response{StatusCode: status}, expect); etype != ErrorTypeNone {
+			t.Errorf("Expected HTTP %d to pass with redirects allowed, got %v: %s", status, etype, msg)
+		}
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 404}, expect); etype != ErrorTypeClientError {
+		t.Errorf("Expected other statuses to still be checked, got %v", etype)
+	}
+}
+
 func TestCategorizeError_HTTPStatus(t *testing.T) {
-	etype, msg := CategorizeError(nil, 201, 200, "", "")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 201}, Expectations{Status: 200})
 	if etype != ErrorTypeHTTPStatus {
 		t.Errorf("Expected HTTP Status, got %v", etype)
 	}
@@ -115,7 +163,7 @@ func TestCategorizeError_HTTPStatus(t *testing.T) {
 }
 
 func TestCategorizeError_BodyValidation(t *testing.T) {
-	etype, msg := CategorizeError(nil, 200, 200, "expected", "not present")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Body: "not present"}, Expectations{Status: 200, Bodies: []string{"expected"}})
 	if etype != ErrorTypeBodyValidation {
 		t.Errorf("Expected Body Validation, got %v", etype)
 	}
@@ -125,7 +173,7 @@ func TestCategorizeError_BodyValidation(t *testing.T) {
 }
 
 func TestCategorizeError_None(t *testing.T) {
-	etype, msg := CategorizeError(nil, 200, 200, "", "")
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200}, Expectations{Status: 200})
 	if etype != ErrorTypeNone {
 		t.Errorf("Expected None, got %v", etype)
 	}
@@ -133,3 +181,141 @@ func TestCategorizeError_None(t *testing.T) {
 		t.Errorf("Expected empty error message, got %v", msg)
 	}
 }
+
+func TestCategorizeError_BodyNotContains(t *testing.T) {
+	expect := Expectations{Status: 200, BodyNotContains: "stack trace"}
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Body: "error: stack trace follows"}, expect)
+	if etype != ErrorTypeBodyValidation {
+		t.Errorf("Expected Body Validation, got %v", etype)
+	}
+	if msg != "Response body should not contain text: 'stack trace'" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+
+	etype, _ = CategorizeError(nil, Response{StatusCode: 200, Body: "all good"}, expect)
+	if etype != ErrorTypeNone {
+		t.Errorf("Expected None when forbidden text is absent, got %v", etype)
+	}
+}
+
+func TestCategorizeError_Validate(t *testing.T) {
+	expect := Expectations{Status: 200, Validate: func(body string) error {
+		if body != `{"id":1}` {
+			return errors.New("missing property 'id'")
+		}
+		return nil
+	}}
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Body: `{}`}, expect)
+	if etype != ErrorTypeBodyValidation {
+		t.Errorf("Expected Body Validation, got %v", etype)
+	}
+	if msg != "Response body failed validation: missing property 'id'" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200, Body: `{"id":1}`}, expect); etype != ErrorTypeNone {
+		t.Errorf("Expected None for a valid body, got %v", etype)
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 500, Body: `{}`}, expect); etype != ErrorTypeServerError {
+		t.Errorf("Expected the status check to come first, got %v", etype)
+	}
+}
+
+func TestCategorizeError_MinSize(t *testing.T) {
+	expect := Expectations{Status: 200, MinSize: 100}
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Size: 99}, expect)
+	if etype != ErrorTypeBodyValidation {
+		t.Errorf("Expected Body Validation, got %v", etype)
+	}
+	if msg != "Response body too small: 99 bytes (expected at least 100)" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200, Size: 100}, expect); etype != ErrorTypeNone {
+		t.Errorf("Expected None at exactly the minimum size, got %v", etype)
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200}, Expectations{Status: 200}); etype != ErrorTypeNone {
+		t.Errorf("Expected size check to be skipped when disabled, got %v", etype)
+	}
+}
+
+func TestCategorizeError_MaxLatency(t *testing.T) {
+	expect := Expectations{Status: 200, MaxLatency: 500 * time.Millisecond}
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Latency: 742 * time.Millisecond}, expect)
+	if etype != ErrorTypeSlowResponse {
+		t.Errorf("Expected Slow Response, got %v", etype)
+	}
+	if msg != "Response too slow: 742ms (max 500ms)" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200, Latency: 500 * time.Millisecond}, expect); etype != ErrorTypeNone {
+		t.Errorf("Expected None at exactly the threshold, got %v", etype)
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 503, Latency: time.Second}, expect); etype != ErrorTypeServerError {
+		t.Errorf("Expected the status check to come first, got %v", etype)
+	}
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200, Latency: time.Hour}, Expectations{Status: 200}); etype != ErrorTypeNone {
+		t.Errorf("Expected latency check to be skipped when disabled, got %v", etype)
+	}
+}
+
+func TestCategorizeError_AnyOfBodies(t *testing.T) {
+	expect := Expectations{Status: 200, Bodies: []string{"variant A", "variant B"}}
+
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 200, Body: "this is variant B"}, expect); etype != ErrorTypeNone {
+		t.Errorf("Expected a match on the second body to succeed, got %v", etype)
+	}
+
+	etype, msg := CategorizeError(nil, Response{StatusCode: 200, Body: "variant C"}, expect)
+	if etype != ErrorTypeBodyValidation {
+		t.Errorf("Expected Body Validation, got %v", etype)
+	}
+	if msg != "Response body doesn't contain any of the expected texts: 'variant A', 'variant B'" {
+		t.Errorf("Unexpected error message: %v", msg)
+	}
+}
+
+func TestErrorType_Code(t *testing.T) {
+	cases := map[ErrorType]string{
+		ErrorTypeServerError:    "server_error",
+		ErrorTypeDNS:            "dns",
+		ErrorTypeBodyValidation: "body_validation",
+		ErrorType("Made Up"):    "made_up",
+	}
+	for errorType, want := range cases {
+		if got := errorType.Code(); got != want {
+			t.Errorf("Expected code %q for %q, got %q", want, errorType, got)
+		}
+	}
+	for errorType, code := range codes {
+		if errorType != ErrorTypeNone && code == "" {
+			t.Errorf("Expected a code for %q", errorType)
+		}
+	}
+}
+
+func TestCategorizeError_Informational(t *testing.T) {
+	etype, msg := CategorizeError(nil, Response{StatusCode: 101}, Expectations{Status: 200})
+	if etype != ErrorTypeHTTPStatus {
+		t.Errorf("Expected HTTP Status, got %v", etype)
+	}
+	if msg != "Unexpected informational response (HTTP 101, expected 200)" {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+
+	if etype, _ := CategorizeError(nil, Response{StatusCode: 101}, Expectations{Status: 101}); etype != ErrorTypeNone {
+		t.Errorf("Expected an expected 101 to succeed, got %v", etype)
+	}
+}
+
+func TestParseCode(t *testing.T) {
+	if errorType, ok := ParseCode("server_error"); !ok || errorType != ErrorTypeServerError {
+		t.Errorf("Expected server_error to parse, got %q, %v", errorType, ok)
+	}
+	for _, code := range []string{"", "Server Error", "bogus"} {
+		if _, ok := ParseCode(code); ok {
+			t.Errorf("Expected %q not to parse", code)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package failures
+
+import (
+	"fmt"
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultRedact are the headers whose values are never written to a dump.
+var DefaultRedact = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// Saver writes a dump of each failed exchange to its own file in a
+// directory, up to a limit so a run where everything fails can't fill the
+// disk. It is safe for concurrent use.
+type Saver struct {
+	dir     string
+	limit   int64
+	headers bool
+	redact  map[string]bool
+	saved   atomic.Int64
+}
+
+// NewSaver creates dir if needed. At most limit dumps are written; headers
+// controls whether request and response headers are included, with the
+// values of redact (canonicalized) replaced.
+func NewSaver(dir string, limit int, headers bool, redact []string) (*Saver, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating failure dump directory: %w", err)
+	}
+	s := &Saver{dir: dir, limit: int64(limit), headers: headers, redact: make(map[string]bool)}
+	for _, name := range redact {
+		s.redact[http.CanonicalHeaderKey(name)] = true
+	}
+	return s, nil
+}
+
+// Wrap returns makeRequest with failure capture enabled and every failed
+// result dumped. Errors writing a dump are reported once on stdout and
+// otherwise ignored, since they shouldn't abort the test.
+func (s *Saver) Wrap(makeRequest func(config.RequestConfig) client.TestResult) func(config.RequestConfig) client.TestResult {
+	var warned atomic.Bool
+	return func(cfg config.RequestConfig) client.TestResult {
+		cfg.CaptureFailures = true
+		result := makeRequest(cfg)
+		if !result.Success {
+			if err := s.Save(result); err != nil && !warned.Swap(true) {
+				fmt.Println("Warning: saving failure dump failed:", err)
+			}
+		}
+		return result
+	}
+}
+
+// Save writes a dump of result, unless the limit has been reached.
+func (s *Saver) Save(result client.TestResult) error {
+	n := s.saved.Add(1)
+	if n > s.limit {
+		return nil
+	}
+	path := filepath.Join(s.dir, fmt.Sprintf("failure-%06d.txt", n))
+	return os.WriteFile(path, []byte(s.format(result)), 0o644)
+}
+
+// format renders result as an HTTP-like text blob: the error, then the
+// request line and headers, then the status line, headers and body.
+func (s *Saver) format(result client.TestResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: %s\n", result.ErrorType, result.ErrorMessage)
+	ex := result.Exchange
+	if ex == nil {
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n%s %s\n", ex.Method, ex.URL)
+	if s.headers {
+		host := ex.Host
+		if u, err := url.Parse(ex.URL); host == "" && err == nil {
+			host = u.Host
+		}
+		fmt.Fprintf(&b, "Host: %s\n", host)
+		s.writeHeaders(&b, ex.RequestHeaders)
+	}
+	if ex.Proto == "" {
+		b.WriteString("\n# no response received\n")
+		return b.String()
+	}
+
+	fmt.Fprintf(&b, "\n%s %s\n", ex.Proto, ex.Status)
+	if s.headers {
+		s.writeHeaders(&b, ex.ResponseHeaders)
+	}
+	if ex.Body != "" {
+		b.WriteString("\n")
+		b.WriteString(ex.Body)
+		if !strings.HasSuffix(ex.Body, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+func (s *Saver) writeHeaders(b *strings.Builder, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range h[name] {
+			if s.redact[http.CanonicalHeaderKey(name)] {
+				value = "[REDACTED]"
+			}
+			fmt.Fprintf(b, "%s: %s\n", name, value)
+		}
+	}
+}
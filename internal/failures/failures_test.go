@@ -0,0 +1,107 @@
+package failures
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+)
+
+func failedResult() client.TestResult {
+	return client.TestResult{
+		ErrorType:    errors.ErrorTypeServerError,
+		ErrorMessage: "Server error (HTTP 500)",
+		StatusCode:   500,
+		Exchange: &client.Exchange{
+			Method:          "GET",
+			URL:             "http://api.test/items",
+			RequestHeaders:  http.Header{"User-Agent": {"Go-Load-Tester/1.0"}, "Authorization": {"Bearer secret"}},
+			Proto:           "HTTP/1.1",
+			Status:          "500 Internal Server Error",
+			ResponseHeaders: http.Header{"X-Cache": {"MISS"}, "Set-Cookie": {"session=abc"}},
+			Body:            "boom",
+		},
+	}
+}
+
+func TestSaver_FormatWithHeaders(t *testing.T) {
+	s, err := NewSaver(t.TempDir(), 10, true, DefaultRedact)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `# Server Error: Server error (HTTP 500)
+
+GET http://api.test/items
+Host: api.test
+Authorization: [REDACTED]
+User-Agent: Go-Load-Tester/1.0
+
+HTTP/1.1 500 Internal Server Error
+Set-Cookie: [REDACTED]
+X-Cache: MISS
+
+boom
+`
+	if got := s.format(failedResult()); got != want {
+		t.Errorf("Unexpected dump:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSaver_FormatWithoutHeaders(t *testing.T) {
+	s, _ := NewSaver(t.TempDir(), 10, false, nil)
+
+	got := s.format(failedResult())
+
+	if strings.Contains(got, "User-Agent") || strings.Contains(got, "X-Cache") {
+		t.Errorf("Expected no headers in dump, got:\n%s", got)
+	}
+	if !strings.Contains(got, "HTTP/1.1 500 Internal Server Error\n\nboom\n") {
+		t.Errorf("Expected status line and body, got:\n%s", got)
+	}
+}
+
+func TestSaver_NoResponse(t *testing.T) {
+	s, _ := NewSaver(t.TempDir(), 10, true, nil)
+	result := client.TestResult{
+		ErrorType:    errors.ErrorTypeConnection,
+		ErrorMessage: "Connection failed",
+		Exchange:     &client.Exchange{Method: "GET", URL: "http://down.test/", Host: "override.test"},
+	}
+
+	got := s.format(result)
+
+	if !strings.Contains(got, "Host: override.test\n") || !strings.HasSuffix(got, "# no response received\n") {
+		t.Errorf("Unexpected dump for a request without response:\n%s", got)
+	}
+}
+
+func TestSaver_WrapWritesUpToLimit(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "dumps")
+	s, err := NewSaver(dir, 2, false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var captured bool
+	makeRequest := s.Wrap(func(cfg config.RequestConfig) client.TestResult {
+		captured = cfg.CaptureFailures
+		return failedResult()
+	})
+
+	for i := 0; i < 3; i++ {
+		makeRequest(config.RequestConfig{})
+	}
+
+	if !captured {
+		t.Error("Expected the wrapped request to capture failures")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 || entries[0].Name() != "failure-000001.txt" {
+		t.Errorf("Expected 2 dumps, got %v", entries)
+	}
+}
@@ -0,0 +1,133 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"loadtester/internal/config"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// file is the part of the HAR 1.2 format (http://www.softwareishard.com/blog/har-12-spec/)
+// needed to replay the recorded requests.
+type file struct {
+	Log *struct {
+		Entries []struct {
+			StartedDateTime string `json:"startedDateTime"`
+			Request         struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status int `json:"status"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// skipHeaders are recorded headers the client sets itself: the connection
+// is the client's own, and Go negotiates and decodes compression only when
+// it sets Accept-Encoding.
+var skipHeaders = []string{"Host", "Content-Length", "Connection", "Accept-Encoding", "Transfer-Encoding"}
+
+// Load reads a HAR file and turns its entries, in recorded order, into
+// scenario steps. Steps are named after their position, method and path, so
+// repeated requests to the same URL stay apart in the stats. A recorded
+// status becomes the step's expected status, except for redirects, which the
+// client follows. Each step's At is when its entry started, relative to the
+// earliest entry; entries without a start time are left at zero.
+func Load(path string) ([]config.Step, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading har: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parsing har %s: %w", path, err)
+	}
+	if f.Log == nil {
+		return nil, fmt.Errorf("har %s has no log", path)
+	}
+	if len(f.Log.Entries) == 0 {
+		return nil, fmt.Errorf("har %s has no entries", path)
+	}
+
+	started := make([]time.Time, len(f.Log.Entries))
+	var first time.Time
+	for i, entry := range f.Log.Entries {
+		if entry.StartedDateTime == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, entry.StartedDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("har entry %d: invalid startedDateTime %q", i+1, entry.StartedDateTime)
+		}
+		started[i] = t
+		if first.IsZero() || t.Before(first) {
+			first = t
+		}
+	}
+
+	steps := make([]config.Step, 0, len(f.Log.Entries))
+	for i, entry := range f.Log.Entries {
+		req := entry.Request
+		if req.Method == "" || req.URL == "" {
+			return nil, fmt.Errorf("har entry %d has no method or url", i+1)
+		}
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return nil, fmt.Errorf("har entry %d: invalid url %q: %v", i+1, req.URL, err)
+		}
+		step := config.Step{
+			Name:   fmt.Sprintf("%d %s %s", i+1, strings.ToUpper(req.Method), u.Path),
+			URL:    req.URL,
+			Method: strings.ToUpper(req.Method),
+		}
+		if !started[i].IsZero() {
+			step.At = started[i].Sub(first)
+		}
+		if status := entry.Response.Status; status > 0 && (status < 300 || status >= 400) {
+			step.ExpectedStatus = status
+		}
+		hasContentType := false
+		for _, h := range req.Headers {
+			// HTTP/2 captures include pseudo-headers such as :authority
+			if strings.HasPrefix(h.Name, ":") || containsFold(skipHeaders, h.Name) {
+				continue
+			}
+			hasContentType = hasContentType || strings.EqualFold(h.Name, "Content-Type")
+			step.Headers = append(step.Headers, constHeader(h.Name, h.Value))
+		}
+		if req.PostData != nil {
+			step.Body = req.PostData.Text
+			if !hasContentType && req.PostData.MimeType != "" {
+				step.Headers = append(step.Headers, constHeader("Content-Type", req.PostData.MimeType))
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func constHeader(name, value string) config.Header {
+	return config.Header{Name: name, Value: func() string { return value }}
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package har
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Entries(t *testing.T) {
+	steps, err := Load(testutil.WriteFile(t, "session.har", `{"log": {"version": "1.2", "entries": [
+		{"request": {"method": "get", "url": "https://test/app?x=1", "headers": [
+			{"name": ":authority", "value": "test"},
+			{"name": "Accept", "value": "text/html"},
+			{"name": "Accept-Encoding", "value": "gzip, br"},
+			{"name": "Cookie", "value": "session=abc"}
+		]}, "response": {"status": 200}},
+		{"request": {"method": "POST", "url": "https://test/api/items", "headers": [],
+			"postData": {"mimeType": "application/json", "text": "{\"name\":\"a\"}"}},
+		 "response": {"status": 201}},
+		{"request": {"method": "GET", "url": "https://test/old", "headers": []}, "response": {"status": 302}},
+		{"request": {"method": "GET", "url": "https://test/app?x=1", "headers": []}, "response": {"status": 0}}
+	]}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(steps) != 4 {
+		t.Fatalf("Expected 4 steps, got %d", len(steps))
+	}
+
+	page, post, redirect, repeat := steps[0], steps[1], steps[2], steps[3]
+	if page.Name != "1 GET /app" || page.Method != "GET" || page.URL != "https://test/app?x=1" || page.ExpectedStatus != 200 {
+		t.Errorf("First entry not parsed correctly: %+v", page)
+	}
+	if len(page.Headers) != 2 || page.Headers[0].Name != "Accept" || page.Headers[1].Value() != "session=abc" {
+		t.Errorf("Expected pseudo and transport headers to be dropped, got %+v", page.Headers)
+	}
+	if post.Body != `{"name":"a"}` || post.ExpectedStatus != 201 {
+		t.Errorf("Expected the posted body and 201, got %+v", post)
+	}
+	if len(post.Headers) != 1 || post.Headers[0].Name != "Content-Type" || post.Headers[0].Value() != "application/json" {
+		t.Errorf("Expected the body's MIME type as Content-Type, got %+v", post.Headers)
+	}
+	if redirect.ExpectedStatus != 0 || repeat.ExpectedStatus != 0 {
+		t.Errorf("Expected no status expectation for redirects or unanswered entries, got %d and %d", redirect.ExpectedStatus, repeat.ExpectedStatus)
+	}
+	if repeat.Name == page.Name {
+		t.Errorf("Expected repeated requests to get distinct names, got %q twice", page.Name)
+	}
+}
+
+func TestLoad_Offsets(t *testing.T) {
+	steps, err := Load(testutil.WriteFile(t, "session.har", `{"log": {"entries": [
+		{"startedDateTime": "2024-05-01T10:00:00.250+02:00", "request": {"method": "GET", "url": "https://test/a"}},
+		{"startedDateTime": "2024-05-01T08:00:00.000Z", "request": {"method": "GET", "url": "https://test/b"}},
+		{"startedDateTime": "2024-05-01T08:00:01.5Z", "request": {"method": "GET", "url": "https://test/c"}},
+		{"request": {"method": "GET", "url": "https://test/d"}}
+	]}}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Offsets are from the earliest entry, whatever the time zone
+	want := []time.Duration{250 * time.Millisecond, 0, 1500 * time.Millisecond, 0}
+	for i, step := range steps {
+		if step.At != want[i] {
+			t.Errorf("Step %d: expected offset %v, got %v", i+1, want[i], step.At)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"bad json":    `{"log": {`,
+		"no log":      `{}`,
+		"no entries":  `{"log": {"entries": []}}`,
+		"missing url": `{"log": {"entries": [{"request": {"method": "GET"}}]}}`,
+		"bad url":     `{"log": {"entries": [{"request": {"method": "GET", "url": "http://[::1"}}]}}`,
+		"bad date":    `{"log": {"entries": [{"startedDateTime": "yesterday", "request": {"method": "GET", "url": "http://test"}}]}}`,
+	}
+	for name, content := range cases {
+		if _, err := Load(testutil.WriteFile(t, "session.har", content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.har")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,53 @@
+package mockserver
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Options control the behavior of the mock server.
+type Options struct {
+	Delay     time.Duration // sleep before every response
+	ErrorRate float64       // fraction of requests, in [0, 1], answered with a 500
+}
+
+// Handler returns an echo handler: it replies 200 with the request body, or
+// "OK" when there is none. The delay and status can be overridden per request
+// with the "delay" (e.g. 100ms) and "status" query parameters.
+func Handler(opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delay := opts.Delay
+		if d, err := time.ParseDuration(r.URL.Query().Get("delay")); err == nil {
+			delay = d
+		}
+		if delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if opts.ErrorRate > 0 && rand.Float64() < opts.ErrorRate {
+			http.Error(w, "injected error", http.StatusInternalServerError)
+			return
+		}
+
+		status := http.StatusOK
+		if s, err := strconv.Atoi(r.URL.Query().Get("status")); err == nil && s >= 200 && s <= 599 {
+			status = s
+		}
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("OK")
+		}
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
@@ -0,0 +1,69 @@
+package mockserver
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_Echo(t *testing.T) {
+	server := httptest.NewServer(Handler(Options{}))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(`{"id":1}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || string(body) != `{"id":1}` || resp.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the body echoed back, got %d %q", resp.StatusCode, body)
+	}
+
+	resp, _ = http.Get(server.URL)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "OK" {
+		t.Errorf("Expected OK without a request body, got %q", body)
+	}
+}
+
+func TestHandler_DelayAndStatus(t *testing.T) {
+	server := httptest.NewServer(Handler(Options{Delay: 20 * time.Millisecond}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := http.Get(server.URL + "?status=404")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if time.Since(start) < 20*time.Millisecond || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a delayed 404, got %d after %v", resp.StatusCode, time.Since(start))
+	}
+
+	start = time.Now()
+	resp, _ = http.Get(server.URL + "?delay=0s")
+	resp.Body.Close()
+	if time.Since(start) >= 20*time.Millisecond {
+		t.Errorf("Expected the delay query parameter to override the default")
+	}
+}
+
+func TestHandler_ErrorRate(t *testing.T) {
+	server := httptest.NewServer(Handler(Options{ErrorRate: 1}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected an injected 500, got %d", resp.StatusCode)
+	}
+}
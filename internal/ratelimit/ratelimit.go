@@ -0,0 +1,35 @@
+// Package ratelimit spaces events, such as requests or new connections,
+// evenly at a fixed rate.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket holding a single token, so events are spaced
+// evenly rather than let through in bursts. It is safe for concurrent use.
+type Limiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time // earliest time the next event may happen
+}
+
+// New returns a limiter letting through rate events per second.
+func New(rate float64) *Limiter {
+	return &Limiter{interval: time.Duration(float64(time.Second) / rate)}
+}
+
+// Reserve claims the next slot and returns how long to wait for it.
+func (l *Limiter) Reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	return wait
+}
@@ -0,0 +1,19 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Reserve(t *testing.T) {
+	l := New(10)
+	if wait := l.Reserve(); wait != 0 {
+		t.Errorf("Expected the first event not to wait, got %v", wait)
+	}
+	if wait := l.Reserve(); wait < 90*time.Millisecond || wait > 100*time.Millisecond {
+		t.Errorf("Expected the second event to wait about 100ms, got %v", wait)
+	}
+	if wait := l.Reserve(); wait < 190*time.Millisecond || wait > 200*time.Millisecond {
+		t.Errorf("Expected the third event to wait about 200ms, got %v", wait)
+	}
+}
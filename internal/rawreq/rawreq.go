@@ -0,0 +1,92 @@
+package rawreq
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"loadtester/internal/config"
+	"loadtester/internal/tmpl"
+	"maps"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Request is what a raw request file asks to send.
+type Request struct {
+	Method  string
+	URL     string
+	Host    string // Host header to send when the request goes to a base URL elsewhere
+	Headers []config.Header
+	Body    string
+}
+
+// skipHeaders are headers the client sets itself from the request.
+var skipHeaders = []string{"Host", "Content-Length", "Connection", "Transfer-Encoding"}
+
+// Load parses a file holding a raw HTTP/1.x request: the request line,
+// headers, a blank line and the body. The body is everything after the blank
+// line, so a hand-edited body needs no matching Content-Length. A request
+// line with an absolute URL is sent there; otherwise the path is resolved
+// against base when given, with the file's Host header sent as the Host, or
+// else against http:// and the Host header. Header values may use the same
+// placeholders as -header.
+func Load(path, base string) (Request, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Request{}, fmt.Errorf("reading raw request: %w", err)
+	}
+	head, body := splitHead(raw)
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(append(head, "\r\n\r\n"...))))
+	if err != nil {
+		return Request{}, fmt.Errorf("parsing raw request %s: %w", path, err)
+	}
+
+	r := Request{Method: req.Method, Body: string(body)}
+	switch {
+	case req.URL.IsAbs():
+		r.URL = req.URL.String()
+	case base != "":
+		b, err := neturl.Parse(base)
+		if err != nil {
+			return Request{}, fmt.Errorf("invalid base url %q: %v", base, err)
+		}
+		r.URL = b.ResolveReference(req.URL).String()
+		r.Host = req.Host
+	case req.Host != "":
+		r.URL = "http://" + req.Host + req.URL.RequestURI()
+	default:
+		return Request{}, fmt.Errorf("raw request %s has a relative path but no Host header", path)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(req.Header)) {
+		if slices.ContainsFunc(skipHeaders, func(s string) bool { return strings.EqualFold(s, name) }) {
+			continue
+		}
+		for _, value := range req.Header[name] {
+			t, err := tmpl.Parse(value)
+			if err != nil {
+				return Request{}, fmt.Errorf("raw request header %s: %w", name, err)
+			}
+			r.Headers = append(r.Headers, config.Header{Name: name, Value: t.String})
+		}
+	}
+	return r, nil
+}
+
+// splitHead splits raw at the blank line ending the headers, accepting bare
+// LF line endings as files saved by editors often have.
+func splitHead(raw []byte) (head, body []byte) {
+	end, sepLen := -1, 0
+	for _, sep := range []string{"\r\n\r\n", "\n\n"} {
+		if i := bytes.Index(raw, []byte(sep)); i >= 0 && (end < 0 || i < end) {
+			end, sepLen = i, len(sep)
+		}
+	}
+	if end < 0 {
+		return bytes.TrimRight(raw, "\r\n"), nil
+	}
+	return raw[:end], raw[end+sepLen:]
+}
@@ -0,0 +1,59 @@
+package rawreq
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := testutil.WriteFile(t, "request.http", "POST /api/items?draft=1 HTTP/1.1\r\n"+
+		"Host: api.test\r\n"+
+		"Content-Type: application/json\r\n"+
+		"Content-Length: 2\r\n"+
+		"X-Trace: a\r\n"+
+		"\r\n"+
+		`{"name": "longer than Content-Length says"}`)
+
+	r, err := Load(path, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.Method != "POST" || r.URL != "http://api.test/api/items?draft=1" || r.Host != "" {
+		t.Errorf("Expected POST to http://api.test/api/items?draft=1, got %s %s (host %q)", r.Method, r.URL, r.Host)
+	}
+	if r.Body != `{"name": "longer than Content-Length says"}` {
+		t.Errorf("Expected the whole body regardless of Content-Length, got %q", r.Body)
+	}
+	if len(r.Headers) != 2 || r.Headers[0].Name != "Content-Type" || r.Headers[1].Value() != "a" {
+		t.Errorf("Expected Content-Type and X-Trace without Host or Content-Length, got %+v", r.Headers)
+	}
+}
+
+func TestLoad_BaseURL(t *testing.T) {
+	path := testutil.WriteFile(t, "request.http", "GET /health HTTP/1.1\nHost: api.internal\n")
+
+	r, err := Load(path, "https://10.0.0.5:8443")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.URL != "https://10.0.0.5:8443/health" || r.Host != "api.internal" || r.Body != "" {
+		t.Errorf("Expected the path on the base URL with the file's Host, got %s (host %q, body %q)", r.URL, r.Host, r.Body)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"no request line": "Host: api.test\r\n\r\n",
+		"no host":         "GET /health HTTP/1.1\r\n\r\n",
+		"bad placeholder": "GET /health HTTP/1.1\r\nHost: api.test\r\nX-ID: {{nope}}\r\n\r\n",
+	}
+	for name, content := range cases {
+		if _, err := Load(testutil.WriteFile(t, "request.http", content), ""); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.http"), ""); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
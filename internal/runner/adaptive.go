@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"loadtester/internal/stats"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// adaptiveTimeout derives a per-request timeout from the latency observed
+// during a warm-up: once warmup successful responses have been seen, the
+// timeout becomes multiplier times their P99. Until then it is zero and the
+// configured static timeout applies.
+type adaptiveTimeout struct {
+	multiplier float64
+	warmup     int
+	// Only touched by the result-collecting goroutine
+	samples []time.Duration
+	p99     time.Duration // of the warm-up, once complete
+
+	timeout atomic.Int64
+}
+
+func newAdaptiveTimeout(multiplier float64, warmup int) *adaptiveTimeout {
+	return &adaptiveTimeout{
+		multiplier: multiplier,
+		warmup:     warmup,
+		samples:    make([]time.Duration, 0, warmup),
+	}
+}
+
+// observe records a successful response time and reports whether this
+// sample completed the warm-up.
+func (a *adaptiveTimeout) observe(responseTime time.Duration) bool {
+	if len(a.samples) >= a.warmup {
+		return false
+	}
+	a.samples = append(a.samples, responseTime)
+	if len(a.samples) < a.warmup {
+		return false
+	}
+
+	slices.Sort(a.samples)
+	a.p99 = stats.Percentile(a.samples, 99)
+	timeout := time.Duration(float64(a.p99) * a.multiplier)
+	// Never time out faster than a millisecond, however fast the warm-up was
+	if timeout < time.Millisecond {
+		timeout = time.Millisecond
+	}
+	a.timeout.Store(int64(timeout))
+	return true
+}
+
+// current returns the adaptive timeout, or zero while still warming up.
+func (a *adaptiveTimeout) current() time.Duration {
+	return time.Duration(a.timeout.Load())
+}
+
+// warmupP99 returns the P99 the timeout was derived from, as measured
+// before any clamping of the timeout.
+func (a *adaptiveTimeout) warmupP99() time.Duration {
+	return a.p99
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveTimeout_Warmup(t *testing.T) {
+	a := newAdaptiveTimeout(3, 4)
+
+	for i, d := range []time.Duration{10, 40, 20} {
+		if a.observe(d * time.Millisecond) {
+			t.Fatalf("Warm-up completed early after %d samples", i+1)
+		}
+		if a.current() != 0 {
+			t.Fatalf("Expected no timeout during warm-up, got %v", a.current())
+		}
+	}
+	if !a.observe(30 * time.Millisecond) {
+		t.Fatal("Expected warm-up to complete on the 4th sample")
+	}
+	if a.current() != 120*time.Millisecond || a.warmupP99() != 40*time.Millisecond {
+		t.Errorf("Expected 3x the warm-up P99 (40ms), got %v from %v", a.current(), a.warmupP99())
+	}
+	if a.observe(time.Second) {
+		t.Error("Expected samples after warm-up to be ignored")
+	}
+	if a.current() != 120*time.Millisecond {
+		t.Errorf("Expected timeout to stay fixed after warm-up, got %v", a.current())
+	}
+}
+
+func TestAdaptiveTimeout_ClampedReportsMeasuredP99(t *testing.T) {
+	a := newAdaptiveTimeout(2, 1)
+	a.observe(100 * time.Microsecond)
+
+	if a.current() != time.Millisecond {
+		t.Errorf("Expected the timeout clamped to 1ms, got %v", a.current())
+	}
+	if a.warmupP99() != 100*time.Microsecond {
+		t.Errorf("Expected the measured warm-up P99 of 100µs, got %v", a.warmupP99())
+	}
+}
+
+func TestRunLoadTest_AdaptiveTimeout(t *testing.T) {
+	cfg := config.RequestConfig{
+		URL:             "http://test",
+		Timeout:         5 * time.Second,
+		ExpectedStatus:  200,
+		AdaptiveTimeout: 2,
+		AdaptiveWarmup:  5,
+	}
+
+	var mu sync.Mutex
+	var timeouts []time.Duration
+	stats := RunLoadTest(cfg, 20, 1, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		timeouts = append(timeouts, cfg.Timeout)
+		mu.Unlock()
+		return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 10 * time.Millisecond}
+	})
+
+	if timeouts[0] != 5*time.Second {
+		t.Errorf("Expected static timeout during warm-up, got %v", timeouts[0])
+	}
+	if last := timeouts[len(timeouts)-1]; last != 20*time.Millisecond {
+		t.Errorf("Expected adaptive timeout of 20ms after warm-up, got %v", last)
+	}
+	if stats.AdaptiveTimeout != 20*time.Millisecond {
+		t.Errorf("Expected adaptive timeout in stats, got %v", stats.AdaptiveTimeout)
+	}
+}
@@ -0,0 +1,30 @@
+package runner
+
+import (
+	"context"
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"loadtester/internal/stats"
+	"sync"
+)
+
+// RunCompare runs one load test per config at the same time, each with
+// numRequests requests and concurrency workers of its own, so the targets are
+// measured under identical conditions. Each config is sent with the
+// makeRequest at the same index; giving each its own client keeps connection
+// pools apart. Progress lines are turned off, since interleaved lines from
+// several runs can't be told apart. The stats are returned in config order.
+func RunCompare(ctx context.Context, configs []config.RequestConfig, numRequests int, concurrency int, makeRequests []func(config.RequestConfig) client.TestResult) []stats.LoadTestStats {
+	results := make([]stats.LoadTestStats, len(configs))
+	var wg sync.WaitGroup
+	for i, cfg := range configs {
+		cfg.NoProgress = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runLoadTest(ctx, cfg, numRequests, concurrency, makeRequests[i], nil, nil)
+		}()
+	}
+	wg.Wait()
+	return results
+}
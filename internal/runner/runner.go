@@ -1,67 +1,576 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"loadtester/internal/client"
 	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"loadtester/internal/ratelimit"
 	"loadtester/internal/stats"
+	"math/rand/v2"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 )
 
+// job is a single request waiting for a free worker.
+type job struct {
+	seq      int
+	queuedAt time.Time
+}
+
+// run holds the state shared by the workers of a single load test.
+type run struct {
+	config      config.RequestConfig
+	stop        context.Context // done once the run is stopped; requests outlive it while draining
+	id          string          // distinguishes this run's requests from other runs
+	makeRequest func(config.RequestConfig) client.TestResult
+	adaptive    *adaptiveTimeout   // nil unless an adaptive timeout is configured
+	ceiling     *ratelimit.Limiter // nil unless a maximum request rate is configured
+}
+
+// RunLoadTest sends numRequests requests using concurrency workers. When
+// config.Duration is set as well, the requests are paced evenly across it;
+// with a duration and numRequests of zero it instead keeps every worker busy
+// until the duration has elapsed. With config.TargetSuccesses set,
+// numRequests is ignored and requests are sent until that many have
+// succeeded, or the duration, if any, elapses; config.UntilErrors does the
+// same for failures.
 func RunLoadTest(config config.RequestConfig, numRequests int, concurrency int, makeRequest func(config.RequestConfig) client.TestResult) stats.LoadTestStats {
-	results := make(chan client.TestResult, numRequests)
+	return RunLoadTestContext(context.Background(), config, numRequests, concurrency, makeRequest)
+}
+
+// RunLoadTestContext is RunLoadTest with cancellation. Cancelling ctx (or
+// hitting config.MaxDuration) stops dispatching new requests and aborts
+// in-flight ones; the stats gathered so far are returned with StopReason set.
+// With config.DrainTimeout set, in-flight requests are instead given that
+// long to finish, and any still running are then aborted and reported as
+// Abandoned.
+// Every goroutine the run starts has exited by the time it returns.
+func RunLoadTestContext(ctx context.Context, config config.RequestConfig, numRequests int, concurrency int, makeRequest func(config.RequestConfig) client.TestResult) stats.LoadTestStats {
+	return runLoadTest(ctx, config, numRequests, concurrency, makeRequest, nil, nil)
+}
 
-	// Use a semaphore to limit concurrency
-	semaphore := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
+// runLoadTest runs a load test as described for RunLoadTestContext. Every
+// result is also added to combined when it is not nil, for stats that span
+// several runs. The snapshots taken every config.ReportInterval go to
+// onSnapshot when it is not nil, instead of being printed.
+func runLoadTest(ctx context.Context, config config.RequestConfig, numRequests int, concurrency int, makeRequest func(config.RequestConfig) client.TestResult, combined *stats.Collector, onSnapshot func(stats.LoadTestStats)) stats.LoadTestStats {
+	targetMode := config.TargetSuccesses > 0
+	errorMode := config.UntilErrors > 0
+	pacedMode := config.Duration > 0 && numRequests > 0 && !targetMode && !errorMode
+	durationMode := config.Duration > 0 && !pacedMode
+	showProgress := !config.NoProgress && !progressDisabledByEnv()
 
-	fmt.Printf("Starting load test: %d requests with %d concurrent workers\n",
-		numRequests, concurrency)
-	fmt.Printf("Target URL: %s\n", config.URL)
+	if targetMode {
+		fmt.Printf("Starting load test: until %d successful responses with %d concurrent workers\n",
+			config.TargetSuccesses, concurrency)
+	} else if errorMode {
+		fmt.Printf("Starting load test: until %d failed requests with %d concurrent workers\n",
+			config.UntilErrors, concurrency)
+	} else if pacedMode {
+		fmt.Printf("Starting load test: %d requests over %v (%.2f req/s) with %d concurrent workers\n",
+			numRequests, config.Duration, pacedRate(numRequests, config.Duration), concurrency)
+		if config.PoissonArrivals {
+			fmt.Printf("Arrivals: Poisson (seed %d)\n", config.Seed)
+		} else if config.RateJitter > 0 {
+			fmt.Printf("Arrivals: jittered by up to ±%.0f%% (seed %d)\n", config.RateJitter*100, config.Seed)
+		}
+	} else if durationMode {
+		fmt.Printf("Starting load test: %v with %d concurrent workers\n",
+			config.Duration, concurrency)
+	} else {
+		fmt.Printf("Starting load test: %d requests with %d concurrent workers\n",
+			numRequests, concurrency)
+	}
+	if len(config.Steps) > 0 {
+		names := make([]string, len(config.Steps))
+		for i, step := range config.Steps {
+			names[i] = step.Name
+		}
+		fmt.Printf("Scenario: %s\n", strings.Join(names, " -> "))
+	} else {
+		fmt.Printf("Target URL: %s\n", config.URL)
+	}
 	fmt.Printf("Expected status: %d\n", config.ExpectedStatus)
-	if config.ExpectedBody != "" {
-		fmt.Printf("Expected body contains: %s\n", config.ExpectedBody)
+	if bodies := config.AcceptedBodies(); len(bodies) > 0 {
+		fmt.Printf("Expected body contains: %s\n", strings.Join(bodies, " OR "))
+	}
+	// Workers share at most MaxConns connections; the rest wait for one
+	active := concurrency
+	if config.MaxConns > 0 && config.MaxConns < active {
+		fmt.Printf("Connections: at most %d shared by %d workers\n", config.MaxConns, concurrency)
+		active = config.MaxConns
+	}
+	if config.ConnPerWorker {
+		fmt.Printf("Connections: one dedicated connection per worker (%d)\n", concurrency)
+	} else if idle := client.IdleConnsPerHost(config); active > idle {
+		fmt.Printf("Warning: %d concurrent connections but only %d idle connections kept per host; "+
+			"extra connections will be closed and reopened, inflating latency (raise -max-idle-conns)\n",
+			active, idle)
+	}
+	if config.MaxRPS > 0 {
+		fmt.Printf("Ceiling: at most %.2f requests/sec\n", config.MaxRPS)
+	}
+	if config.DNSRoundRobin > 0 {
+		fmt.Printf("DNS: connections round-robin across each host's addresses (%d lookups)\n", config.DNSRoundRobin)
 	}
 	fmt.Println("---")
 
-	startTime := time.Now()
-
-	progressChan := make(chan struct{}, numRequests)
-	go func() {
-		completed := 0
-		for range progressChan {
-			completed++
-			if completed%10 == 0 || completed == numRequests {
-				fmt.Printf("Progress: %d/%d requests completed\n", completed, numRequests)
-			}
-		}
+	if config.MaxDuration > 0 {
+		var cancelCap context.CancelFunc
+		ctx, cancelCap = context.WithTimeoutCause(ctx, config.MaxDuration,
+			fmt.Errorf("max duration of %v reached", config.MaxDuration))
+		defer cancelCap()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	// Goroutines other than the workers; all are joined before returning
+	var background sync.WaitGroup
+	defer func() {
+		cancel()
+		background.Wait()
 	}()
 
-	// Launch goroutines for concurrent requests
-	for i := 0; i < numRequests; i++ {
-		wg.Add(1)
+	// Requests run on a context of their own, so that while draining they
+	// carry on after ctx is done. Its error is a plain context.Canceled:
+	// the cause the run was stopped with, such as the max duration, belongs
+	// in StopReason, not in the error of every request cut off by it.
+	reqCtx, abandon := context.WithCancel(context.WithoutCancel(ctx))
+	defer abandon()
+	drained := make(chan struct{})
+	if config.DrainTimeout == 0 {
+		defer context.AfterFunc(ctx, abandon)()
+	} else {
+		background.Add(1)
 		go func() {
-			defer wg.Done()
+			defer background.Done()
+			select {
+			case <-ctx.Done():
+			case <-drained:
+				return
+			}
+			fmt.Printf("Stopping: waiting up to %v for in-flight requests\n", config.DrainTimeout)
+			timer := time.NewTimer(config.DrainTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+				fmt.Println("Drain timeout reached: abandoning in-flight requests")
+				abandon()
+			case <-drained:
+			}
+		}()
+	}
+
+	startTime := time.Now()
+	collector := stats.NewCollector(startTime, config)
+	config.Context = reqCtx
+	r := &run{
+		config:      config,
+		stop:        ctx,
+		id:          strconv.FormatInt(startTime.UnixNano(), 36),
+		makeRequest: makeRequest,
+	}
+	if config.AdaptiveTimeout > 0 {
+		r.adaptive = newAdaptiveTimeout(config.AdaptiveTimeout, config.AdaptiveWarmup)
+	}
+	if config.MaxRPS > 0 {
+		r.ceiling = ratelimit.New(config.MaxRPS)
+	}
 
-			// Acquire semaphore
-			semaphore <- struct{}{}
+	// In count mode every request is queued up front, so time spent waiting
+	// for a worker shows up as queue wait. In duration mode a job is only
+	// created once a worker is free to take it.
+	// Stopping dispatch lets in-flight requests finish, unlike cancelling ctx
+	dispatchCtx, stopDispatch := context.WithCancel(ctx)
+	defer stopDispatch()
 
-			// Make request
-			result := makeRequest(config)
-			results <- result
-			progressChan <- struct{}{}
-			// Release semaphore
-			<-semaphore
+	var jobs chan job
+	if pacedMode {
+		// Each job is due at a set offset from the start; a job waiting on
+		// a busy worker is still counted from when it was due
+		jobs = make(chan job)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			defer close(jobs)
+			gap := arrivalGaps(config, config.Duration/time.Duration(numRequests))
+			due := startTime
+			for seq := 0; seq < numRequests; seq++ {
+				if seq > 0 {
+					due = due.Add(gap())
+				}
+				if wait := time.Until(due); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+						return
+					}
+				}
+				select {
+				case jobs <- job{seq: seq, queuedAt: due}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	} else if durationMode || targetMode || errorMode {
+		// Open-ended: runs until the deadline, if any, or until stopped
+		jobs = make(chan job)
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			defer close(jobs)
+			deadline := startTime.Add(config.Duration)
+			for seq := 0; !durationMode || time.Now().Before(deadline); seq++ {
+				select {
+				case jobs <- job{seq: seq, queuedAt: time.Now()}:
+				case <-dispatchCtx.Done():
+					return
+				}
+			}
 		}()
+	} else {
+		jobs = make(chan job, numRequests)
+		for seq := 0; seq < numRequests; seq++ {
+			jobs <- job{seq: seq, queuedAt: time.Now()}
+		}
+		close(jobs)
+	}
+
+	// One batch of results per job: a single request, or a scenario iteration
+	results := make(chan []client.TestResult, concurrency)
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func(worker int) {
+			defer workers.Done()
+			for j := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				results <- r.runJob(j, worker)
+			}
+		}(i)
 	}
 
 	// Close results channel when all requests complete
+	background.Add(1)
 	go func() {
-		wg.Wait()
+		defer background.Done()
+		workers.Wait()
+		close(drained)
 		close(results)
-		close(progressChan)
 	}()
 
-	return stats.CollectAndCalculateStats(results, startTime)
+	if config.ReportInterval > 0 {
+		report := stats.PrintSnapshot
+		if onSnapshot != nil {
+			report = onSnapshot
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			ticker := time.NewTicker(config.ReportInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					report(collector.Snapshot())
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	completed, successes, failures := 0, 0, 0
+	lastProgress := startTime
+	for batch := range results {
+		for i, result := range batch {
+			// The first request pays for DNS, connecting and the TLS handshake
+			result.First = completed == 0 && i == 0
+			collector.Add(result)
+			if combined != nil {
+				combined.Add(result)
+			}
+			if result.Success {
+				successes++
+			} else if result.ErrorType != errors.ErrorTypeAborted {
+				failures++
+			}
+			if r.adaptive != nil && result.Success && r.adaptive.observe(result.ResponseTime) {
+				fmt.Printf("Adaptive timeout: %v (%.1fx warm-up P99 of %v)\n",
+					r.adaptive.current(), config.AdaptiveTimeout, r.adaptive.warmupP99())
+			}
+		}
+		completed++
+		if targetMode && successes >= config.TargetSuccesses || errorMode && failures >= config.UntilErrors {
+			stopDispatch()
+		}
+		if !showProgress {
+			continue
+		}
+		if targetMode {
+			if time.Since(lastProgress) >= time.Second {
+				lastProgress = time.Now()
+				fmt.Printf("Progress: %d/%d successful responses (%d requests)\n", successes, config.TargetSuccesses, completed)
+			}
+		} else if errorMode {
+			if time.Since(lastProgress) >= time.Second {
+				lastProgress = time.Now()
+				fmt.Printf("Progress: %d/%d failed requests (%d requests)\n", failures, config.UntilErrors, completed)
+			}
+		} else if durationMode {
+			// Report at most once a second; the total isn't known up front
+			if time.Since(lastProgress) >= time.Second {
+				lastProgress = time.Now()
+				remaining := config.Duration - time.Since(startTime)
+				if remaining < 0 {
+					remaining = 0
+				}
+				fmt.Printf("Progress: %d requests completed, %v remaining\n", completed, remaining.Round(time.Second))
+			}
+		} else if completed%10 == 0 || completed == numRequests {
+			fmt.Printf("Progress: %d/%d requests completed\n", completed, numRequests)
+		}
+	}
+
+	final := collector.Snapshot()
+	if ctx.Err() != nil {
+		final.StopReason = context.Cause(ctx).Error()
+	}
+	if pacedMode {
+		final.TargetRate = pacedRate(numRequests, config.Duration)
+	}
+	if !durationMode && !targetMode && !errorMode {
+		final.PlannedRequests = numRequests
+	}
+	if r.adaptive != nil {
+		final.AdaptiveTimeout = r.adaptive.current()
+	}
+	return final
+}
+
+// progressDisabledByEnv reports whether the environment asks for quiet
+// output: LOADTESTER_NO_PROGRESS is set, or CI is (as CI systems do), unless
+// CI is explicitly "false" or "0".
+func progressDisabledByEnv() bool {
+	if os.Getenv("LOADTESTER_NO_PROGRESS") != "" {
+		return true
+	}
+	ci := os.Getenv("CI")
+	return ci != "" && ci != "false" && ci != "0"
+}
+
+// pacedRate is the request rate needed to spread n requests across d.
+func pacedRate(n int, d time.Duration) float64 {
+	return float64(n) / d.Seconds()
+}
+
+// arrivalGaps returns a source of gaps between paced requests that average
+// interval: interval itself, or random gaps as set by config.RateJitter or
+// config.PoissonArrivals, drawn from config.Seed. The source is not safe for
+// concurrent use.
+func arrivalGaps(config config.RequestConfig, interval time.Duration) func() time.Duration {
+	rng := rand.New(rand.NewPCG(config.Seed, 0))
+	switch {
+	case config.PoissonArrivals:
+		return func() time.Duration { return time.Duration(rng.ExpFloat64() * float64(interval)) }
+	case config.RateJitter > 0:
+		return func() time.Duration {
+			return time.Duration((1 + config.RateJitter*(2*rng.Float64()-1)) * float64(interval))
+		}
+	default:
+		return func() time.Duration { return interval }
+	}
+}
+
+// runJob runs a single job on the given worker: one request, or one
+// iteration of the scenario.
+func (r *run) runJob(j job, worker int) []client.TestResult {
+	waitTime := time.Since(j.queuedAt)
+	iteration := r.config
+	iteration.Worker = worker
+	if len(r.config.Steps) == 0 {
+		result := r.send(iteration, j.seq)
+		result.WaitTime = waitTime
+		return []client.TestResult{result}
+	}
+
+	// Steps share a cookie jar for the iteration, so a login step's session
+	// carries over to the steps after it
+	iteration.Jar, _ = cookiejar.New(nil)
+	results := make([]client.TestResult, 0, len(r.config.Steps))
+	start := time.Now()
+	for _, step := range r.config.Steps {
+		// A step whose recorded time has passed, because the one before it
+		// was still running, is sent at once and counted from its due time
+		var late time.Duration
+		if r.config.ReplayTiming {
+			due := start.Add(step.At)
+			if !r.sleep(time.Until(due)) {
+				break
+			}
+			late = time.Since(due)
+		}
+		result := r.send(withStep(iteration, step), j.seq)
+		result.Step = step.Name
+		result.Tag = step.Tag
+		result.WaitTime = late
+		results = append(results, result)
+		// Later steps depend on earlier ones, so abandon the iteration, and
+		// a stopped run only lets the request in flight finish
+		if !result.Success || r.stop.Err() != nil {
+			break
+		}
+		if !r.think(step) {
+			break
+		}
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	// Only the first step waited for a worker
+	results[0].WaitTime += waitTime
+	return results
+}
+
+// think pauses for the step's think time, reporting false if the run was
+// stopped while waiting.
+func (r *run) think(step config.Step) bool {
+	pause := step.ThinkTime
+	if step.ThinkTimeMax > step.ThinkTime {
+		pause += rand.N(step.ThinkTimeMax - step.ThinkTime + 1)
+	}
+	return r.sleep(pause)
+}
+
+// sleep pauses for d, reporting false if the run was stopped while waiting.
+func (r *run) sleep(d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.stop.Done():
+		return false
+	}
+}
+
+// withStep returns cfg with the request-specific fields of step applied.
+func withStep(cfg config.RequestConfig, step config.Step) config.RequestConfig {
+	cfg.URL = step.URL
+	switch {
+	case step.Method != "":
+		cfg.Method = step.Method
+	case cfg.Method == "":
+		cfg.Method = http.MethodGet
+	}
+	cfg.Body = step.Body
+	if step.ExpectedStatus != 0 {
+		cfg.ExpectedStatus = step.ExpectedStatus
+	}
+	if step.Timeout > 0 {
+		cfg.Timeout = step.Timeout
+	}
+	cfg.ExpectedBody = step.ExpectedBody
+	cfg.ExpectedBodies = nil
+	if len(step.Headers) > 0 {
+		headers := make([]config.Header, 0, len(cfg.Headers)+len(step.Headers))
+		for _, h := range cfg.Headers {
+			if !slices.ContainsFunc(step.Headers, func(s config.Header) bool { return strings.EqualFold(s.Name, h.Name) }) {
+				headers = append(headers, h)
+			}
+		}
+		cfg.Headers = append(headers, step.Headers...)
+	}
+	return cfg
+}
+
+// send prepares and sends a single request, once the rate ceiling, if any,
+// lets it through.
+func (r *run) send(cfg config.RequestConfig, seq int) client.TestResult {
+	var throttled time.Duration
+	if r.ceiling != nil {
+		throttled = r.ceiling.Reserve()
+		if !r.sleep(throttled) {
+			return client.TestResult{ErrorType: errors.ErrorTypeAborted, ErrorMessage: "Request aborted: the run was stopped"}
+		}
+	}
+
+	var result client.TestResult
+	reqConfig, err := prepareRequest(cfg, r.id, seq)
+	if err == nil && r.adaptive != nil {
+		// The adaptive timeout only ever tightens the static one
+		if timeout := r.adaptive.current(); timeout > 0 && timeout < reqConfig.Timeout {
+			reqConfig.Timeout = timeout
+		}
+	}
+	if err != nil {
+		result = client.TestResult{
+			ErrorType:    errors.ErrorTypeDataSource,
+			ErrorMessage: fmt.Sprintf("Data source error: %v", err),
+		}
+	} else {
+		result = r.makeRequest(reqConfig)
+		switch {
+		case result.Success || r.config.Context.Err() == nil:
+		case r.config.DrainTimeout > 0:
+			// Only the drain timeout cancels the request context on its own
+			result.ErrorType = errors.ErrorTypeAbandoned
+			result.ErrorMessage = fmt.Sprintf("Abandoned: still in flight after the %v drain timeout", r.config.DrainTimeout)
+		case result.StatusCode == 0:
+			// Cut off by stopping the run, whatever error that surfaced as
+			result.ErrorType = errors.ErrorTypeAborted
+			result.ErrorMessage = "Request aborted: the run was stopped"
+		}
+	}
+	result.ThrottleWait = throttled
+	return result
+}
+
+// prepareRequest returns the config for a single request, filling in any
+// per-request values such as a body drawn from the data source. runID and seq
+// together identify the request uniquely.
+func prepareRequest(cfg config.RequestConfig, runID string, seq int) (config.RequestConfig, error) {
+	if cfg.CacheBustParam != "" {
+		cfg.URL = addQueryParam(cfg.URL, cfg.CacheBustParam, runID+"-"+strconv.Itoa(seq))
+	}
+	if cfg.BodySource != nil {
+		body, err := cfg.BodySource.Next()
+		if err != nil {
+			return cfg, fmt.Errorf("reading request body: %w", err)
+		}
+		cfg.Body = body
+	}
+	return cfg, nil
+}
+
+// addQueryParam appends key=value to rawURL, leaving any existing query
+// parameters untouched and in their original order.
+func addQueryParam(rawURL, key, value string) string {
+	fragment := ""
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+		if strings.HasSuffix(rawURL, "?") || strings.HasSuffix(rawURL, "&") {
+			sep = ""
+		}
+	}
+	return rawURL + sep + url.QueryEscape(key) + "=" + url.QueryEscape(value) + fragment
 }
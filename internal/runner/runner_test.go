@@ -1,8 +1,17 @@
 package runner
 
 import (
+	"context"
+	"fmt"
 	"loadtester/internal/client"
 	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"loadtester/internal/stats"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -46,6 +55,29 @@ func TestRunLoadTest_ConcurrencyLimit(t *testing.T) {
 	}
 }
 
+func TestRunLoadTest_WorkerIndex(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200}
+
+	var mu sync.Mutex
+	workers := make(map[int]bool)
+	RunLoadTest(cfg, 50, 3, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		workers[cfg.Worker] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	for worker := range workers {
+		if worker < 0 || worker >= 3 {
+			t.Errorf("Expected worker indexes 0-2, got %d", worker)
+		}
+	}
+	if len(workers) < 2 {
+		t.Errorf("Expected requests from several workers, got %v", workers)
+	}
+}
+
 func TestRunLoadTest_AllSuccess(t *testing.T) {
 	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, Concurrency: 2}
 	numRequests := 10
@@ -61,4 +93,918 @@ func TestRunLoadTest_AllSuccess(t *testing.T) {
 	if stats.FailedReqs != 0 {
 		t.Errorf("Expected 0 failed requests, got %d", stats.FailedReqs)
 	}
+	if stats.PlannedRequests != numRequests {
+		t.Errorf("Expected %d planned requests, got %d", numRequests, stats.PlannedRequests)
+	}
+}
+
+type sliceSource struct {
+	mu     sync.Mutex
+	bodies []string
+	next   int
+}
+
+func (s *sliceSource) Next() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body := s.bodies[s.next%len(s.bodies)]
+	s.next++
+	return body, nil
+}
+
+func TestRunLoadTest_TagsFirstRequest(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, NoProgress: true}
+
+	var calls atomic.Int32
+	stats := RunLoadTest(cfg, 5, 1, func(cfg config.RequestConfig) client.TestResult {
+		// Only the cold first request is slow
+		if calls.Add(1) == 1 {
+			return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 200 * time.Millisecond}
+		}
+		return client.TestResult{Success: true, StatusCode: 200, ResponseTime: time.Millisecond}
+	})
+
+	if stats.FirstRequestTime != 200*time.Millisecond {
+		t.Errorf("Expected the first request's 200ms to be reported, got %v", stats.FirstRequestTime)
+	}
+}
+
+func TestRunLoadTest_BodySource(t *testing.T) {
+	source := &sliceSource{bodies: []string{"a", "b"}}
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, BodySource: source}
+
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	RunLoadTest(cfg, 10, 3, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		seen[cfg.Body]++
+		mu.Unlock()
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if seen["a"] != 5 || seen["b"] != 5 {
+		t.Errorf("Expected bodies to be cycled evenly, got %v", seen)
+	}
+}
+
+func TestRunLoadTest_WaitTime(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200}
+
+	// With one worker, later requests must queue behind earlier ones
+	stats := RunLoadTest(cfg, 5, 1, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(10 * time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 10 * time.Millisecond}
+	})
+
+	if stats.MaxWaitTime < 30*time.Millisecond {
+		t.Errorf("Expected queued requests to record wait time, got max %v", stats.MaxWaitTime)
+	}
+	if stats.AverageWaitTime <= 0 {
+		t.Errorf("Expected positive average wait time, got %v", stats.AverageWaitTime)
+	}
+}
+
+func TestRunLoadTest_DurationMode(t *testing.T) {
+	cfg := config.RequestConfig{
+		URL:            "http://test",
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Duration:       100 * time.Millisecond,
+		ReportInterval: 30 * time.Millisecond,
+	}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 0, 2, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(5 * time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 5 * time.Millisecond}
+	})
+	elapsed := time.Since(start)
+
+	if elapsed < cfg.Duration {
+		t.Errorf("Expected run to last at least %v, took %v", cfg.Duration, elapsed)
+	}
+	if elapsed > cfg.Duration+500*time.Millisecond {
+		t.Errorf("Expected run to stop shortly after %v, took %v", cfg.Duration, elapsed)
+	}
+	// Open-ended, so requests keep going until the duration is up
+	if stats.TotalRequests < 10 {
+		t.Errorf("Expected many requests in duration mode, got %d", stats.TotalRequests)
+	}
+	if stats.PlannedRequests != 0 {
+		t.Errorf("Expected no planned count in duration mode, got %d", stats.PlannedRequests)
+	}
+}
+
+func TestAddQueryParam(t *testing.T) {
+	cases := map[string]string{
+		"http://test":              "http://test?cb=1",
+		"http://test/path?a=1&b=2": "http://test/path?a=1&b=2&cb=1",
+		"http://test/?":            "http://test/?cb=1",
+		"http://test/p?a=1#frag":   "http://test/p?a=1&cb=1#frag",
+	}
+	for in, want := range cases {
+		if got := addQueryParam(in, "cb", "1"); got != want {
+			t.Errorf("addQueryParam(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRunLoadTest_CacheBust(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test?x=1", Timeout: 1 * time.Second, ExpectedStatus: 200, CacheBustParam: "cb"}
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	RunLoadTest(cfg, 20, 4, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		seen[cfg.URL] = true
+		mu.Unlock()
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if len(seen) != 20 {
+		t.Errorf("Expected 20 distinct URLs, got %d", len(seen))
+	}
+	for u := range seen {
+		if !strings.HasPrefix(u, "http://test?x=1&cb=") {
+			t.Errorf("Expected existing query to be preserved, got %q", u)
+		}
+	}
+}
+
+// checkNoGoroutineLeak fails if more goroutines are running than before
+// the test started. Exiting goroutines can take a moment to be reaped, so
+// the count is polled briefly before failing.
+func checkNoGoroutineLeak(t *testing.T, before int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		after := runtime.NumGoroutine()
+		if after <= before {
+			return
+		}
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("Goroutines leaked: %d before, %d after\n%s", before, after, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunLoadTest_NoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, ReportInterval: time.Millisecond}
+	RunLoadTest(cfg, 20, 4, mockMakeRequest)
+
+	checkNoGoroutineLeak(t, before)
+}
+
+func TestRunLoadTestContext_CancelledNoGoroutineLeak(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	cfg := config.RequestConfig{
+		URL:            "http://test",
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Duration:       time.Hour,
+		ReportInterval: time.Millisecond,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	stats := RunLoadTestContext(ctx, cfg, 0, 4, func(cfg config.RequestConfig) client.TestResult {
+		select {
+		case <-cfg.Context.Done():
+			return client.TestResult{ErrorMessage: "aborted"}
+		case <-time.After(5 * time.Millisecond):
+			return client.TestResult{Success: true, StatusCode: 200}
+		}
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cancelled run to stop promptly, took %v", elapsed)
+	}
+	if stats.TotalRequests == 0 {
+		t.Error("Expected partial stats from the cancelled run")
+	}
+	checkNoGoroutineLeak(t, before)
+}
+
+func TestRunLoadTestContext_CancelledCountMode(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200}
+
+	var calls int32
+	stats := RunLoadTestContext(ctx, cfg, 1000, 2, func(cfg config.RequestConfig) client.TestResult {
+		if atomic.AddInt32(&calls, 1) == 10 {
+			cancel()
+		}
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if stats.TotalRequests >= 1000 {
+		t.Errorf("Expected cancellation to stop dispatching, got %d requests", stats.TotalRequests)
+	}
+	checkNoGoroutineLeak(t, before)
+}
+
+func TestRunLoadTest_MaxDuration(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, MaxDuration: 50 * time.Millisecond}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 1000, 2, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(5 * time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the cap to stop the run, took %v", elapsed)
+	}
+	if stats.TotalRequests >= 1000 {
+		t.Errorf("Expected partial results, got %d requests", stats.TotalRequests)
+	}
+	if stats.StopReason != "max duration of 50ms reached" {
+		t.Errorf("Expected stop reason to note the cap, got %q", stats.StopReason)
+	}
+}
+
+// hangingServer starts a server that never answers, holding each request
+// until the client gives up on it.
+func hangingServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunLoadTestContext_InterruptAbortsInFlightRequests(t *testing.T) {
+	server := hangingServer(t)
+	cfg := config.RequestConfig{URL: server.URL, Timeout: time.Minute, ExpectedStatus: 200}
+
+	// Ctrl-C cancels the run with a cause, as interruptContext does
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(50*time.Millisecond, func() { cancel(fmt.Errorf("received interrupt")) })
+
+	stats := RunLoadTestContext(ctx, cfg, 100, 2, client.NewClient(cfg).MakeRequest)
+
+	if stats.FailedReqs != 0 {
+		t.Errorf("Expected no failures from the interrupt, got %d: %v", stats.FailedReqs, stats.ErrorBreakdown)
+	}
+	if stats.AbortedReqs != 2 {
+		t.Errorf("Expected both in-flight requests to be aborted, got %d", stats.AbortedReqs)
+	}
+	if stats.StopReason != "received interrupt" {
+		t.Errorf("Expected the interrupt as stop reason, got %q", stats.StopReason)
+	}
+}
+
+func TestRunLoadTestContext_CancelledRequestsAbortedWhateverTheirError(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(50*time.Millisecond, func() { cancel(fmt.Errorf("received interrupt")) })
+
+	// slowRequest reports its cancellation as a network error
+	stats := RunLoadTestContext(ctx, config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200},
+		100, 2, slowRequest(time.Minute))
+
+	if stats.FailedReqs != 0 || stats.AbortedReqs != 2 {
+		t.Errorf("Expected both in-flight requests to be aborted, not failed, got %d aborted and %v", stats.AbortedReqs, stats.ErrorBreakdown)
+	}
+}
+
+func TestRunLoadTest_MaxDurationAbortsInFlightRequests(t *testing.T) {
+	server := hangingServer(t)
+	cfg := config.RequestConfig{URL: server.URL, Timeout: time.Minute, ExpectedStatus: 200, MaxDuration: 50 * time.Millisecond}
+
+	stats := RunLoadTest(cfg, 100, 2, client.NewClient(cfg).MakeRequest)
+
+	if stats.FailedReqs != 0 {
+		t.Errorf("Expected no failures from the max duration stop, got %d: %v", stats.FailedReqs, stats.ErrorBreakdown)
+	}
+	if stats.AbortedReqs != 2 {
+		t.Errorf("Expected both in-flight requests to be aborted, got %d", stats.AbortedReqs)
+	}
+	if stats.StopReason != "max duration of 50ms reached" {
+		t.Errorf("Expected stop reason to note the cap, got %q", stats.StopReason)
+	}
+}
+
+// slowRequest waits d for its response, failing early if its context is
+// cancelled.
+func slowRequest(d time.Duration) func(config.RequestConfig) client.TestResult {
+	return func(cfg config.RequestConfig) client.TestResult {
+		select {
+		case <-time.After(d):
+			return client.TestResult{Success: true, StatusCode: 200}
+		case <-cfg.Context.Done():
+			return client.TestResult{ErrorType: errors.ErrorTypeNetwork, ErrorMessage: cfg.Context.Err().Error()}
+		}
+	}
+}
+
+func TestRunLoadTest_DrainLetsInFlightRequestsFinish(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200,
+		MaxDuration: 50 * time.Millisecond, DrainTimeout: time.Second}
+
+	stats := RunLoadTest(cfg, 100, 2, slowRequest(150*time.Millisecond))
+
+	if stats.TotalRequests != 2 || stats.SuccessfulReqs != 2 {
+		t.Errorf("Expected both in-flight requests to finish, got %d of %d successful", stats.SuccessfulReqs, stats.TotalRequests)
+	}
+	if stats.StopReason != "max duration of 50ms reached" {
+		t.Errorf("Expected stop reason to note the cap, got %q", stats.StopReason)
+	}
+}
+
+func TestRunLoadTest_DrainTimeoutAbandonsRequests(t *testing.T) {
+	before := runtime.NumGoroutine()
+	cfg := config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200,
+		MaxDuration: 50 * time.Millisecond, DrainTimeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 100, 2, slowRequest(time.Minute))
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the drain timeout to end the run, took %v", elapsed)
+	}
+	if got := stats.ErrorBreakdown[errors.ErrorTypeAbandoned]; got != 2 {
+		t.Errorf("Expected both in-flight requests to be abandoned, got %v", stats.ErrorBreakdown)
+	}
+	checkNoGoroutineLeak(t, before)
+}
+
+func TestRunLoadTest_DrainNotNeededWhenCompleted(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200, DrainTimeout: time.Minute}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 5, 2, mockMakeRequest)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected a completed run not to wait for the drain timeout, took %v", elapsed)
+	}
+	if stats.SuccessfulReqs != 5 {
+		t.Errorf("Expected 5 successful requests, got %d", stats.SuccessfulReqs)
+	}
+}
+
+func TestRunLoadTest_CompletedHasNoStopReason(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, MaxDuration: time.Minute}
+
+	stats := RunLoadTest(cfg, 5, 2, mockMakeRequest)
+
+	if stats.StopReason != "" {
+		t.Errorf("Expected no stop reason for a completed run, got %q", stats.StopReason)
+	}
+}
+
+func TestRunLoadTest_PacedMode(t *testing.T) {
+	cfg := config.RequestConfig{
+		URL:            "http://test",
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Duration:       200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 10, 4, mockMakeRequest)
+	elapsed := time.Since(start)
+
+	if stats.TotalRequests != 10 {
+		t.Errorf("Expected exactly 10 paced requests, got %d", stats.TotalRequests)
+	}
+	// The last request is due 9/10 of the way through the window
+	if elapsed < 180*time.Millisecond || elapsed > cfg.Duration+500*time.Millisecond {
+		t.Errorf("Expected requests spread over about %v, took %v", cfg.Duration, elapsed)
+	}
+	if stats.TargetRate != 50 {
+		t.Errorf("Expected target rate 50 req/s, got %v", stats.TargetRate)
+	}
+}
+
+func TestArrivalGaps(t *testing.T) {
+	interval := 10 * time.Millisecond
+	if gap := arrivalGaps(config.RequestConfig{}, interval); gap() != interval || gap() != interval {
+		t.Error("Expected even gaps without jitter")
+	}
+
+	for _, cfg := range []config.RequestConfig{
+		{RateJitter: 0.5, Seed: 7},
+		{PoissonArrivals: true, Seed: 7},
+	} {
+		gap, again := arrivalGaps(cfg, interval), arrivalGaps(cfg, interval)
+		var total time.Duration
+		varied := false
+		for i := 0; i < 10000; i++ {
+			g := gap()
+			if g != again() {
+				t.Fatalf("%+v: expected the same seed to give the same gaps", cfg)
+			}
+			if g < 0 || (cfg.RateJitter > 0 && (g < 5*time.Millisecond || g > 15*time.Millisecond)) {
+				t.Fatalf("%+v: gap %v out of range", cfg, g)
+			}
+			varied = varied || g != interval
+			total += g
+		}
+		if mean := total / 10000; mean < 9*time.Millisecond || mean > 11*time.Millisecond {
+			t.Errorf("%+v: expected gaps to average %v, got %v", cfg, interval, mean)
+		}
+		if !varied {
+			t.Errorf("%+v: expected the gaps to vary", cfg)
+		}
+	}
+}
+
+func TestRunLoadTest_PacedModeCountsLateRequestsAsWaiting(t *testing.T) {
+	cfg := config.RequestConfig{
+		URL:            "http://test",
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Duration:       20 * time.Millisecond,
+	}
+
+	// One worker that takes longer than the pacing interval falls behind
+	stats := RunLoadTest(cfg, 4, 1, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(20 * time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if stats.MaxWaitTime < 20*time.Millisecond {
+		t.Errorf("Expected lag behind the schedule to show as queue wait, got max %v", stats.MaxWaitTime)
+	}
+}
+
+func TestRunLoadTest_ScenarioCarriesCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		case "/profile":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Concurrency:    2,
+		Steps: []config.Step{
+			{Name: "login", URL: server.URL + "/login", Method: "POST"},
+			{Name: "profile", URL: server.URL + "/profile", Method: "GET"},
+		},
+	}
+
+	stats := RunLoadTest(cfg, 5, 2, client.NewClient(cfg).MakeRequest)
+
+	if stats.TotalRequests != 10 || stats.FailedReqs != 0 {
+		t.Errorf("Expected 10 successful requests, got %d with %d failures", stats.TotalRequests, stats.FailedReqs)
+	}
+	if len(stats.Steps) != 2 || stats.Steps[0].Name != "login" || stats.Steps[1].TotalRequests != 5 {
+		t.Errorf("Expected per-step stats in scenario order, got %+v", stats.Steps)
+	}
+}
+
+func TestWithStep_EmptyFieldsFallBack(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test/run", Method: "POST", Body: "run body", ExpectedStatus: 201, Timeout: time.Second}
+
+	got := withStep(cfg, config.Step{URL: "http://test/step"})
+
+	if got.Method != "POST" || got.ExpectedStatus != 201 || got.Timeout != time.Second {
+		t.Errorf("Expected an empty method, status and timeout to fall back to the run's, got %s, %d and %v", got.Method, got.ExpectedStatus, got.Timeout)
+	}
+	if got.URL != "http://test/step" || got.Body != "" {
+		t.Errorf("Expected the step's own URL and no body, got %q and %q", got.URL, got.Body)
+	}
+
+	got = withStep(cfg, config.Step{URL: "http://test/step", Method: "PUT", Body: "step body"})
+	if got.Method != "PUT" || got.Body != "step body" {
+		t.Errorf("Expected the step's method and body, got %s and %q", got.Method, got.Body)
+	}
+
+	cfg.Method = ""
+	if got = withStep(cfg, config.Step{URL: "http://test/step"}); got.Method != "GET" {
+		t.Errorf("Expected GET without a method on the step or the run, got %q", got.Method)
+	}
+}
+
+func TestRunLoadTest_ScenarioStepUsesRunMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer server.Close()
+
+	cfg := config.RequestConfig{
+		Method:  "POST",
+		Timeout: time.Second,
+		Steps:   []config.Step{{Name: "submit", URL: server.URL}},
+	}.WithDefaults()
+
+	stats := RunLoadTest(cfg, 2, 1, client.NewClient(cfg).MakeRequest)
+
+	// Sent as POST, so the 201 expected of a POST is met
+	if stats.SuccessfulReqs != 2 {
+		t.Errorf("Expected the step to be sent as the run's POST and expect 201, got %v", stats.StatusBreakdown)
+	}
+}
+
+func TestRunLoadTest_ScenarioStopsIterationOnFailure(t *testing.T) {
+	cfg := config.RequestConfig{
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Steps: []config.Step{
+			{Name: "login", URL: "http://test/login"},
+			{Name: "fetch", URL: "http://test/fetch"},
+		},
+	}
+
+	stats := RunLoadTest(cfg, 3, 1, func(cfg config.RequestConfig) client.TestResult {
+		return client.TestResult{Success: !strings.HasSuffix(cfg.URL, "/login"), StatusCode: 500}
+	})
+
+	if stats.TotalRequests != 3 || stats.Steps[0].FailedReqs != 3 || stats.Steps[1].TotalRequests != 0 {
+		t.Errorf("Expected later steps to be skipped after a failure, got %+v", stats.Steps)
+	}
+}
+
+func TestRunLoadTest_ScenarioThinkTime(t *testing.T) {
+	cfg := config.RequestConfig{
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		Steps: []config.Step{
+			{Name: "search", URL: "http://test/search", ThinkTime: 20 * time.Millisecond, ThinkTimeMax: 30 * time.Millisecond},
+			{Name: "asset", URL: "http://test/asset"},
+		},
+	}
+
+	var mu sync.Mutex
+	sent := make(map[string]time.Time)
+	start := time.Now()
+	stats := RunLoadTest(cfg, 1, 1, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		sent[cfg.URL] = time.Now()
+		mu.Unlock()
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if stats.TotalRequests != 2 {
+		t.Fatalf("Expected 2 requests, got %d", stats.TotalRequests)
+	}
+	if gap := sent["http://test/asset"].Sub(sent["http://test/search"]); gap < 20*time.Millisecond {
+		t.Errorf("Expected at least 20ms think time between steps, got %v", gap)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Think time took far longer than its 30ms maximum: %v", elapsed)
+	}
+}
+
+func TestRunLoadTest_ScenarioReplayTiming(t *testing.T) {
+	cfg := config.RequestConfig{
+		Timeout:        1 * time.Second,
+		ExpectedStatus: 200,
+		ReplayTiming:   true,
+		Steps: []config.Step{
+			{Name: "page", URL: "http://test/page"},
+			{Name: "api", URL: "http://test/api", At: 50 * time.Millisecond},
+			{Name: "asset", URL: "http://test/asset", At: 60 * time.Millisecond},
+		},
+	}
+
+	var mu sync.Mutex
+	sent := make(map[string]time.Duration)
+	start := time.Now()
+	stats := RunLoadTest(cfg, 1, 1, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		sent[cfg.URL] = time.Since(start)
+		mu.Unlock()
+		// Holds up the next step past its recorded time
+		if cfg.URL == "http://test/api" {
+			time.Sleep(40 * time.Millisecond)
+		}
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if stats.TotalRequests != 3 {
+		t.Fatalf("Expected 3 requests, got %d", stats.TotalRequests)
+	}
+	if at := sent["http://test/api"]; at < 50*time.Millisecond {
+		t.Errorf("Expected the api step no earlier than its 50ms offset, got %v", at)
+	}
+	// The asset step was due at 60ms but had to wait for the api step
+	if stats.MaxWaitTime < 20*time.Millisecond {
+		t.Errorf("Expected the late asset step to count as waiting, got max wait %v", stats.MaxWaitTime)
+	}
+}
+
+func TestRunLoadTest_ScenarioStepTimeout(t *testing.T) {
+	cfg := config.RequestConfig{
+		Timeout:        5 * time.Second,
+		ExpectedStatus: 200,
+		Steps: []config.Step{
+			{Name: "slow", URL: "http://test/slow", Timeout: 30 * time.Second},
+			{Name: "fast", URL: "http://test/fast"},
+		},
+	}
+
+	var mu sync.Mutex
+	timeouts := make(map[string]time.Duration)
+	RunLoadTest(cfg, 1, 1, func(cfg config.RequestConfig) client.TestResult {
+		mu.Lock()
+		timeouts[cfg.URL] = cfg.Timeout
+		mu.Unlock()
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if timeouts["http://test/slow"] != 30*time.Second || timeouts["http://test/fast"] != 5*time.Second {
+		t.Errorf("Expected the step timeout with the global one as fallback, got %v", timeouts)
+	}
+}
+
+func TestRunLoadTest_ScenarioStepHeaders(t *testing.T) {
+	header := func(name, value string) config.Header {
+		return config.Header{Name: name, Value: func() string { return value }}
+	}
+	cfg := config.RequestConfig{
+		ExpectedStatus: 200,
+		Headers:        []config.Header{header("Authorization", "run"), header("X-Run", "1")},
+		Steps: []config.Step{
+			{Name: "api", URL: "http://test/api", Headers: []config.Header{header("authorization", "step")}},
+		},
+	}
+
+	var got []config.Header
+	RunLoadTest(cfg, 1, 1, func(cfg config.RequestConfig) client.TestResult {
+		got = cfg.Headers
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if len(got) != 2 || got[0].Name != "X-Run" || got[1].Value() != "step" {
+		t.Errorf("Expected the step's Authorization to replace the run's, got %+v", got)
+	}
+	if len(cfg.Headers) != 2 || cfg.Headers[0].Value() != "run" {
+		t.Errorf("Expected the run's headers to be left alone, got %+v", cfg.Headers)
+	}
+}
+
+func TestRunLoadTest_TargetSuccesses(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, TargetSuccesses: 20}
+
+	var calls atomic.Int32
+	stats := RunLoadTest(cfg, 1, 4, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(time.Millisecond)
+		// Every other request fails
+		if calls.Add(1)%2 == 0 {
+			return client.TestResult{StatusCode: 500}
+		}
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	// In-flight requests may overshoot by up to one per worker
+	if stats.SuccessfulReqs < 20 || stats.SuccessfulReqs > 24 {
+		t.Errorf("Expected about 20 successes, got %d", stats.SuccessfulReqs)
+	}
+	if stats.FailedReqs < 15 {
+		t.Errorf("Expected failures not to count toward the target, got %d failures", stats.FailedReqs)
+	}
+	if stats.StopReason != "" {
+		t.Errorf("Expected reaching the target to be a normal completion, got %q", stats.StopReason)
+	}
+}
+
+func TestRunLoadTest_TargetSuccessesCapped(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, TargetSuccesses: 10, MaxDuration: 50 * time.Millisecond}
+
+	stats := RunLoadTest(cfg, 1, 2, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(time.Millisecond)
+		return client.TestResult{StatusCode: 500}
+	})
+
+	if stats.SuccessfulReqs != 0 || stats.StopReason == "" {
+		t.Errorf("Expected the cap to end a run that never reaches its target, got %+v", stats.StopReason)
+	}
+}
+
+func TestRunLoadTest_UntilErrors(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, UntilErrors: 5}
+
+	var calls atomic.Int32
+	stats := RunLoadTest(cfg, 1, 4, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(time.Millisecond)
+		// One request in ten fails
+		if calls.Add(1)%10 == 0 {
+			return client.TestResult{StatusCode: 500}
+		}
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	// In-flight requests may overshoot by up to one per worker
+	if stats.FailedReqs < 5 || stats.FailedReqs > 9 {
+		t.Errorf("Expected about 5 failures, got %d", stats.FailedReqs)
+	}
+	if stats.SuccessfulReqs < 40 {
+		t.Errorf("Expected successes not to count toward the target, got %d successes", stats.SuccessfulReqs)
+	}
+	if stats.StopReason != "" {
+		t.Errorf("Expected reaching the error count to be a normal completion, got %q", stats.StopReason)
+	}
+}
+
+func TestProgressDisabledByEnv(t *testing.T) {
+	cases := []struct {
+		noProgress, ci string
+		want           bool
+	}{
+		{"", "", false},
+		{"1", "", true},
+		{"", "true", true},
+		{"", "false", false},
+		{"", "0", false},
+	}
+	for _, c := range cases {
+		t.Setenv("LOADTESTER_NO_PROGRESS", c.noProgress)
+		t.Setenv("CI", c.ci)
+		if got := progressDisabledByEnv(); got != c.want {
+			t.Errorf("LOADTESTER_NO_PROGRESS=%q CI=%q: expected %v, got %v", c.noProgress, c.ci, c.want, got)
+		}
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	configs := []config.RequestConfig{
+		{URL: "http://a.test", Timeout: time.Second, ExpectedStatus: 200},
+		{URL: "http://b.test", Timeout: time.Second, ExpectedStatus: 200},
+	}
+	var aInFlight, bInFlight, overlap atomic.Int32
+	send := func(mine, other *atomic.Int32, status int) func(config.RequestConfig) client.TestResult {
+		return func(cfg config.RequestConfig) client.TestResult {
+			mine.Add(1)
+			defer mine.Add(-1)
+			time.Sleep(5 * time.Millisecond)
+			if other.Load() > 0 {
+				overlap.Add(1)
+			}
+			return client.TestResult{Success: status == 200, StatusCode: status, ResponseTime: 5 * time.Millisecond}
+		}
+	}
+
+	results := RunCompare(context.Background(), configs, 10, 2, []func(config.RequestConfig) client.TestResult{
+		send(&aInFlight, &bInFlight, 200),
+		send(&bInFlight, &aInFlight, 500),
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("Expected stats for 2 targets, got %d", len(results))
+	}
+	if results[0].TotalRequests != 10 || results[0].SuccessfulReqs != 10 {
+		t.Errorf("Expected the first target's 10 requests to succeed, got %d of %d", results[0].SuccessfulReqs, results[0].TotalRequests)
+	}
+	if results[1].TotalRequests != 10 || results[1].FailedReqs != 10 {
+		t.Errorf("Expected the second target's 10 requests to fail, got %d of %d", results[1].FailedReqs, results[1].TotalRequests)
+	}
+	if overlap.Load() == 0 {
+		t.Error("Expected the targets to be tested at the same time")
+	}
+}
+
+func TestRunWithSnapshots(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: time.Second, ExpectedStatus: 200, Duration: 250 * time.Millisecond}
+	snapshots, err := RunWithSnapshots(context.Background(), cfg, SnapshotOptions{
+		Concurrency: 2,
+		Interval:    50 * time.Millisecond,
+		MakeRequest: func(cfg config.RequestConfig) client.TestResult {
+			time.Sleep(5 * time.Millisecond)
+			return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 5 * time.Millisecond}
+		},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var received []stats.LoadTestStats
+	for s := range snapshots {
+		received = append(received, s)
+	}
+	if len(received) < 3 {
+		t.Fatalf("Expected interim snapshots and a final one, got %d", len(received))
+	}
+	for i := 1; i < len(received); i++ {
+		if received[i].TotalRequests < received[i-1].TotalRequests {
+			t.Errorf("Expected snapshots to only grow, got %d after %d", received[i].TotalRequests, received[i-1].TotalRequests)
+		}
+	}
+	final := received[len(received)-1]
+	if final.TotalRequests == 0 || final.SuccessfulReqs != final.TotalRequests || final.TestDuration < cfg.Duration {
+		t.Errorf("Expected a final snapshot covering the whole run, got %d requests over %v", final.TotalRequests, final.TestDuration)
+	}
+}
+
+func TestRunWithSnapshots_Invalid(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test"}
+	cases := map[string]SnapshotOptions{
+		"no workers":  {Requests: 10, Interval: time.Second},
+		"no interval": {Requests: 10, Concurrency: 1},
+		"no end":      {Concurrency: 1, Interval: time.Second},
+	}
+	for name, opts := range cases {
+		if _, err := RunWithSnapshots(context.Background(), cfg, opts); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestRunStages(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, NoProgress: true}
+	runStages := []config.Stage{
+		{Name: "ramp", Concurrency: 1, Duration: 100 * time.Millisecond, Rate: 50},
+		{Name: "peak", Concurrency: 4, Duration: 100 * time.Millisecond},
+	}
+
+	var inFlight, maxInFlight atomic.Int32
+	stats := RunStages(context.Background(), cfg, runStages, 2, func(cfg config.RequestConfig) client.TestResult {
+		n := inFlight.Add(1)
+		for {
+			prev := maxInFlight.Load()
+			if n <= prev || maxInFlight.CompareAndSwap(prev, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inFlight.Add(-1)
+		return client.TestResult{Success: true, StatusCode: 200, ResponseTime: 5 * time.Millisecond}
+	})
+
+	if len(stats.Stages) != 2 || stats.Stages[0].Name != "ramp" || stats.Stages[1].Concurrency != 4 {
+		t.Fatalf("Expected a summary per stage in order, got %+v", stats.Stages)
+	}
+	if ramp := stats.Stages[0].TotalRequests; ramp != 5 {
+		t.Errorf("Expected the ramp to send 5 paced requests, got %d", ramp)
+	}
+	if stats.TotalRequests != stats.Stages[0].TotalRequests+stats.Stages[1].TotalRequests {
+		t.Errorf("Expected combined stats to cover every stage, got %d total for %+v", stats.TotalRequests, stats.Stages)
+	}
+	if maxInFlight.Load() > 4 {
+		t.Errorf("Expected at most 4 concurrent requests, got %d", maxInFlight.Load())
+	}
+}
+
+func TestRunStages_MaxDurationStopsRemainingStages(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, NoProgress: true, MaxDuration: 50 * time.Millisecond}
+	runStages := []config.Stage{
+		{Name: "long", Duration: time.Second},
+		{Name: "never", Duration: time.Second},
+	}
+
+	start := time.Now()
+	stats := RunStages(context.Background(), cfg, runStages, 1, func(cfg config.RequestConfig) client.TestResult {
+		time.Sleep(time.Millisecond)
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the cap to end the run early, took %v", elapsed)
+	}
+	if len(stats.Stages) != 1 || stats.StopReason == "" {
+		t.Errorf("Expected the second stage to be skipped with a stop reason, got %+v (%q)", stats.Stages, stats.StopReason)
+	}
+}
+
+func TestRunStages_MaxDurationAbortsInFlightRequests(t *testing.T) {
+	server := hangingServer(t)
+	cfg := config.RequestConfig{URL: server.URL, Timeout: time.Minute, ExpectedStatus: 200, NoProgress: true, MaxDuration: 50 * time.Millisecond}
+	runStages := []config.Stage{{Name: "long", Duration: time.Second}}
+
+	stats := RunStages(context.Background(), cfg, runStages, 2, client.NewClient(cfg).MakeRequest)
+
+	if stats.FailedReqs != 0 || stats.AbortedReqs != 2 {
+		t.Errorf("Expected both in-flight requests to be aborted, not failed, got %d aborted and %v", stats.AbortedReqs, stats.ErrorBreakdown)
+	}
+}
+
+func TestRunLoadTest_MaxRPS(t *testing.T) {
+	cfg := config.RequestConfig{URL: "http://test", Timeout: 1 * time.Second, ExpectedStatus: 200, MaxRPS: 50}
+
+	start := time.Now()
+	stats := RunLoadTest(cfg, 10, 10, func(cfg config.RequestConfig) client.TestResult {
+		return client.TestResult{Success: true, StatusCode: 200}
+	})
+
+	// 10 requests spaced 20ms apart, however many workers there are
+	if elapsed := time.Since(start); elapsed < 170*time.Millisecond {
+		t.Errorf("Expected 10 requests at 50/s to take at least 180ms, took %v", elapsed)
+	}
+	if stats.TotalRequests != 10 || stats.ThrottledReqs != 9 || stats.MaxRPS != 50 {
+		t.Errorf("Expected 9 of 10 requests to be held back at 50/s, got %d of %d at %v", stats.ThrottledReqs, stats.TotalRequests, stats.MaxRPS)
+	}
+	if stats.MaxThrottleWait < 170*time.Millisecond || stats.AverageThrottleWait <= 0 {
+		t.Errorf("Expected the last request to wait about 180ms, got max %v, average %v", stats.MaxThrottleWait, stats.AverageThrottleWait)
+	}
 }
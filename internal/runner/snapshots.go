@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"loadtester/internal/stats"
+	"time"
+)
+
+// SnapshotOptions configures RunWithSnapshots.
+type SnapshotOptions struct {
+	Requests    int           // as numRequests of RunLoadTest
+	Concurrency int           // number of workers
+	Interval    time.Duration // time between interim snapshots
+	// MakeRequest sends each request; nil uses a client built from the config
+	MakeRequest func(config.RequestConfig) client.TestResult
+}
+
+// RunWithSnapshots starts a load test in the background and returns a channel
+// carrying a snapshot of the stats so far every opts.Interval, then the final
+// stats, after which it is closed. Snapshots are cheap, as the stats are
+// aggregated as results arrive. An interim snapshot is skipped when the
+// previous one hasn't been received yet, so a slow consumer never holds up
+// the run, but the final one is always sent: read until the channel closes.
+// Cancelling ctx ends the run early, as for RunLoadTestContext.
+func RunWithSnapshots(ctx context.Context, cfg config.RequestConfig, opts SnapshotOptions) (<-chan stats.LoadTestStats, error) {
+	if opts.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be >= 1, got %d", opts.Concurrency)
+	}
+	if opts.Requests < 0 {
+		return nil, fmt.Errorf("requests must be >= 0, got %d", opts.Requests)
+	}
+	if opts.Requests == 0 && cfg.Duration <= 0 && cfg.TargetSuccesses <= 0 && cfg.UntilErrors <= 0 {
+		return nil, fmt.Errorf("one of requests, duration, target successes or until errors must be set")
+	}
+	if opts.Interval <= 0 {
+		return nil, fmt.Errorf("interval must be > 0, got %v", opts.Interval)
+	}
+	makeRequest := opts.MakeRequest
+	if makeRequest == nil {
+		makeRequest = client.NewClient(cfg).MakeRequest
+		if cfg.Retries > 0 {
+			makeRequest = client.WithRetries(makeRequest)
+		}
+	}
+	// Snapshots take the place of both the printed reports and progress lines
+	cfg.ReportInterval = opts.Interval
+	cfg.NoProgress = true
+
+	snapshots := make(chan stats.LoadTestStats, 1)
+	go func() {
+		defer close(snapshots)
+		final := runLoadTest(ctx, cfg, opts.Requests, opts.Concurrency, makeRequest, nil, func(s stats.LoadTestStats) {
+			select {
+			case snapshots <- s:
+			default:
+			}
+		})
+		snapshots <- final
+	}()
+	return snapshots, nil
+}
@@ -0,0 +1,67 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"loadtester/internal/stats"
+	"math"
+	"time"
+)
+
+// RunStages runs stages one after another, each for its own duration with
+// its own concurrency and rate. The returned stats combine every stage, with
+// a summary of each in Stages. config.MaxDuration caps the whole run rather
+// than each stage, and cancelling ctx skips the remaining stages.
+func RunStages(ctx context.Context, config config.RequestConfig, stages []config.Stage, concurrency int, makeRequest func(config.RequestConfig) client.TestResult) stats.LoadTestStats {
+	if config.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, config.MaxDuration,
+			fmt.Errorf("max duration of %v reached", config.MaxDuration))
+		defer cancel()
+	}
+
+	combined := stats.NewCollector(time.Now(), config)
+	var summaries []stats.StageStats
+	for i, stage := range stages {
+		stageConfig := config
+		stageConfig.MaxDuration = 0
+		stageConfig.Duration = stage.Duration
+		workers := concurrency
+		if stage.Concurrency > 0 {
+			workers = stage.Concurrency
+		}
+		// A rate is paced as a fixed number of requests over the duration
+		numRequests := 0
+		if stage.Rate > 0 {
+			numRequests = max(1, int(math.Round(stage.Rate*stage.Duration.Seconds())))
+		}
+
+		fmt.Printf("\n=== Stage %d/%d: %s ===\n", i+1, len(stages), stage.Name)
+		result := runLoadTest(ctx, stageConfig, numRequests, workers, makeRequest, combined, nil)
+		summaries = append(summaries, stats.StageStats{
+			Name:              stage.Name,
+			Concurrency:       workers,
+			TotalRequests:     result.TotalRequests,
+			SuccessfulReqs:    result.SuccessfulReqs,
+			FailedReqs:        result.FailedReqs,
+			SuccessRate:       result.SuccessRate,
+			RequestsPerSecond: result.RequestsPerSecond,
+			AverageTime:       result.AverageTime,
+			P95Time:           result.P95Time,
+			P99Time:           result.P99Time,
+			TestDuration:      result.TestDuration,
+		})
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	final := combined.Snapshot()
+	final.Stages = summaries
+	if ctx.Err() != nil {
+		final.StopReason = context.Cause(ctx).Error()
+	}
+	return final
+}
@@ -0,0 +1,102 @@
+package scenario
+
+import (
+	"encoding/json"
+	"fmt"
+	"loadtester/internal/config"
+	"os"
+	"strings"
+	"time"
+)
+
+// Scenario is a flow of requests that each virtual user walks through in
+// order, e.g. login -> fetch -> logout.
+type Scenario struct {
+	Steps []config.Step
+}
+
+// file is the on-disk JSON format of a scenario.
+type file struct {
+	Steps []struct {
+		Name         string `json:"name"`
+		URL          string `json:"url"`
+		Method       string `json:"method"`
+		Body         string `json:"body"`
+		Status       int    `json:"status"`
+		BodyContains string `json:"body_contains"`
+		Tag          string `json:"tag"`
+		Timeout      string `json:"timeout"`
+		ThinkTime    string `json:"think_time"`
+	} `json:"steps"`
+}
+
+// Load reads and validates a scenario file. Steps without a name are named
+// after their method, if any, and URL; names must be unique since stats are grouped
+// by them.
+func Load(path string) (*Scenario, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if len(f.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", path)
+	}
+
+	s := &Scenario{}
+	seen := make(map[string]bool)
+	for i, fs := range f.Steps {
+		if fs.URL == "" {
+			return nil, fmt.Errorf("scenario step %d has no url", i+1)
+		}
+		step := config.Step{
+			Name:           fs.Name,
+			URL:            fs.URL,
+			Method:         strings.ToUpper(fs.Method),
+			Body:           fs.Body,
+			ExpectedStatus: fs.Status,
+			ExpectedBody:   fs.BodyContains,
+			Tag:            fs.Tag,
+		}
+		if fs.Timeout != "" {
+			step.Timeout, err = time.ParseDuration(fs.Timeout)
+			if err != nil || step.Timeout <= 0 {
+				return nil, fmt.Errorf("scenario step %d: invalid timeout %q, want a positive duration like 2s", i+1, fs.Timeout)
+			}
+		}
+		step.ThinkTime, step.ThinkTimeMax, err = parseThinkTime(fs.ThinkTime)
+		if err != nil {
+			return nil, fmt.Errorf("scenario step %d: %w", i+1, err)
+		}
+		// A step without a method uses the run's, so it's named by URL alone
+		if step.Name == "" {
+			step.Name = strings.TrimSpace(step.Method + " " + step.URL)
+		}
+		if seen[step.Name] {
+			return nil, fmt.Errorf("scenario step name %q is used more than once", step.Name)
+		}
+		seen[step.Name] = true
+		s.Steps = append(s.Steps, step)
+	}
+	return s, nil
+}
+
+// parseThinkTime parses a think time: a duration such as "500ms", or a range
+// such as "1s-3s" to pause a random time within. Empty means no pause.
+func parseThinkTime(s string) (min, max time.Duration, err error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err = time.ParseDuration(strings.TrimSpace(lo))
+	if err == nil && isRange {
+		max, err = time.ParseDuration(strings.TrimSpace(hi))
+	}
+	if err != nil || min < 0 || (isRange && max < min) {
+		return 0, 0, fmt.Errorf("invalid think_time %q, want a duration like 500ms or a range like 1s-3s", s)
+	}
+	return min, max, nil
+}
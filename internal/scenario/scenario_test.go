@@ -0,0 +1,72 @@
+package scenario
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Steps(t *testing.T) {
+	s, err := Load(testutil.WriteFile(t, "scenario.json", `{"steps": [
+		{"name": "login", "method": "post", "url": "http://test/login", "body": "user=a", "status": 201, "tag": "auth"},
+		{"url": "http://test/profile", "body_contains": "a"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(s.Steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %d", len(s.Steps))
+	}
+	login, fetch := s.Steps[0], s.Steps[1]
+	if login.Name != "login" || login.Method != "POST" || login.Body != "user=a" || login.ExpectedStatus != 201 || login.Tag != "auth" {
+		t.Errorf("First step not parsed correctly: %+v", login)
+	}
+	if fetch.Name != "http://test/profile" || fetch.Method != "" || fetch.ExpectedBody != "a" {
+		t.Errorf("Second step defaults not applied: %+v", fetch)
+	}
+}
+
+func TestLoad_ThinkTime(t *testing.T) {
+	s, err := Load(testutil.WriteFile(t, "scenario.json", `{"steps": [
+		{"name": "search", "url": "http://test/search", "think_time": "1s-3s"},
+		{"name": "asset", "url": "http://test/app.js", "think_time": "50ms", "timeout": "500ms"},
+		{"name": "done", "url": "http://test/done"}
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	search, asset, done := s.Steps[0], s.Steps[1], s.Steps[2]
+	if search.ThinkTime != time.Second || search.ThinkTimeMax != 3*time.Second {
+		t.Errorf("Expected a 1s-3s range, got %v-%v", search.ThinkTime, search.ThinkTimeMax)
+	}
+	if asset.ThinkTime != 50*time.Millisecond || asset.ThinkTimeMax != 0 {
+		t.Errorf("Expected a fixed 50ms think time, got %v-%v", asset.ThinkTime, asset.ThinkTimeMax)
+	}
+	if asset.Timeout != 500*time.Millisecond || search.Timeout != 0 {
+		t.Errorf("Expected only the asset step to override the timeout, got %v and %v", asset.Timeout, search.Timeout)
+	}
+	if done.ThinkTime != 0 {
+		t.Errorf("Expected no think time by default, got %v", done.ThinkTime)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"empty":          `{"steps": []}`,
+		"missing url":    `{"steps": [{"name": "a"}]}`,
+		"duplicate name": `{"steps": [{"name": "a", "url": "http://x"}, {"name": "a", "url": "http://y"}]}`,
+		"bad json":       `{"steps": [`,
+		"bad think time": `{"steps": [{"url": "http://x", "think_time": "soon"}]}`,
+		"inverted range": `{"steps": [{"url": "http://x", "think_time": "3s-1s"}]}`,
+		"bad timeout":    `{"steps": [{"url": "http://x", "timeout": "0s"}]}`,
+	}
+	for name, content := range cases {
+		if _, err := Load(testutil.WriteFile(t, "scenario.json", content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,37 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Validator checks response bodies against a compiled JSON Schema. It is
+// safe for concurrent use.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Load compiles the JSON Schema at path, along with any schemas it
+// references, so that validating a body never touches the disk.
+func Load(path string) (*Validator, error) {
+	compiled, err := jsonschema.NewCompiler().Compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("compiling json-schema: %w", err)
+	}
+	return &Validator{schema: compiled}, nil
+}
+
+// Validate reports why body is not JSON matching the schema, or nil if it is.
+// The error is a single line so that it groups well in error breakdowns.
+func (v *Validator) Validate(body string) error {
+	doc, err := jsonschema.UnmarshalJSON(strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("response is not valid JSON: %v", err)
+	}
+	if err := v.schema.Validate(doc); err != nil {
+		return fmt.Errorf("%s", strings.Join(strings.Fields(err.Error()), " "))
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package schema
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	v, err := Load(testutil.WriteFile(t, "schema.json", `{
+		"type": "object",
+		"required": ["id", "name"],
+		"properties": {"id": {"type": "integer"}, "name": {"type": "string"}}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := v.Validate(`{"id": 1, "name": "a"}`); err != nil {
+		t.Errorf("Expected a matching body to pass, got %v", err)
+	}
+	for _, body := range []string{`{"id": 1}`, `{"id": "1", "name": "a"}`, `not json`} {
+		err := v.Validate(body)
+		if err == nil {
+			t.Errorf("Expected %s to fail validation", body)
+			continue
+		}
+		if strings.Contains(err.Error(), "\n") {
+			t.Errorf("Expected a single-line error, got %q", err)
+		}
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	if _, err := Load(testutil.WriteFile(t, "schema.json", `{"type": 5}`)); err == nil {
+		t.Error("Expected error for an invalid schema")
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
@@ -0,0 +1,61 @@
+package stages
+
+import (
+	"encoding/json"
+	"fmt"
+	"loadtester/internal/config"
+	"os"
+	"time"
+)
+
+// file is the on-disk JSON format of a stages file.
+type file struct {
+	Stages []struct {
+		Name        string  `json:"name"`
+		Concurrency int     `json:"concurrency"`
+		Duration    string  `json:"duration"`
+		Rate        float64 `json:"rate"`
+	} `json:"stages"`
+}
+
+// Load reads and validates a stages file. Every stage needs a duration;
+// stages without a name are numbered, and a zero concurrency falls back to
+// the run's.
+func Load(path string) ([]config.Stage, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading stages: %w", err)
+	}
+	var f file
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return nil, fmt.Errorf("parsing stages %s: %w", path, err)
+	}
+	if len(f.Stages) == 0 {
+		return nil, fmt.Errorf("stages file %s has no stages", path)
+	}
+
+	var stages []config.Stage
+	for i, fs := range f.Stages {
+		duration, err := time.ParseDuration(fs.Duration)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("stage %d needs a positive duration like 30s, got %q", i+1, fs.Duration)
+		}
+		if fs.Concurrency < 0 {
+			return nil, fmt.Errorf("stage %d concurrency must be >= 0, got %d", i+1, fs.Concurrency)
+		}
+		if fs.Rate < 0 {
+			return nil, fmt.Errorf("stage %d rate must be >= 0, got %v", i+1, fs.Rate)
+		}
+		name := fs.Name
+		if name == "" {
+			name = fmt.Sprintf("stage %d", i+1)
+		}
+		stages = append(stages, config.Stage{
+			Name:        name,
+			Concurrency: fs.Concurrency,
+			Duration:    duration,
+			Rate:        fs.Rate,
+		})
+	}
+	return stages, nil
+}
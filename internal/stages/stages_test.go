@@ -0,0 +1,47 @@
+package stages
+
+import (
+	"loadtester/internal/testutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad_Stages(t *testing.T) {
+	stages, err := Load(testutil.WriteFile(t, "stages.json", `{"stages": [
+		{"name": "ramp", "concurrency": 10, "duration": "30s"},
+		{"concurrency": 50, "duration": "1m", "rate": 200}
+	]}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(stages) != 2 {
+		t.Fatalf("Expected 2 stages, got %d", len(stages))
+	}
+	ramp, peak := stages[0], stages[1]
+	if ramp.Name != "ramp" || ramp.Concurrency != 10 || ramp.Duration != 30*time.Second || ramp.Rate != 0 {
+		t.Errorf("First stage not parsed correctly: %+v", ramp)
+	}
+	if peak.Name != "stage 2" || peak.Duration != time.Minute || peak.Rate != 200 {
+		t.Errorf("Second stage not parsed correctly: %+v", peak)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"empty":                `{"stages": []}`,
+		"missing duration":     `{"stages": [{"concurrency": 1}]}`,
+		"bad duration":         `{"stages": [{"duration": "soon"}]}`,
+		"negative concurrency": `{"stages": [{"duration": "1s", "concurrency": -1}]}`,
+		"negative rate":        `{"stages": [{"duration": "1s", "rate": -5}]}`,
+		"bad json":             `{"stages": [`,
+	}
+	for name, content := range cases {
+		if _, err := Load(testutil.WriteFile(t, "stages.json", content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
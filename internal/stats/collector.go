@@ -1,9 +1,15 @@
 package stats
 
 import (
+	"cmp"
 	"loadtester/internal/client"
+	"loadtester/internal/config"
 	"loadtester/internal/errors"
+	"math"
+	"net/http"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -11,71 +17,767 @@ type LoadTestStats struct {
 	TotalRequests  int
 	SuccessfulReqs int
 	FailedReqs     int
+	Retries        int // extra attempts made beyond the first for each request
 	SuccessRate    float64
+	ErrorRate      float64
 	AverageTime    time.Duration
 	MinTime        time.Duration
 	MaxTime        time.Duration
 	MedianTime     time.Duration
 	P95Time        time.Duration
 	P99Time        time.Duration
+	StdDevTime     time.Duration // population standard deviation of the response times
+	// StdDevTime relative to AverageTime; a high value means erratic latency
+	// even when the average looks fine
+	LatencyCV float64
+	// Latency of the run's first request, which pays the connection setup
+	// cost pooled requests avoid; when excluded it is left out of every
+	// latency figure above and below, though still counted as a request
+	FirstRequestTime     time.Duration
+	FirstRequestExcluded bool
+	// Too few samples for the tail percentiles to mean much, see MinPercentileSamples
+	PercentilesUnreliable bool
+
+	// The requests MinTime and MaxTime were measured on, for chasing down
+	// outliers (nil before any sample)
+	Fastest *RequestSample `json:",omitempty"`
+	Slowest *RequestSample `json:",omitempty"`
+
+	// The percentiles above split by outcome: how fast good responses come
+	// versus how long failures take to surface
+	SuccessLatency Percentiles
+	FailureLatency Percentiles
+
+	// Responses with the expected status whose body was then checked, and
+	// the share of them whose body passed: content correctness apart from
+	// availability (zero unless body validation is enabled)
+	BodyCheckedReqs int
+	BodyPassRate    float64
 
 	// Error breakdown
-	ErrorBreakdown  map[errors.ErrorType]int
-	StatusBreakdown map[int]int
+	ErrorBreakdown map[errors.ErrorType]int
+	ErrorCodes     map[string]int // ErrorBreakdown keyed by stable machine codes
+
+	// Failures by exact error message, capped at MaxErrorMessages distinct
+	// messages; failures with messages beyond the cap land in OtherErrorMessages
+	ErrorMessages      map[string]int
+	OtherErrorMessages int
+	StatusBreakdown    map[int]int
+
+	// Negotiated HTTP protocol of each response (e.g. "HTTP/2.0")
+	ProtocolBreakdown map[string]int
+
+	// Server IP each request's connection went to, showing how a host
+	// resolving to several addresses spreads the load across them
+	RemoteAddrBreakdown map[string]int
+
+	// Negotiated TLS version and cipher suite (e.g. "TLS 1.3 TLS_AES_128_GCM_SHA256")
+	TLSBreakdown map[string]int
+
+	// Values of the configured cache header (e.g. X-Cache) and the share of
+	// responses whose value contains HIT; empty when no header is configured
+	CacheHeader    string
+	CacheBreakdown map[string]int `json:",omitempty"`
+	CacheHitRate   float64
+
+	// Server throttling: responses with status 429, and the delays asked for
+	// by Retry-After headers on 429 and 503 responses
+	RateLimitedReqs   int
+	RateLimitedRate   float64 // percentage of all requests
+	RetryAfters       int     // responses carrying a usable Retry-After header
+	MinRetryAfter     time.Duration
+	MaxRetryAfter     time.Duration
+	AverageRetryAfter time.Duration
+
+	// Server certificate expiry seen on the first successful HTTPS request,
+	// and how close to it the summary warns (zero disables the warning)
+	CertExpiry     time.Time
+	CertExpiryWarn time.Duration
+
+	// Response sizes bucketed by bytes, when enabled with config.SizeHistogram
+	SizeHistogram []SizeBucket `json:",omitempty"`
 
 	// Performance insights
 	TotalDataTransfer int64
+	TotalDataSent     int64 // request body bytes sent, after any compression
+	TotalDataSentRaw  int64 // request body bytes before compression
 	RequestsPerSecond float64
+	TargetRate        float64 // paced request rate aimed for (zero when not paced)
 	TestDuration      time.Duration
+	StopReason        string // why the run ended early, empty if it completed
+
+	// Requests the run was set to send, to compare TotalRequests against;
+	// zero for open-ended runs (a duration, success target or error count),
+	// which are reported by what they completed and at what rate
+	PlannedRequests int
+
+	// Results completed during the warm-up at the start of the run are
+	// discarded; TestDuration and the rates cover only the time after it
+	WarmupDuration  time.Duration
+	WarmupDiscarded int
+
+	// Requests completed in the quietest and busiest whole second of the
+	// run, showing whether throughput was steady or spiky (zero when the run
+	// lasted under a second)
+	MinRequestsPerSecond  int
+	PeakRequestsPerSecond int
+
+	// Requests cut short by stopping the run (Aborted), which are left out
+	// of every other figure so a shutdown doesn't inflate the failures
+	AbortedReqs int
+
+	// Sum of response times over TestDuration, i.e. the average number of
+	// requests in flight. Far below the configured Concurrency, the tester or
+	// its pacing held throughput back rather than the server
+	EffectiveConcurrency float64
+	Concurrency          int
+
+	// Timeout derived from warm-up latency (zero when not adaptive)
+	AdaptiveTimeout time.Duration
+
+	// Time requests spent queued for a worker slot before being sent
+	AverageWaitTime time.Duration
+	MaxWaitTime     time.Duration
+
+	// Connection churn: connections dialed versus idle connections reused
+	ConnectionsOpened     int
+	ConnectionsReused     int
+	RequestsPerConnection float64 // requests served per opened connection
+
+	// TCP connect duration of requests that dialed; ballooning connect
+	// times under load are an early overload signal
+	Connects       int
+	ConnectLatency Percentiles
+
+	// Time dials were held back by the connect rate limit (requests that waited)
+	ConnectWaits       int
+	AverageConnectWait time.Duration
+	MaxConnectWait     time.Duration
+
+	// Transfer rate of each response that had a body, telling transfer-bound
+	// responses apart from server-bound ones
+	Throughput Throughput
+
+	// Time requests were held back by the requests-per-second ceiling
+	// (requests that waited; zero when no ceiling was set or reached)
+	MaxRPS              float64
+	ThrottledReqs       int
+	AverageThrottleWait time.Duration
+	MaxThrottleWait     time.Duration
+
+	// DNS resolution timing (only requests that performed a lookup)
+	DNSLookups     int
+	AverageDNSTime time.Duration
+	MaxDNSTime     time.Duration
+
+	// Successful requests at or under the configured latency target (zero
+	// target disables); failures count against it however fast they were
+	LatencyTarget    time.Duration
+	WithinTargetReqs int
+	AboveTargetReqs  int
+	WithinTargetRate float64
+
+	// Apdex score for the configured target T (zero target disables)
+	ApdexTarget     time.Duration
+	ApdexSatisfied  int
+	ApdexTolerating int
+	ApdexFrustrated int
+	ApdexScore      float64
+	ApdexRating     string
+
+	// Per-second windows from the start of the run, in order
+	TimeSeries []Window
+
+	// Per-step results of a scenario, in scenario order
+	Steps []StepStats
+
+	// Per-tag results of a scenario whose steps are tagged, in order of
+	// first use; untagged steps are left out
+	Tags []TagStats `json:",omitempty"`
+
+	// Per-stage results of a multi-stage run, in the order they ran
+	Stages []StageStats `json:",omitempty"`
 
 	// Response time distribution
 	ResponseTimes []time.Duration
 }
 
-func CollectAndCalculateStats(results chan client.TestResult, testStart time.Time) LoadTestStats {
-	stats := LoadTestStats{
-		MinTime:         time.Hour,
-		ErrorBreakdown:  make(map[errors.ErrorType]int),
-		StatusBreakdown: make(map[int]int),
-		ResponseTimes:   make([]time.Duration, 0),
-		TestDuration:    0,
+// RequestSample identifies a single request of the run.
+type RequestSample struct {
+	Method       string
+	URL          string
+	Step         string `json:",omitempty"`
+	StatusCode   int
+	ErrorType    errors.ErrorType `json:",omitempty"`
+	ResponseTime time.Duration
+}
+
+// sampleOf returns the RequestSample for result.
+func sampleOf(result client.TestResult) *RequestSample {
+	return &RequestSample{
+		Method:       result.Method,
+		URL:          result.URL,
+		Step:         result.Step,
+		StatusCode:   result.StatusCode,
+		ErrorType:    result.ErrorType,
+		ResponseTime: result.ResponseTime,
 	}
-	var totalTime time.Duration
+}
 
-	for result := range results {
-		stats.TotalRequests++
+// Percentiles summarizes a latency distribution.
+type Percentiles struct {
+	Median time.Duration
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// percentilesOf sorts times in place and summarizes them.
+func percentilesOf(times []time.Duration) Percentiles {
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return Percentiles{
+		Median: Percentile(times, 50),
+		P95:    Percentile(times, 95),
+		P99:    Percentile(times, 99),
+	}
+}
+
+// RollingSeconds is how many seconds of results a window's rolling P95
+// covers, so a momentary lull of a few samples doesn't make it jump.
+const RollingSeconds = 10
+
+// Window holds the results that completed during one second of the run.
+type Window struct {
+	Second   int // seconds since the start of the run
+	Requests int
+	Failures int
+	Errors   map[errors.ErrorType]int `json:",omitempty"`
+	// P95 response time over this second and the RollingSeconds-1 before it
+	// (zero when none of them completed a request)
+	P95 time.Duration
+}
+
+// StepStats summarizes the requests made for one scenario step.
+type StepStats struct {
+	Name           string
+	TotalRequests  int
+	SuccessfulReqs int
+	FailedReqs     int
+	SuccessRate    float64
+	AverageTime    time.Duration
+	P95Time        time.Duration
+	P99Time        time.Duration
+}
+
+// TagStats summarizes the requests made for the scenario steps sharing a tag.
+type TagStats struct {
+	Name           string
+	TotalRequests  int
+	SuccessfulReqs int
+	FailedReqs     int
+	SuccessRate    float64
+	AverageTime    time.Duration
+	MedianTime     time.Duration
+	P95Time        time.Duration
+	P99Time        time.Duration
+}
+
+// StageStats summarizes one stage of a multi-stage run.
+type StageStats struct {
+	Name              string
+	Concurrency       int
+	TotalRequests     int
+	SuccessfulReqs    int
+	FailedReqs        int
+	SuccessRate       float64
+	RequestsPerSecond float64
+	AverageTime       time.Duration
+	P95Time           time.Duration
+	P99Time           time.Duration
+	TestDuration      time.Duration
+}
+
+// MinPercentileSamples is the sample count below which P95/P99 are flagged as
+// unreliable: with fewer samples they are little more than the maximum.
+const MinPercentileSamples = 100
+
+// MaxErrorMessages bounds how many distinct error messages are tracked, so a
+// message embedding per-request data can't grow the stats without limit.
+const MaxErrorMessages = 100
+
+// Collector aggregates results as they arrive so that snapshots of the
+// stats-so-far can be taken while a test is still running. It is safe for
+// concurrent use.
+type Collector struct {
+	mu        sync.Mutex
+	testStart time.Time
+	stats     LoadTestStats
+
+	totalTime     time.Duration
+	totalDNSTime  time.Duration
+	totalWaitTime time.Duration
+	totalConnWait time.Duration
+	totalThrottle time.Duration
+	totalRetryAft time.Duration
+
+	// Response times by outcome, for SuccessLatency and FailureLatency
+	successTimes []time.Duration
+	failureTimes []time.Duration
+	connectTimes []time.Duration
+
+	throughputs []float64 // bytes per second of each response with a body
+
+	sawFirst bool // a result tagged First has been recorded
+
+	checksBody bool // body validation is enabled, so BodyCheckedReqs is counted
+	bodyPassed int
+
+	sizeCounts []int // responses per size bucket; nil unless the histogram is enabled
+
+	// Response times by the second they completed in, for the rolling P95
+	windowTimes [][]time.Duration
+
+	// One collector per scenario step, in scenario order
+	stepNames []string
+	steps     map[string]*Collector
+
+	// One collector per step tag, in order of first use
+	tagNames []string
+	tags     map[string]*Collector
+}
+
+func NewCollector(testStart time.Time, config config.RequestConfig) *Collector {
+	c := &Collector{
+		testStart: testStart.Add(config.WarmupDuration),
+		stats: LoadTestStats{
+			WarmupDuration:       config.WarmupDuration,
+			Concurrency:          config.Concurrency,
+			MaxRPS:               config.MaxRPS,
+			LatencyTarget:        config.LatencyTarget,
+			ApdexTarget:          config.ApdexTarget,
+			CertExpiryWarn:       config.CertExpiryWarn,
+			CacheHeader:          config.CacheHeader,
+			FirstRequestExcluded: config.ExcludeFirst,
+			MinTime:              time.Hour,
+			ErrorBreakdown:       make(map[errors.ErrorType]int),
+			ErrorMessages:        make(map[string]int),
+			StatusBreakdown:      make(map[int]int),
+			ProtocolBreakdown:    make(map[string]int),
+			TLSBreakdown:         make(map[string]int),
+			RemoteAddrBreakdown:  make(map[string]int),
+			ResponseTimes:        make([]time.Duration, 0),
+			TestDuration:         0,
+		},
+	}
+	if config.SizeHistogram {
+		c.sizeCounts = make([]int, len(sizeBounds)+1)
+	}
+	c.checksBody = checksBody(config)
+	if len(config.Steps) > 0 {
+		stepConfig := config
+		stepConfig.Steps = nil
+		c.steps = make(map[string]*Collector, len(config.Steps))
+		c.tags = make(map[string]*Collector)
+		for _, step := range config.Steps {
+			c.stepNames = append(c.stepNames, step.Name)
+			c.steps[step.Name] = NewCollector(testStart, stepConfig)
+			if _, seen := c.tags[step.Tag]; step.Tag != "" && !seen {
+				c.tagNames = append(c.tagNames, step.Tag)
+				c.tags[step.Tag] = NewCollector(testStart, stepConfig)
+			}
+		}
+	}
+	return c
+}
+
+// Add records a single result.
+func (c *Collector) Add(result client.TestResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := &c.stats
+
+	// Measurement starts once the warm-up is over
+	elapsed := time.Since(c.testStart)
+	if elapsed < 0 {
+		stats.WarmupDiscarded++
+		return
+	}
+	if result.ErrorType == errors.ErrorTypeAborted {
+		stats.AbortedReqs++
+		return
+	}
+
+	if step, ok := c.steps[result.Step]; ok {
+		step.Add(result)
+	}
+	if tag, ok := c.tags[result.Tag]; ok {
+		tag.Add(result)
+	}
+
+	window := c.window(elapsed)
+	window.Requests++
+	if !result.Success {
+		window.Failures++
+		if result.ErrorType != "" {
+			if window.Errors == nil {
+				window.Errors = make(map[errors.ErrorType]int)
+			}
+			window.Errors[result.ErrorType]++
+		}
+	}
+
+	// Only the run's first request is tagged, but a collector spanning
+	// several runs sees one per run; the earliest is the cold one
+	first := result.First && !c.sawFirst
+	if first {
+		c.sawFirst = true
+		stats.FirstRequestTime = result.ResponseTime
+	}
+	// An excluded first request still counts, it just adds no latency sample
+	sample := !(first && stats.FirstRequestExcluded)
+
+	stats.TotalRequests++
+	if sample {
 		stats.ResponseTimes = append(stats.ResponseTimes, result.ResponseTime)
-		stats.TotalDataTransfer += result.ResponseSize
+		c.windowTimes[window.Second] = append(c.windowTimes[window.Second], result.ResponseTime)
+	}
+	stats.TotalDataTransfer += result.ResponseSize
+	if elapsed := result.ResponseTime + result.BodyTime; sample && result.ResponseSize > 0 && elapsed > 0 {
+		c.throughputs = append(c.throughputs, float64(result.ResponseSize)/elapsed.Seconds())
+	}
+	if c.sizeCounts != nil && result.StatusCode > 0 {
+		c.sizeCounts[sizeBucket(result.ResponseSize)]++
+	}
+	stats.TotalDataSent += result.RequestSize
+	stats.TotalDataSentRaw += result.RequestRawSize
 
-		if result.Success {
-			stats.SuccessfulReqs++
-		} else {
-			stats.FailedReqs++
-			// Track error types
-			if result.ErrorType != "" {
-				stats.ErrorBreakdown[result.ErrorType]++
+	if result.Attempts > 1 {
+		stats.Retries += result.Attempts - 1
+	}
+
+	if result.Success {
+		stats.SuccessfulReqs++
+		if sample {
+			c.successTimes = append(c.successTimes, result.ResponseTime)
+		}
+	} else {
+		stats.FailedReqs++
+		if sample {
+			c.failureTimes = append(c.failureTimes, result.ResponseTime)
+		}
+		// Track error types
+		if result.ErrorType != "" {
+			stats.ErrorBreakdown[result.ErrorType]++
+		}
+		if result.ErrorMessage != "" {
+			if _, seen := stats.ErrorMessages[result.ErrorMessage]; seen || len(stats.ErrorMessages) < MaxErrorMessages {
+				stats.ErrorMessages[result.ErrorMessage]++
+			} else {
+				stats.OtherErrorMessages++
 			}
 		}
+	}
+
+	if result.StatusCode > 0 {
+		stats.StatusBreakdown[result.StatusCode]++
+	}
+	if result.StatusCode == http.StatusTooManyRequests {
+		stats.RateLimitedReqs++
+	}
+	// Only a response with the expected status gets its body checked, and
+	// the latency limit is checked after the body
+	if c.checksBody && (result.Success || result.ErrorType == errors.ErrorTypeBodyValidation || result.ErrorType == errors.ErrorTypeSlowResponse) {
+		stats.BodyCheckedReqs++
+		if result.ErrorType != errors.ErrorTypeBodyValidation {
+			c.bodyPassed++
+		}
+	}
+	if result.RetryAfter > 0 {
+		stats.RetryAfters++
+		c.totalRetryAft += result.RetryAfter
+		if stats.MinRetryAfter == 0 || result.RetryAfter < stats.MinRetryAfter {
+			stats.MinRetryAfter = result.RetryAfter
+		}
+		stats.MaxRetryAfter = max(stats.MaxRetryAfter, result.RetryAfter)
+	}
+	if result.Protocol != "" {
+		stats.ProtocolBreakdown[result.Protocol]++
+	}
+	if result.RemoteAddr != "" {
+		stats.RemoteAddrBreakdown[result.RemoteAddr]++
+	}
+	if result.CacheStatus != "" {
+		if stats.CacheBreakdown == nil {
+			stats.CacheBreakdown = make(map[string]int)
+		}
+		stats.CacheBreakdown[result.CacheStatus]++
+	}
+	if result.TLSVersion != "" {
+		stats.TLSBreakdown[result.TLSVersion+" "+result.TLSCipher]++
+	}
+	if result.Success && stats.CertExpiry.IsZero() {
+		stats.CertExpiry = result.CertExpiry
+	}
+
+	if result.NewConnection {
+		stats.ConnectionsOpened++
+	}
+	if result.ReusedConnection {
+		stats.ConnectionsReused++
+	}
+
+	c.totalWaitTime += result.WaitTime
+	if result.WaitTime > stats.MaxWaitTime {
+		stats.MaxWaitTime = result.WaitTime
+	}
+
+	if result.ConnectTime > 0 {
+		stats.Connects++
+		c.connectTimes = append(c.connectTimes, result.ConnectTime)
+	}
+
+	if result.ConnectWait > 0 {
+		stats.ConnectWaits++
+		c.totalConnWait += result.ConnectWait
+		if result.ConnectWait > stats.MaxConnectWait {
+			stats.MaxConnectWait = result.ConnectWait
+		}
+	}
+
+	if result.ThrottleWait > 0 {
+		stats.ThrottledReqs++
+		c.totalThrottle += result.ThrottleWait
+		stats.MaxThrottleWait = max(stats.MaxThrottleWait, result.ThrottleWait)
+	}
+
+	if result.DNSTime > 0 {
+		stats.DNSLookups++
+		c.totalDNSTime += result.DNSTime
+		if result.DNSTime > stats.MaxDNSTime {
+			stats.MaxDNSTime = result.DNSTime
+		}
+	}
+
+	if stats.LatencyTarget > 0 {
+		// A fast failure, such as a refused connection, didn't meet the target
+		if result.Success && result.ResponseTime <= stats.LatencyTarget {
+			stats.WithinTargetReqs++
+		} else {
+			stats.AboveTargetReqs++
+		}
+	}
+
+	if stats.ApdexTarget > 0 {
+		// Failed requests always count as frustrated
+		switch {
+		case !result.Success || result.ResponseTime > 4*stats.ApdexTarget:
+			stats.ApdexFrustrated++
+		case result.ResponseTime > stats.ApdexTarget:
+			stats.ApdexTolerating++
+		default:
+			stats.ApdexSatisfied++
+		}
+	}
 
-		if result.StatusCode > 0 {
-			stats.StatusBreakdown[result.StatusCode]++
+	if !sample {
+		return
+	}
+	c.totalTime += result.ResponseTime
+	// Samples are replaced rather than updated, so snapshots can share them
+	if result.ResponseTime < stats.MinTime || stats.Fastest == nil {
+		stats.MinTime = result.ResponseTime
+		stats.Fastest = sampleOf(result)
+	}
+	if result.ResponseTime > stats.MaxTime || stats.Slowest == nil {
+		stats.MaxTime = result.ResponseTime
+		stats.Slowest = sampleOf(result)
+	}
+}
+
+// checksBody reports whether config validates response bodies, for the run
+// or any of its steps.
+func checksBody(config config.RequestConfig) bool {
+	if len(config.AcceptedBodies()) > 0 || config.BodyNotContains != "" || config.BodyValidator != nil || config.MinResponseSize > 0 {
+		return true
+	}
+	for _, step := range config.Steps {
+		if step.ExpectedBody != "" {
+			return true
 		}
+	}
+	return false
+}
 
-		totalTime += result.ResponseTime
-		if result.ResponseTime < stats.MinTime {
-			stats.MinTime = result.ResponseTime
+// rpsRange returns the fewest and most requests completed in any whole
+// second of a run lasting d. The final, partial second is left out, and
+// seconds without a window completed no requests.
+func rpsRange(series []Window, d time.Duration) (lowest, peak int) {
+	seconds := int(d / time.Second)
+	for second := 0; second < seconds; second++ {
+		requests := 0
+		if second < len(series) {
+			requests = series[second].Requests
 		}
-		if result.ResponseTime > stats.MaxTime {
-			stats.MaxTime = result.ResponseTime
+		if second == 0 || requests < lowest {
+			lowest = requests
 		}
+		peak = max(peak, requests)
+	}
+	return lowest, peak
+}
+
+// window returns the time series window for elapsed, adding empty windows
+// for any seconds without results so the series stays contiguous.
+func (c *Collector) window(elapsed time.Duration) *Window {
+	second := int(elapsed / time.Second)
+	if second < 0 {
+		second = 0
+	}
+	series := &c.stats.TimeSeries
+	for len(*series) <= second {
+		*series = append(*series, Window{Second: len(*series)})
+		c.windowTimes = append(c.windowTimes, nil)
 	}
+	return &(*series)[second]
+}
 
-	stats.TestDuration = time.Since(testStart)
+// Snapshot returns the stats for everything recorded so far. The returned
+// value does not share state with the collector.
+func (c *Collector) Snapshot() LoadTestStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.ErrorBreakdown = make(map[errors.ErrorType]int, len(c.stats.ErrorBreakdown))
+	for errorType, count := range c.stats.ErrorBreakdown {
+		stats.ErrorBreakdown[errorType] = count
+	}
+	stats.ErrorCodes = make(map[string]int, len(c.stats.ErrorBreakdown))
+	for errorType, count := range c.stats.ErrorBreakdown {
+		stats.ErrorCodes[errorType.Code()] += count
+	}
+	stats.ErrorMessages = make(map[string]int, len(c.stats.ErrorMessages))
+	for msg, count := range c.stats.ErrorMessages {
+		stats.ErrorMessages[msg] = count
+	}
+	stats.StatusBreakdown = make(map[int]int, len(c.stats.StatusBreakdown))
+	for code, count := range c.stats.StatusBreakdown {
+		stats.StatusBreakdown[code] = count
+	}
+	stats.ProtocolBreakdown = make(map[string]int, len(c.stats.ProtocolBreakdown))
+	for proto, count := range c.stats.ProtocolBreakdown {
+		stats.ProtocolBreakdown[proto] = count
+	}
+	stats.RemoteAddrBreakdown = make(map[string]int, len(c.stats.RemoteAddrBreakdown))
+	for addr, count := range c.stats.RemoteAddrBreakdown {
+		stats.RemoteAddrBreakdown[addr] = count
+	}
+	if c.stats.CacheBreakdown != nil {
+		stats.CacheBreakdown = make(map[string]int, len(c.stats.CacheBreakdown))
+		hits, total := 0, 0
+		for value, count := range c.stats.CacheBreakdown {
+			stats.CacheBreakdown[value] = count
+			total += count
+			if strings.Contains(strings.ToUpper(value), "HIT") {
+				hits += count
+			}
+		}
+		stats.CacheHitRate = float64(hits) / float64(total) * 100
+	}
+	stats.TimeSeries = make([]Window, len(c.stats.TimeSeries))
+	for i, window := range c.stats.TimeSeries {
+		if window.Errors != nil {
+			errs := make(map[errors.ErrorType]int, len(window.Errors))
+			for errorType, count := range window.Errors {
+				errs[errorType] = count
+			}
+			window.Errors = errs
+		}
+		var rolling []time.Duration
+		for _, times := range c.windowTimes[max(0, i-RollingSeconds+1) : i+1] {
+			rolling = append(rolling, times...)
+		}
+		window.P95 = percentilesOf(rolling).P95
+		stats.TimeSeries[i] = window
+	}
+	stats.TLSBreakdown = make(map[string]int, len(c.stats.TLSBreakdown))
+	for negotiated, count := range c.stats.TLSBreakdown {
+		stats.TLSBreakdown[negotiated] = count
+	}
+	stats.ResponseTimes = append(make([]time.Duration, 0, len(c.stats.ResponseTimes)), c.stats.ResponseTimes...)
+
+	stats.TestDuration = max(0, time.Since(c.testStart))
+
+	if c.sizeCounts != nil {
+		stats.SizeHistogram = sizeHistogram(c.sizeCounts)
+	}
+
+	for _, name := range c.stepNames {
+		step := c.steps[name].Snapshot()
+		stats.Steps = append(stats.Steps, StepStats{
+			Name:           name,
+			TotalRequests:  step.TotalRequests,
+			SuccessfulReqs: step.SuccessfulReqs,
+			FailedReqs:     step.FailedReqs,
+			SuccessRate:    step.SuccessRate,
+			AverageTime:    step.AverageTime,
+			P95Time:        step.P95Time,
+			P99Time:        step.P99Time,
+		})
+	}
+	for _, name := range c.tagNames {
+		tag := c.tags[name].Snapshot()
+		stats.Tags = append(stats.Tags, TagStats{
+			Name:           name,
+			TotalRequests:  tag.TotalRequests,
+			SuccessfulReqs: tag.SuccessfulReqs,
+			FailedReqs:     tag.FailedReqs,
+			SuccessRate:    tag.SuccessRate,
+			AverageTime:    tag.AverageTime,
+			MedianTime:     tag.MedianTime,
+			P95Time:        tag.P95Time,
+			P99Time:        tag.P99Time,
+		})
+	}
+
+	if stats.ConnectionsOpened > 0 {
+		stats.RequestsPerConnection = float64(stats.ConnectionsOpened+stats.ConnectionsReused) / float64(stats.ConnectionsOpened)
+	}
+
+	if stats.Connects > 0 {
+		stats.ConnectLatency = percentilesOf(append([]time.Duration(nil), c.connectTimes...))
+	}
+	stats.Throughput = throughputOf(append([]float64(nil), c.throughputs...))
+
+	if stats.RetryAfters > 0 {
+		stats.AverageRetryAfter = c.totalRetryAft / time.Duration(stats.RetryAfters)
+	}
+
+	if stats.ConnectWaits > 0 {
+		stats.AverageConnectWait = c.totalConnWait / time.Duration(stats.ConnectWaits)
+	}
+	if stats.ThrottledReqs > 0 {
+		stats.AverageThrottleWait = c.totalThrottle / time.Duration(stats.ThrottledReqs)
+	}
+
+	if stats.DNSLookups > 0 {
+		stats.AverageDNSTime = c.totalDNSTime / time.Duration(stats.DNSLookups)
+	}
 
 	if stats.TotalRequests > 0 {
 		stats.SuccessRate = float64(stats.SuccessfulReqs) / float64(stats.TotalRequests) * 100
-		stats.AverageTime = totalTime / time.Duration(stats.TotalRequests)
+		stats.ErrorRate = float64(stats.FailedReqs) / float64(stats.TotalRequests) * 100
+		stats.RateLimitedRate = float64(stats.RateLimitedReqs) / float64(stats.TotalRequests) * 100
+		if stats.BodyCheckedReqs > 0 {
+			stats.BodyPassRate = float64(c.bodyPassed) / float64(stats.BodyCheckedReqs) * 100
+		}
+		stats.AverageWaitTime = c.totalWaitTime / time.Duration(stats.TotalRequests)
 		stats.RequestsPerSecond = float64(stats.TotalRequests) / stats.TestDuration.Seconds()
+		stats.MinRequestsPerSecond, stats.PeakRequestsPerSecond = rpsRange(stats.TimeSeries, stats.TestDuration)
+		stats.WithinTargetRate = float64(stats.WithinTargetReqs) / float64(stats.TotalRequests) * 100
+		if stats.ApdexTarget > 0 {
+			stats.ApdexScore = (float64(stats.ApdexSatisfied) + float64(stats.ApdexTolerating)/2) / float64(stats.TotalRequests)
+			stats.ApdexRating = apdexRating(stats.ApdexScore)
+		}
 
 		// Calculate percentiles
 		sort.Slice(stats.ResponseTimes, func(i, j int) bool {
@@ -83,22 +785,85 @@ func CollectAndCalculateStats(results chan client.TestResult, testStart time.Tim
 		})
 
 		if len(stats.ResponseTimes) > 0 {
-			stats.MedianTime = percentile(stats.ResponseTimes, 50)
-			stats.P95Time = percentile(stats.ResponseTimes, 95)
-			stats.P99Time = percentile(stats.ResponseTimes, 99)
+			stats.AverageTime = c.totalTime / time.Duration(len(stats.ResponseTimes))
+			stats.MedianTime = Percentile(stats.ResponseTimes, 50)
+			stats.P95Time = Percentile(stats.ResponseTimes, 95)
+			stats.P99Time = Percentile(stats.ResponseTimes, 99)
+			stats.StdDevTime, stats.LatencyCV = spread(stats.ResponseTimes)
+			if stats.TestDuration > 0 {
+				stats.EffectiveConcurrency = c.totalTime.Seconds() / stats.TestDuration.Seconds()
+			}
 		}
+		stats.PercentilesUnreliable = len(stats.ResponseTimes) < MinPercentileSamples
+		stats.SuccessLatency = percentilesOf(append([]time.Duration(nil), c.successTimes...))
+		stats.FailureLatency = percentilesOf(append([]time.Duration(nil), c.failureTimes...))
 	}
 
 	return stats
 }
 
-func percentile(sortedTimes []time.Duration, p int) time.Duration {
-	if len(sortedTimes) == 0 {
-		return 0
+// spread returns the population standard deviation of times and its
+// coefficient of variation (stddev/mean).
+func spread(times []time.Duration) (time.Duration, float64) {
+	var sum float64
+	for _, t := range times {
+		sum += float64(t)
+	}
+	mean := sum / float64(len(times))
+	var squares float64
+	for _, t := range times {
+		d := float64(t) - mean
+		squares += d * d
+	}
+	stddev := math.Sqrt(squares / float64(len(times)))
+	if mean == 0 {
+		return time.Duration(stddev), 0
+	}
+	return time.Duration(stddev), stddev / mean
+}
+
+func CollectAndCalculateStats(results chan client.TestResult, testStart time.Time, config config.RequestConfig) LoadTestStats {
+	collector := NewCollector(testStart, config)
+	for result := range results {
+		collector.Add(result)
+	}
+	return collector.Snapshot()
+}
+
+// Passed reports whether the run met its assertions: at least one request was
+// made and none of them failed.
+func (s LoadTestStats) Passed() bool {
+	return s.TotalRequests > 0 && s.FailedReqs == 0
+}
+
+// apdexRating maps an Apdex score to the standard rating bands.
+func apdexRating(score float64) string {
+	switch {
+	case score >= 0.94:
+		return "Excellent"
+	case score >= 0.85:
+		return "Good"
+	case score >= 0.70:
+		return "Fair"
+	case score >= 0.50:
+		return "Poor"
+	default:
+		return "Unacceptable"
+	}
+}
+
+// Percentile returns the p-th percentile of sorted by the nearest-rank
+// method without interpolation: the sample at index n*p/100, rounded down and
+// capped at the last, i.e. the smallest value more than p% of samples are at
+// or under. VerifyPercentiles checks results against this definition.
+func Percentile[T cmp.Ordered](sorted []T, p int) T {
+	if len(sorted) == 0 {
+		var zero T
+		return zero
 	}
-	index := (len(sortedTimes) * p / 100)
-	if index >= len(sortedTimes) {
-		index = len(sortedTimes) - 1
+	index := (len(sorted) * p / 100)
+	if index >= len(sorted) {
+		index = len(sorted) - 1
 	}
-	return sortedTimes[index]
+	return sorted[index]
 }
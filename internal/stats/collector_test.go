@@ -1,8 +1,11 @@
 package stats
 
 import (
+	"fmt"
 	"loadtester/internal/client"
+	"loadtester/internal/config"
 	"loadtester/internal/errors"
+	"math"
 	"testing"
 	"time"
 )
@@ -28,7 +31,7 @@ func TestCollectAndCalculateStats_BasicStats(t *testing.T) {
 	results <- makeResult(true, 200, 500*time.Millisecond, errors.ErrorTypeNone, 500)
 	close(results)
 
-	stats := CollectAndCalculateStats(results, start)
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{})
 
 	if stats.TotalRequests != 5 {
 		t.Errorf("Expected 5 requests, got %d", stats.TotalRequests)
@@ -42,6 +45,9 @@ func TestCollectAndCalculateStats_BasicStats(t *testing.T) {
 	if stats.SuccessRate != 60 {
 		t.Errorf("Expected success rate 60, got %f", stats.SuccessRate)
 	}
+	if stats.ErrorRate != 40 {
+		t.Errorf("Expected error rate 40, got %f", stats.ErrorRate)
+	}
 	if stats.MinTime != 100*time.Millisecond {
 		t.Errorf("Expected min time 100ms, got %v", stats.MinTime)
 	}
@@ -51,6 +57,13 @@ func TestCollectAndCalculateStats_BasicStats(t *testing.T) {
 	if stats.AverageTime != 300*time.Millisecond {
 		t.Errorf("Expected average time 300ms, got %v", stats.AverageTime)
 	}
+	// Population stddev of 100..500ms is sqrt(20000)ms
+	if want := time.Duration(math.Sqrt(20000) * float64(time.Millisecond)); stats.StdDevTime < want-time.Microsecond || stats.StdDevTime > want+time.Microsecond {
+		t.Errorf("Expected stddev %v, got %v", want, stats.StdDevTime)
+	}
+	if math.Abs(stats.LatencyCV-math.Sqrt(20000)/300) > 1e-6 {
+		t.Errorf("Expected CV %.4f, got %.4f", math.Sqrt(20000)/300, stats.LatencyCV)
+	}
 	if stats.TotalDataTransfer != 1500 {
 		t.Errorf("Expected total data transfer 1500, got %d", stats.TotalDataTransfer)
 	}
@@ -73,7 +86,7 @@ func TestCollectAndCalculateStats_Percentiles(t *testing.T) {
 	results <- makeResult(true, 200, 500*time.Millisecond, errors.ErrorTypeNone, 500)
 	close(results)
 
-	stats := CollectAndCalculateStats(results, start)
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{})
 
 	if stats.MedianTime != 300*time.Millisecond {
 		t.Errorf("Expected median time 300ms, got %v", stats.MedianTime)
@@ -87,14 +100,643 @@ func TestCollectAndCalculateStats_Percentiles(t *testing.T) {
 }
 
 func TestPercentile_EmptySlice(t *testing.T) {
-	if percentile([]time.Duration{}, 50) != 0 {
+	if Percentile([]time.Duration{}, 50) != 0 {
 		t.Error("Expected percentile of empty slice to be 0")
 	}
 }
 
 func TestPercentile_Bounds(t *testing.T) {
 	times := []time.Duration{10, 20, 30, 40, 50}
-	if percentile(times, 100) != 50 {
-		t.Errorf("Expected 100th percentile to be 50, got %v", percentile(times, 100))
+	if Percentile(times, 100) != 50 {
+		t.Errorf("Expected 100th percentile to be 50, got %v", Percentile(times, 100))
+	}
+}
+
+func TestCollectAndCalculateStats_DNSTiming(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	start := time.Now()
+
+	r1 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r1.DNSTime = 10 * time.Millisecond
+	r2 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r2.DNSTime = 30 * time.Millisecond
+	results <- r1
+	results <- r2
+	results <- makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{})
+
+	if stats.DNSLookups != 2 {
+		t.Errorf("Expected 2 DNS lookups, got %d", stats.DNSLookups)
+	}
+	if stats.AverageDNSTime != 20*time.Millisecond {
+		t.Errorf("Expected average DNS time 20ms, got %v", stats.AverageDNSTime)
+	}
+	if stats.MaxDNSTime != 30*time.Millisecond {
+		t.Errorf("Expected max DNS time 30ms, got %v", stats.MaxDNSTime)
+	}
+}
+
+func TestCollectAndCalculateStats_LatencyTarget(t *testing.T) {
+	results := make(chan client.TestResult, 4)
+	start := time.Now()
+
+	results <- makeResult(true, 200, 50*time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(true, 200, 150*time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(false, 500, 80*time.Millisecond, errors.ErrorTypeServerError, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{LatencyTarget: 100 * time.Millisecond})
+
+	// The fast failure doesn't count as within target
+	if stats.WithinTargetReqs != 2 {
+		t.Errorf("Expected 2 requests within target, got %d", stats.WithinTargetReqs)
+	}
+	if stats.AboveTargetReqs != 2 {
+		t.Errorf("Expected 2 requests above target, got %d", stats.AboveTargetReqs)
+	}
+	if stats.WithinTargetRate != 50 {
+		t.Errorf("Expected within-target rate 50, got %f", stats.WithinTargetRate)
+	}
+}
+
+func TestCollectAndCalculateStats_ApdexBoundaries(t *testing.T) {
+	results := make(chan client.TestResult, 5)
+	start := time.Now()
+
+	target := 100 * time.Millisecond
+	results <- makeResult(true, 200, target, errors.ErrorTypeNone, 0)                      // satisfied (== T)
+	results <- makeResult(true, 200, target+1, errors.ErrorTypeNone, 0)                    // tolerating (just over T)
+	results <- makeResult(true, 200, 4*target, errors.ErrorTypeNone, 0)                    // tolerating (== 4T)
+	results <- makeResult(true, 200, 4*target+1, errors.ErrorTypeNone, 0)                  // frustrated (just over 4T)
+	results <- makeResult(false, 500, 10*time.Millisecond, errors.ErrorTypeServerError, 0) // frustrated (failed)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{ApdexTarget: target})
+
+	if stats.ApdexSatisfied != 1 || stats.ApdexTolerating != 2 || stats.ApdexFrustrated != 2 {
+		t.Errorf("Apdex buckets incorrect: satisfied=%d tolerating=%d frustrated=%d",
+			stats.ApdexSatisfied, stats.ApdexTolerating, stats.ApdexFrustrated)
+	}
+	if stats.ApdexScore != 0.4 {
+		t.Errorf("Expected Apdex score 0.4, got %f", stats.ApdexScore)
+	}
+	if stats.ApdexRating != "Unacceptable" {
+		t.Errorf("Expected rating Unacceptable, got %s", stats.ApdexRating)
+	}
+}
+
+func TestCollectAndCalculateStats_ApdexDisabled(t *testing.T) {
+	results := make(chan client.TestResult, 1)
+	results <- makeResult(true, 200, time.Second, errors.ErrorTypeNone, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.ApdexScore != 0 || stats.ApdexRating != "" {
+		t.Errorf("Expected no Apdex score without a target, got %f (%s)", stats.ApdexScore, stats.ApdexRating)
+	}
+}
+
+func TestApdexRating_Bands(t *testing.T) {
+	cases := map[float64]string{
+		1.0:  "Excellent",
+		0.94: "Excellent",
+		0.93: "Good",
+		0.85: "Good",
+		0.70: "Fair",
+		0.50: "Poor",
+		0.49: "Unacceptable",
+	}
+	for score, want := range cases {
+		if got := apdexRating(score); got != want {
+			t.Errorf("apdexRating(%.2f) = %s, want %s", score, got, want)
+		}
+	}
+}
+
+func TestLoadTestStats_Passed(t *testing.T) {
+	if (LoadTestStats{}).Passed() {
+		t.Error("Expected a run without requests not to pass")
+	}
+	if !(LoadTestStats{TotalRequests: 3, SuccessfulReqs: 3}).Passed() {
+		t.Error("Expected a run without failures to pass")
+	}
+	if (LoadTestStats{TotalRequests: 3, SuccessfulReqs: 2, FailedReqs: 1}).Passed() {
+		t.Error("Expected a run with failures not to pass")
+	}
+}
+
+func TestCollectAndCalculateStats_WaitTime(t *testing.T) {
+	results := make(chan client.TestResult, 2)
+	r1 := makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+	r1.WaitTime = 10 * time.Millisecond
+	r2 := makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+	r2.WaitTime = 30 * time.Millisecond
+	results <- r1
+	results <- r2
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.AverageWaitTime != 20*time.Millisecond {
+		t.Errorf("Expected average wait time 20ms, got %v", stats.AverageWaitTime)
+	}
+	if stats.MaxWaitTime != 30*time.Millisecond {
+		t.Errorf("Expected max wait time 30ms, got %v", stats.MaxWaitTime)
+	}
+}
+
+func TestCollectAndCalculateStats_ConnectWait(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	for _, wait := range []time.Duration{0, 10 * time.Millisecond, 50 * time.Millisecond} {
+		r := makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+		r.ConnectWait = wait
+		results <- r
+	}
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.ConnectWaits != 2 {
+		t.Errorf("Expected 2 requests held back, got %d", stats.ConnectWaits)
+	}
+	if stats.AverageConnectWait != 30*time.Millisecond || stats.MaxConnectWait != 50*time.Millisecond {
+		t.Errorf("Expected avg 30ms and max 50ms, got %v and %v", stats.AverageConnectWait, stats.MaxConnectWait)
+	}
+}
+
+func TestCollectAndCalculateStats_RateLimiting(t *testing.T) {
+	results := make(chan client.TestResult, 4)
+	results <- makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+	for _, retryAfter := range []time.Duration{0, time.Second, 3 * time.Second} {
+		r := makeResult(false, 429, 10*time.Millisecond, errors.ErrorTypeRateLimited, 0)
+		r.RetryAfter = retryAfter
+		results <- r
+	}
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.RateLimitedReqs != 3 || stats.RateLimitedRate != 75 {
+		t.Errorf("Expected 3 rate-limited requests (75%%), got %d (%.2f%%)", stats.RateLimitedReqs, stats.RateLimitedRate)
+	}
+	if stats.RetryAfters != 2 || stats.MinRetryAfter != time.Second || stats.MaxRetryAfter != 3*time.Second || stats.AverageRetryAfter != 2*time.Second {
+		t.Errorf("Expected Retry-After min=1s avg=2s max=3s over 2 responses, got min=%v avg=%v max=%v over %d",
+			stats.MinRetryAfter, stats.AverageRetryAfter, stats.MaxRetryAfter, stats.RetryAfters)
+	}
+}
+
+func TestCollector_Tags(t *testing.T) {
+	cfg := config.RequestConfig{Steps: []config.Step{
+		{Name: "home", Tag: "browse"},
+		{Name: "cart", Tag: "checkout"},
+		{Name: "search", Tag: "browse"},
+		{Name: "health"},
+	}}
+	c := NewCollector(time.Now(), cfg)
+	add := func(step, tag string, success bool, rt time.Duration) {
+		r := makeResult(success, 200, rt, errors.ErrorTypeNone, 0)
+		r.Step, r.Tag = step, tag
+		c.Add(r)
+	}
+	add("home", "browse", true, 10*time.Millisecond)
+	add("search", "browse", true, 30*time.Millisecond)
+	add("cart", "checkout", false, 50*time.Millisecond)
+	add("health", "", true, time.Millisecond)
+
+	stats := c.Snapshot()
+
+	if len(stats.Tags) != 2 || stats.Tags[0].Name != "browse" || stats.Tags[1].Name != "checkout" {
+		t.Fatalf("Expected browse and checkout in order of first use, got %+v", stats.Tags)
+	}
+	browse, checkout := stats.Tags[0], stats.Tags[1]
+	if browse.TotalRequests != 2 || browse.SuccessRate != 100 || browse.AverageTime != 20*time.Millisecond {
+		t.Errorf("Expected both browse steps grouped, got %+v", browse)
+	}
+	if checkout.TotalRequests != 1 || checkout.FailedReqs != 1 {
+		t.Errorf("Expected one failed checkout request, got %+v", checkout)
+	}
+}
+
+func TestCollector_Snapshot(t *testing.T) {
+	collector := NewCollector(time.Now(), config.RequestConfig{})
+	collector.Add(makeResult(true, 200, 300*time.Millisecond, errors.ErrorTypeNone, 10))
+	collector.Add(makeResult(false, 500, 100*time.Millisecond, errors.ErrorTypeServerError, 10))
+
+	first := collector.Snapshot()
+	if first.TotalRequests != 2 || first.FailedReqs != 1 {
+		t.Errorf("Unexpected interim snapshot: %d requests, %d failed", first.TotalRequests, first.FailedReqs)
+	}
+
+	collector.Add(makeResult(true, 200, 200*time.Millisecond, errors.ErrorTypeNone, 10))
+	second := collector.Snapshot()
+
+	if second.TotalRequests != 3 || second.MedianTime != 200*time.Millisecond {
+		t.Errorf("Unexpected snapshot after more results: %d requests, median %v", second.TotalRequests, second.MedianTime)
+	}
+	// Earlier snapshots must not change as the collector keeps aggregating
+	if len(first.ResponseTimes) != 2 || first.StatusBreakdown[200] != 1 {
+		t.Errorf("Earlier snapshot was modified: %v, %v", first.ResponseTimes, first.StatusBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_ProtocolBreakdown(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	for _, proto := range []string{"HTTP/2.0", "HTTP/2.0", ""} {
+		r := makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0)
+		r.Protocol = proto
+		results <- r
+	}
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if len(stats.ProtocolBreakdown) != 1 || stats.ProtocolBreakdown["HTTP/2.0"] != 2 {
+		t.Errorf("Protocol breakdown incorrect: %+v", stats.ProtocolBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_RemoteAddrBreakdown(t *testing.T) {
+	results := make(chan client.TestResult, 4)
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.1", ""} {
+		r := makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0)
+		r.RemoteAddr = addr
+		results <- r
+	}
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if len(stats.RemoteAddrBreakdown) != 2 || stats.RemoteAddrBreakdown["10.0.0.1"] != 2 || stats.RemoteAddrBreakdown["10.0.0.2"] != 1 {
+		t.Errorf("Remote address breakdown incorrect: %+v", stats.RemoteAddrBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_Aborted(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	results <- makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(false, 0, 5*time.Millisecond, errors.ErrorTypeTimeout, 0)
+	results <- makeResult(false, 0, time.Second, errors.ErrorTypeAborted, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.AbortedReqs != 1 {
+		t.Errorf("Expected 1 aborted request, got %d", stats.AbortedReqs)
+	}
+	if stats.TotalRequests != 2 || stats.FailedReqs != 1 || stats.ErrorRate != 50 {
+		t.Errorf("Expected the aborted request to be left out of the error rate, got %d requests, %d failed (%.2f%%)",
+			stats.TotalRequests, stats.FailedReqs, stats.ErrorRate)
+	}
+	if stats.MaxTime != 5*time.Millisecond || stats.ErrorBreakdown[errors.ErrorTypeAborted] != 0 {
+		t.Errorf("Expected no latency or error entry for the aborted request, got max %v and %v", stats.MaxTime, stats.ErrorBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_CacheBreakdown(t *testing.T) {
+	results := make(chan client.TestResult, 4)
+	for _, value := range []string{"HIT", "TCP_HIT", "MISS", "(none)"} {
+		r := makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0)
+		r.CacheStatus = value
+		results <- r
+	}
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{CacheHeader: "X-Cache"})
+
+	if stats.CacheHeader != "X-Cache" || len(stats.CacheBreakdown) != 4 || stats.CacheBreakdown["MISS"] != 1 {
+		t.Errorf("Cache breakdown incorrect: %q %+v", stats.CacheHeader, stats.CacheBreakdown)
+	}
+	if stats.CacheHitRate != 50 {
+		t.Errorf("Expected a 50%% hit rate, got %.2f", stats.CacheHitRate)
+	}
+}
+
+func TestCollectAndCalculateStats_FirstRequest(t *testing.T) {
+	for _, exclude := range []bool{false, true} {
+		results := make(chan client.TestResult, 4)
+		first := makeResult(true, 200, 300*time.Millisecond, errors.ErrorTypeNone, 0)
+		first.First = true
+		results <- first
+		for i := 0; i < 2; i++ {
+			results <- makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+		}
+		// A later run's first request is not the cold one
+		again := makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+		again.First = true
+		results <- again
+		close(results)
+
+		stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{ExcludeFirst: exclude})
+
+		if stats.FirstRequestTime != 300*time.Millisecond || stats.FirstRequestExcluded != exclude {
+			t.Errorf("exclude=%v: expected a 300ms first request, got %v (excluded %v)", exclude, stats.FirstRequestTime, stats.FirstRequestExcluded)
+		}
+		if stats.TotalRequests != 4 {
+			t.Errorf("exclude=%v: expected the first request to still count, got %d requests", exclude, stats.TotalRequests)
+		}
+		wantMax, wantSamples := 300*time.Millisecond, 4
+		if exclude {
+			wantMax, wantSamples = 10*time.Millisecond, 3
+		}
+		if stats.MaxTime != wantMax || len(stats.ResponseTimes) != wantSamples || stats.AverageTime > wantMax {
+			t.Errorf("exclude=%v: expected max %v over %d samples, got %v over %d (avg %v)",
+				exclude, wantMax, wantSamples, stats.MaxTime, len(stats.ResponseTimes), stats.AverageTime)
+		}
+	}
+}
+
+func TestCollectAndCalculateStats_ConnectionChurn(t *testing.T) {
+	results := make(chan client.TestResult, 5)
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		r := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+		r.NewConnection = i%2 == 0
+		r.ReusedConnection = !r.NewConnection
+		results <- r
+	}
+	// A request that never got a connection counts towards neither
+	results <- makeResult(false, 0, 100*time.Millisecond, errors.ErrorTypeConnection, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, start, config.RequestConfig{})
+
+	if stats.ConnectionsOpened != 2 || stats.ConnectionsReused != 2 {
+		t.Errorf("Expected 2 opened and 2 reused connections, got %d and %d", stats.ConnectionsOpened, stats.ConnectionsReused)
+	}
+	if stats.RequestsPerConnection != 2 {
+		t.Errorf("Expected 2 requests per connection, got %v", stats.RequestsPerConnection)
+	}
+}
+
+func TestCollectAndCalculateStats_ErrorCodes(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	results <- makeResult(false, 500, 100*time.Millisecond, errors.ErrorTypeServerError, 0)
+	results <- makeResult(false, 503, 100*time.Millisecond, errors.ErrorTypeServerError, 0)
+	results <- makeResult(false, 0, 100*time.Millisecond, errors.ErrorTypeTimeout, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.ErrorCodes["server_error"] != 2 || stats.ErrorCodes["timeout"] != 1 {
+		t.Errorf("Expected error counts keyed by code, got %v", stats.ErrorCodes)
+	}
+	if stats.ErrorBreakdown[errors.ErrorTypeServerError] != 2 {
+		t.Errorf("Expected display-name breakdown to be kept, got %v", stats.ErrorBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_Retries(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	r1 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r1.Attempts = 3
+	r2 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r2.Attempts = 1
+	results <- r1
+	results <- r2
+	results <- makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.Retries != 2 {
+		t.Errorf("Expected 2 retries, got %d", stats.Retries)
+	}
+}
+
+func TestCollectAndCalculateStats_PercentilesUnreliable(t *testing.T) {
+	collector := NewCollector(time.Now(), config.RequestConfig{})
+	for i := 0; i < MinPercentileSamples-1; i++ {
+		collector.Add(makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0))
+	}
+	if !collector.Snapshot().PercentilesUnreliable {
+		t.Errorf("Expected percentiles from %d samples to be flagged", MinPercentileSamples-1)
+	}
+
+	collector.Add(makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0))
+	if collector.Snapshot().PercentilesUnreliable {
+		t.Errorf("Expected percentiles from %d samples to be reliable", MinPercentileSamples)
+	}
+}
+
+func TestCollectAndCalculateStats_TLSBreakdown(t *testing.T) {
+	results := make(chan client.TestResult, 2)
+	r := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r.TLSVersion, r.TLSCipher = "TLS 1.3", "TLS_AES_128_GCM_SHA256"
+	results <- r
+	results <- makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if len(stats.TLSBreakdown) != 1 || stats.TLSBreakdown["TLS 1.3 TLS_AES_128_GCM_SHA256"] != 1 {
+		t.Errorf("Expected one TLS 1.3 entry, got %v", stats.TLSBreakdown)
+	}
+}
+
+func TestCollectAndCalculateStats_ErrorMessages(t *testing.T) {
+	collector := NewCollector(time.Now(), config.RequestConfig{})
+	fail := func(msg string) client.TestResult {
+		r := makeResult(false, 0, 100*time.Millisecond, errors.ErrorTypeDNS, 0)
+		r.ErrorMessage = msg
+		return r
+	}
+	collector.Add(fail("no such host a.test"))
+	collector.Add(fail("no such host a.test"))
+	collector.Add(fail("no such host b.test"))
+	for i := 0; i < MaxErrorMessages; i++ {
+		collector.Add(fail(fmt.Sprintf("unique %d", i)))
+	}
+	collector.Add(fail("no such host b.test"))
+
+	stats := collector.Snapshot()
+
+	if len(stats.ErrorMessages) != MaxErrorMessages {
+		t.Errorf("Expected distinct messages capped at %d, got %d", MaxErrorMessages, len(stats.ErrorMessages))
+	}
+	if stats.ErrorMessages["no such host a.test"] != 2 || stats.ErrorMessages["no such host b.test"] != 2 {
+		t.Errorf("Expected already-tracked messages to keep counting past the cap, got %v", stats.ErrorMessages)
+	}
+	if stats.OtherErrorMessages != 2 {
+		t.Errorf("Expected 2 failures beyond the cap, got %d", stats.OtherErrorMessages)
+	}
+}
+
+func TestCollector_TimeSeries(t *testing.T) {
+	// Results land in the window for the elapsed time when they're added
+	collector := NewCollector(time.Now().Add(-2500*time.Millisecond), config.RequestConfig{})
+	collector.Add(makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0))
+	collector.Add(makeResult(false, 0, 100*time.Millisecond, errors.ErrorTypeTimeout, 0))
+	collector.Add(makeResult(false, 0, 100*time.Millisecond, errors.ErrorTypeTimeout, 0))
+
+	stats := collector.Snapshot()
+
+	if len(stats.TimeSeries) != 3 {
+		t.Fatalf("Expected contiguous windows for seconds 0-2, got %+v", stats.TimeSeries)
+	}
+	if w := stats.TimeSeries[0]; w.Requests != 0 || w.Errors != nil {
+		t.Errorf("Expected an empty first window, got %+v", w)
+	}
+	w := stats.TimeSeries[2]
+	if w.Second != 2 || w.Requests != 3 || w.Failures != 2 || w.Errors[errors.ErrorTypeTimeout] != 2 {
+		t.Errorf("Expected 2 timeouts out of 3 requests at t=2s, got %+v", w)
+	}
+
+	// Snapshots must not share the per-window maps
+	stats.TimeSeries[2].Errors[errors.ErrorTypeTimeout] = 99
+	if collector.Snapshot().TimeSeries[2].Errors[errors.ErrorTypeTimeout] != 2 {
+		t.Error("Expected snapshot windows to be independent copies")
+	}
+}
+
+func TestRPSRange(t *testing.T) {
+	series := []Window{{Second: 0, Requests: 8}, {Second: 1, Requests: 12}, {Second: 2, Requests: 30}}
+
+	// The last window is the partial second the run ended in
+	if lowest, peak := rpsRange(series, 2500*time.Millisecond); lowest != 8 || peak != 12 {
+		t.Errorf("Expected min 8 and peak 12 over whole seconds, got %d and %d", lowest, peak)
+	}
+	// Seconds after the last window completed nothing
+	if lowest, peak := rpsRange(series, 4*time.Second); lowest != 0 || peak != 30 {
+		t.Errorf("Expected min 0 and peak 30, got %d and %d", lowest, peak)
+	}
+	if lowest, peak := rpsRange(series, 900*time.Millisecond); lowest != 0 || peak != 0 {
+		t.Errorf("Expected no range for a run under a second, got %d and %d", lowest, peak)
+	}
+}
+
+func TestCollectAndCalculateStats_LatencyByOutcome(t *testing.T) {
+	results := make(chan client.TestResult, 4)
+	results <- makeResult(true, 200, 10*time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(true, 200, 20*time.Millisecond, errors.ErrorTypeNone, 0)
+	results <- makeResult(false, 0, 5*time.Second, errors.ErrorTypeTimeout, 0)
+	results <- makeResult(false, 503, 1*time.Millisecond, errors.ErrorTypeServerError, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.SuccessLatency.P99 != 20*time.Millisecond || stats.SuccessLatency.Median != 20*time.Millisecond {
+		t.Errorf("Expected success percentiles over successes only, got %+v", stats.SuccessLatency)
+	}
+	if stats.FailureLatency.P99 != 5*time.Second || stats.FailureLatency.Median != 5*time.Second {
+		t.Errorf("Expected failure percentiles over failures only, got %+v", stats.FailureLatency)
+	}
+	if stats.P99Time != 5*time.Second {
+		t.Errorf("Expected overall P99 to include failures, got %v", stats.P99Time)
+	}
+}
+
+func TestCollectAndCalculateStats_ConnectTime(t *testing.T) {
+	results := make(chan client.TestResult, 3)
+	r1 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r1.ConnectTime = 2 * time.Millisecond
+	r2 := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	r2.ConnectTime = 40 * time.Millisecond
+	results <- r1
+	results <- r2
+	// A reused connection has no connect time
+	results <- makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 0)
+	close(results)
+
+	stats := CollectAndCalculateStats(results, time.Now(), config.RequestConfig{})
+
+	if stats.Connects != 2 {
+		t.Errorf("Expected 2 connects, got %d", stats.Connects)
+	}
+	if stats.ConnectLatency.P99 != 40*time.Millisecond || stats.ConnectLatency.Median != 40*time.Millisecond {
+		t.Errorf("Expected connect percentiles over dialed requests only, got %+v", stats.ConnectLatency)
+	}
+}
+
+func TestCollector_FastestAndSlowest(t *testing.T) {
+	c := NewCollector(time.Now(), config.RequestConfig{})
+	for i, d := range []time.Duration{20 * time.Millisecond, 5 * time.Millisecond, 90 * time.Millisecond, 30 * time.Millisecond} {
+		result := makeResult(true, 200, d, errors.ErrorTypeNone, 0)
+		result.Method, result.URL = "GET", fmt.Sprintf("http://test/%d", i)
+		c.Add(result)
+	}
+
+	stats := c.Snapshot()
+
+	if stats.Fastest == nil || stats.Fastest.URL != "http://test/1" || stats.Fastest.ResponseTime != stats.MinTime {
+		t.Errorf("Expected the fastest request to be the second, got %+v", stats.Fastest)
+	}
+	if stats.Slowest == nil || stats.Slowest.URL != "http://test/2" || stats.Slowest.ResponseTime != stats.MaxTime {
+		t.Errorf("Expected the slowest request to be the third, got %+v", stats.Slowest)
+	}
+}
+
+func TestCollector_Warmup(t *testing.T) {
+	start := time.Now()
+	c := NewCollector(start, config.RequestConfig{WarmupDuration: 50 * time.Millisecond})
+	c.Add(makeResult(false, 500, time.Second, errors.ErrorTypeServerError, 0))
+
+	if stats := c.Snapshot(); stats.TotalRequests != 0 || stats.WarmupDiscarded != 1 || stats.TestDuration != 0 {
+		t.Fatalf("Expected the warm-up result to be discarded, got %d requests, %d discarded, %v",
+			stats.TotalRequests, stats.WarmupDiscarded, stats.TestDuration)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	c.Add(makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0))
+
+	stats := c.Snapshot()
+	if stats.TotalRequests != 1 || stats.FailedReqs != 0 || stats.MaxTime != time.Millisecond {
+		t.Errorf("Expected only the steady-state result, got %+v", stats)
+	}
+	// The run is measured from the end of the warm-up
+	if stats.TestDuration > time.Since(start)-50*time.Millisecond {
+		t.Errorf("Expected the warm-up to be left out of the duration, got %v", stats.TestDuration)
+	}
+	if len(stats.TimeSeries) != 1 || stats.TimeSeries[0].Requests != 1 {
+		t.Errorf("Expected the time series to start after the warm-up, got %+v", stats.TimeSeries)
+	}
+}
+
+func TestCollector_BodyPassRate(t *testing.T) {
+	c := NewCollector(time.Now(), config.RequestConfig{ExpectedBody: "ok"})
+	c.Add(makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0))
+	c.Add(makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0))
+	c.Add(makeResult(false, 200, time.Millisecond, errors.ErrorTypeBodyValidation, 0))
+	// Passed the body check, then failed the latency limit
+	c.Add(makeResult(false, 200, time.Second, errors.ErrorTypeSlowResponse, 0))
+	// Never got as far as the body check
+	c.Add(makeResult(false, 500, time.Millisecond, errors.ErrorTypeServerError, 0))
+	c.Add(makeResult(false, 0, time.Millisecond, errors.ErrorTypeTimeout, 0))
+
+	stats := c.Snapshot()
+
+	if stats.BodyCheckedReqs != 4 || stats.BodyPassRate != 75 {
+		t.Errorf("Expected 3 of 4 checked bodies to pass, got %d checked at %.2f%%", stats.BodyCheckedReqs, stats.BodyPassRate)
+	}
+
+	// Nothing to report without body validation
+	c = NewCollector(time.Now(), config.RequestConfig{})
+	c.Add(makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, 0))
+	if stats := c.Snapshot(); stats.BodyCheckedReqs != 0 || stats.BodyPassRate != 0 {
+		t.Errorf("Expected no body checks, got %d at %.2f%%", stats.BodyCheckedReqs, stats.BodyPassRate)
+	}
+}
+
+func TestCollector_EffectiveConcurrency(t *testing.T) {
+	// Four half-second requests in about a second: two in flight on average
+	c := NewCollector(time.Now().Add(-time.Second), config.RequestConfig{Concurrency: 8})
+	for i := 0; i < 4; i++ {
+		c.Add(makeResult(true, 200, 500*time.Millisecond, errors.ErrorTypeNone, 0))
+	}
+
+	stats := c.Snapshot()
+	if stats.Concurrency != 8 {
+		t.Errorf("Expected the configured concurrency 8, got %d", stats.Concurrency)
+	}
+	if stats.EffectiveConcurrency < 1.9 || stats.EffectiveConcurrency > 2 {
+		t.Errorf("Expected an effective concurrency of about 2, got %.2f", stats.EffectiveConcurrency)
+	}
+
+	if stats := NewCollector(time.Now(), config.RequestConfig{}).Snapshot(); stats.EffectiveConcurrency != 0 {
+		t.Errorf("Expected no effective concurrency without requests, got %.2f", stats.EffectiveConcurrency)
 	}
 }
@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sizeBounds are the upper bounds (exclusive) of the response size histogram
+// buckets, growing 4x from 1KB. Larger responses go in a final open bucket.
+var sizeBounds = []int64{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20, 16 << 20}
+
+// SizeBucket counts the responses of Min bytes or more and less than Max
+// (zero Max means no upper bound).
+type SizeBucket struct {
+	Min   int64
+	Max   int64
+	Count int
+}
+
+// sizeBucket returns the index of the bucket a response of size bytes falls in.
+func sizeBucket(size int64) int {
+	return sort.Search(len(sizeBounds), func(i int) bool { return size < sizeBounds[i] })
+}
+
+// sizeHistogram turns per-bucket counts into buckets, leaving out the empty
+// ones past the largest response.
+func sizeHistogram(counts []int) []SizeBucket {
+	last := -1
+	for i, count := range counts {
+		if count > 0 {
+			last = i
+		}
+	}
+	buckets := make([]SizeBucket, 0, last+1)
+	for i := 0; i <= last; i++ {
+		bucket := SizeBucket{Count: counts[i]}
+		if i > 0 {
+			bucket.Min = sizeBounds[i-1]
+		}
+		if i < len(sizeBounds) {
+			bucket.Max = sizeBounds[i]
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// histogramWidth is the length of the longest bar in a printed histogram.
+const histogramWidth = 40
+
+// printSizeHistogram prints the response size histogram as a bar chart, with
+// bars scaled to the fullest bucket.
+func printSizeHistogram(buckets []SizeBucket) {
+	total, most := 0, 0
+	for _, bucket := range buckets {
+		total += bucket.Count
+		most = max(most, bucket.Count)
+	}
+	if total == 0 {
+		return
+	}
+	fmt.Println("\nResponse Size Histogram:")
+	for _, bucket := range buckets {
+		label := formatBytes(bucket.Min) + " - " + formatBytes(bucket.Max)
+		if bucket.Max == 0 {
+			label = formatBytes(bucket.Min) + "+"
+		}
+		bar := strings.Repeat("#", bucket.Count*histogramWidth/most)
+		if bar == "" && bucket.Count > 0 {
+			bar = "." // too few to fill a cell, but not none
+		}
+		fmt.Printf("  %-15s %-*s %d (%.2f%%)\n", label, histogramWidth, bar, bucket.Count, float64(bucket.Count)/float64(total)*100)
+	}
+}
+
+// formatBytes renders a bucket bound, e.g. "4 KB" or "1 MB".
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10:
+		return fmt.Sprintf("%d KB", n>>10)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
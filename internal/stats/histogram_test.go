@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestSizeBucket(t *testing.T) {
+	cases := map[int64]int{0: 0, 1023: 0, 1024: 1, 4095: 1, 4096: 2, 1 << 20: 6, 16 << 20: 8, 1 << 30: 8}
+	for size, want := range cases {
+		if got := sizeBucket(size); got != want {
+			t.Errorf("sizeBucket(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
+
+func TestCollector_SizeHistogram(t *testing.T) {
+	c := NewCollector(time.Now(), config.RequestConfig{SizeHistogram: true})
+	for _, size := range []int64{500, 2048, 3000, 10 << 20} {
+		c.Add(makeResult(true, 200, time.Millisecond, errors.ErrorTypeNone, size))
+	}
+	// No response, so no size to count
+	c.Add(client.TestResult{ErrorType: errors.ErrorTypeConnection})
+
+	histogram := c.Snapshot().SizeHistogram
+
+	want := []SizeBucket{
+		{Min: 0, Max: 1 << 10, Count: 1},
+		{Min: 1 << 10, Max: 4 << 10, Count: 2},
+		{Min: 4 << 10, Max: 16 << 10},
+		{Min: 16 << 10, Max: 64 << 10},
+		{Min: 64 << 10, Max: 256 << 10},
+		{Min: 256 << 10, Max: 1 << 20},
+		{Min: 1 << 20, Max: 4 << 20},
+		{Min: 4 << 20, Max: 16 << 20, Count: 1},
+	}
+	if len(histogram) != len(want) {
+		t.Fatalf("Expected %d buckets up to the largest response, got %+v", len(want), histogram)
+	}
+	for i := range want {
+		if histogram[i] != want[i] {
+			t.Errorf("Bucket %d: expected %+v, got %+v", i, want[i], histogram[i])
+		}
+	}
+
+	if h := NewCollector(time.Now(), config.RequestConfig{}).Snapshot().SizeHistogram; h != nil {
+		t.Errorf("Expected no histogram unless enabled, got %+v", h)
+	}
+}
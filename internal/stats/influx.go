@@ -0,0 +1,56 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// InfluxTags identify a run in the InfluxDB line protocol output.
+type InfluxTags struct {
+	URL    string
+	Method string
+}
+
+// influxEscaper escapes the characters that are special in tag values.
+var influxEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `)
+
+// WriteInfluxLines writes stats to w in InfluxDB line protocol: one
+// "loadtest" point summarizing the run, timestamped at end, then one
+// "loadtest_window" point per second of TimeSeries. Latencies are in
+// milliseconds and rates in percent.
+func WriteInfluxLines(w io.Writer, stats LoadTestStats, tags InfluxTags, end time.Time) error {
+	var tagSet string
+	if tags.URL != "" {
+		tagSet += ",url=" + influxEscaper.Replace(tags.URL)
+	}
+	if tags.Method != "" {
+		tagSet += ",method=" + influxEscaper.Replace(tags.Method)
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "loadtest%s requests=%di,failures=%di,rps=%g,error_rate=%g,avg_ms=%g,p50_ms=%g,p95_ms=%g,p99_ms=%g,max_ms=%g %d\n",
+		tagSet, stats.TotalRequests, stats.FailedReqs, stats.RequestsPerSecond, stats.ErrorRate,
+		millis(stats.AverageTime), millis(stats.MedianTime), millis(stats.P95Time), millis(stats.P99Time), millis(stats.MaxTime),
+		end.UnixNano())
+
+	start := end.Add(-stats.TestDuration)
+	for _, window := range stats.TimeSeries {
+		var errorRate float64
+		if window.Requests > 0 {
+			errorRate = float64(window.Failures) / float64(window.Requests) * 100
+		}
+		// A window spans one second, so its request count is its rate
+		fmt.Fprintf(bw, "loadtest_window%s requests=%di,failures=%di,rps=%g,error_rate=%g %d\n",
+			tagSet, window.Requests, window.Failures, float64(window.Requests), errorRate,
+			start.Add(time.Duration(window.Second)*time.Second).UnixNano())
+	}
+	return bw.Flush()
+}
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,50 @@
+package stats
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteInfluxLines(t *testing.T) {
+	s := LoadTestStats{
+		TotalRequests:     10,
+		FailedReqs:        1,
+		RequestsPerSecond: 5,
+		ErrorRate:         10,
+		AverageTime:       1500 * time.Microsecond,
+		MedianTime:        time.Millisecond,
+		P95Time:           2 * time.Millisecond,
+		P99Time:           3 * time.Millisecond,
+		MaxTime:           4 * time.Millisecond,
+		TestDuration:      2 * time.Second,
+		TimeSeries: []Window{
+			{Second: 0, Requests: 6},
+			{Second: 1, Requests: 4, Failures: 1},
+		},
+	}
+	end := time.Unix(100, 0)
+
+	var b strings.Builder
+	if err := WriteInfluxLines(&b, s, InfluxTags{URL: "http://host/a b?x=1", Method: "GET"}, end); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `loadtest,url=http://host/a\ b?x\=1,method=GET requests=10i,failures=1i,rps=5,error_rate=10,avg_ms=1.5,p50_ms=1,p95_ms=2,p99_ms=3,max_ms=4 100000000000
+loadtest_window,url=http://host/a\ b?x\=1,method=GET requests=6i,failures=0i,rps=6,error_rate=0 98000000000
+loadtest_window,url=http://host/a\ b?x\=1,method=GET requests=4i,failures=1i,rps=4,error_rate=25 99000000000
+`
+	if b.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteInfluxLines_OmitsEmptyTags(t *testing.T) {
+	var b strings.Builder
+	if err := WriteInfluxLines(&b, LoadTestStats{}, InfluxTags{Method: "POST"}, time.Unix(0, 0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "loadtest,method=POST requests=0i") {
+		t.Errorf("Expected no url tag, got %q", b.String())
+	}
+}
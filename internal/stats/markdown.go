@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"loadtester/internal/errors"
+	"sort"
+	"time"
+)
+
+// WriteMarkdown writes the summary, status code breakdown and error type
+// breakdown of stats to w as GitHub-flavored Markdown tables, for pasting
+// into pull requests, issues and wikis. Latencies are rounded to the
+// microsecond.
+func WriteMarkdown(w io.Writer, stats LoadTestStats) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "## Load test results")
+	fmt.Fprintln(bw)
+	if stats.Passed() {
+		fmt.Fprintln(bw, "**PASS**: all requests met the expected status and body")
+	} else {
+		fmt.Fprintf(bw, "**FAIL**: %d of %d requests failed\n", stats.FailedReqs, stats.TotalRequests)
+	}
+	if stats.StopReason != "" {
+		fmt.Fprintf(bw, "\nStopped early: %s (partial results)\n", stats.StopReason)
+	}
+
+	fmt.Fprintln(bw, "\n| Metric | Value |")
+	fmt.Fprintln(bw, "| --- | ---: |")
+	rows := []struct{ name, value string }{
+		{"Total requests", fmt.Sprintf("%d", stats.TotalRequests)},
+		{"Successful", fmt.Sprintf("%d (%.2f%%)", stats.SuccessfulReqs, stats.SuccessRate)},
+		{"Failed", fmt.Sprintf("%d (%.2f%%)", stats.FailedReqs, stats.ErrorRate)},
+		{"Test duration", stats.TestDuration.Round(time.Millisecond).String()},
+		{"Requests/sec", fmt.Sprintf("%.2f", stats.RequestsPerSecond)},
+		{"Data transferred", fmt.Sprintf("%.2f MB", float64(stats.TotalDataTransfer)/(1024*1024))},
+		{"Average", stats.AverageTime.Round(time.Microsecond).String()},
+		{"Median", stats.MedianTime.Round(time.Microsecond).String()},
+		{"95th percentile", stats.P95Time.Round(time.Microsecond).String()},
+		{"99th percentile", stats.P99Time.Round(time.Microsecond).String()},
+		{"Min", stats.MinTime.Round(time.Microsecond).String()},
+		{"Max", stats.MaxTime.Round(time.Microsecond).String()},
+	}
+	for _, row := range rows {
+		fmt.Fprintf(bw, "| %s | %s |\n", row.name, row.value)
+	}
+
+	if len(stats.StatusBreakdown) > 0 {
+		fmt.Fprintln(bw, "\n### Status codes")
+		fmt.Fprintln(bw, "\n| Status | Count | Share |")
+		fmt.Fprintln(bw, "| --- | ---: | ---: |")
+		var codes []int
+		for code := range stats.StatusBreakdown {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			count := stats.StatusBreakdown[code]
+			fmt.Fprintf(bw, "| %d | %d | %.2f%% |\n", code, count, share(count, stats.TotalRequests))
+		}
+	}
+
+	if len(stats.ErrorBreakdown) > 0 {
+		fmt.Fprintln(bw, "\n### Errors")
+		fmt.Fprintln(bw, "\n| Error type | Count | Share |")
+		fmt.Fprintln(bw, "| --- | ---: | ---: |")
+		var errorTypes []errors.ErrorType
+		for errorType := range stats.ErrorBreakdown {
+			errorTypes = append(errorTypes, errorType)
+		}
+		// Most frequent first, ties by name so the output is stable
+		sort.Slice(errorTypes, func(i, j int) bool {
+			ci, cj := stats.ErrorBreakdown[errorTypes[i]], stats.ErrorBreakdown[errorTypes[j]]
+			if ci != cj {
+				return ci > cj
+			}
+			return errorTypes[i] < errorTypes[j]
+		})
+		for _, errorType := range errorTypes {
+			count := stats.ErrorBreakdown[errorType]
+			fmt.Fprintf(bw, "| %s | %d | %.2f%% |\n", errorType, count, share(count, stats.TotalRequests))
+		}
+	}
+	return bw.Flush()
+}
+
+// share is count as a percentage of total, or zero when total is.
+func share(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) / float64(total) * 100
+}
@@ -0,0 +1,70 @@
+package stats
+
+import (
+	"loadtester/internal/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMarkdown(t *testing.T) {
+	s := LoadTestStats{
+		TotalRequests:     4,
+		SuccessfulReqs:    2,
+		FailedReqs:        2,
+		SuccessRate:       50,
+		ErrorRate:         50,
+		TestDuration:      2 * time.Second,
+		RequestsPerSecond: 2,
+		AverageTime:       1500 * time.Microsecond,
+		MedianTime:        time.Millisecond,
+		P95Time:           2 * time.Millisecond,
+		P99Time:           2 * time.Millisecond,
+		MinTime:           500 * time.Microsecond,
+		MaxTime:           2 * time.Millisecond,
+		StatusBreakdown:   map[int]int{503: 1, 200: 2},
+		ErrorBreakdown:    map[errors.ErrorType]int{errors.ErrorTypeServerError: 1, errors.ErrorTypeTimeout: 1},
+	}
+
+	var b strings.Builder
+	if err := WriteMarkdown(&b, s); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `## Load test results
+
+**FAIL**: 2 of 4 requests failed
+
+| Metric | Value |
+| --- | ---: |
+| Total requests | 4 |
+| Successful | 2 (50.00%) |
+| Failed | 2 (50.00%) |
+| Test duration | 2s |
+| Requests/sec | 2.00 |
+| Data transferred | 0.00 MB |
+| Average | 1.5ms |
+| Median | 1ms |
+| 95th percentile | 2ms |
+| 99th percentile | 2ms |
+| Min | 500µs |
+| Max | 2ms |
+
+### Status codes
+
+| Status | Count | Share |
+| --- | ---: | ---: |
+| 200 | 2 | 50.00% |
+| 503 | 1 | 25.00% |
+
+### Errors
+
+| Error type | Count | Share |
+| --- | ---: | ---: |
+| Server Error | 1 | 25.00% |
+| Timeout | 1 | 25.00% |
+`
+	if b.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
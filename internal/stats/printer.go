@@ -1,34 +1,247 @@
 package stats
 
 import (
+	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"loadtester/internal/errors"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-func PrintDetailedStats(stats LoadTestStats) {
+const (
+	ansiReset = "\033[0m"
+	ansiBold  = "\033[1m"
+	ansiRed   = "\033[31m"
+	ansiGreen = "\033[32m"
+)
+
+// PrintOptions controls the human-readable report.
+type PrintOptions struct {
+	Color bool // ANSI colors for the verdict banner and failures
+}
+
+// paint wraps s in the given ANSI codes when color output is enabled.
+func paint(opts PrintOptions, s string, codes ...string) string {
+	if !opts.Color {
+		return s
+	}
+	return strings.Join(codes, "") + s + ansiReset
+}
+
+// describeSample returns the request s as a suffix for a latency line, e.g.
+// " (GET http://host/a, status 200)", or "" when s is nil.
+func describeSample(s *RequestSample) string {
+	if s == nil || s.URL == "" {
+		return ""
+	}
+	outcome := fmt.Sprintf("status %d", s.StatusCode)
+	if s.StatusCode == 0 {
+		outcome = string(s.ErrorType)
+	}
+	if s.Step != "" {
+		return fmt.Sprintf(" (step %q: %s %s, %s)", s.Step, s.Method, s.URL, outcome)
+	}
+	return fmt.Sprintf(" (%s %s, %s)", s.Method, s.URL, outcome)
+}
+
+func PrintDetailedStats(stats LoadTestStats, opts PrintOptions) {
 	fmt.Println("\n" + strings.Repeat("=", 60))
 	fmt.Println("LOAD TEST RESULTS")
 	fmt.Println(strings.Repeat("=", 60))
 
+	if stats.Passed() {
+		fmt.Println(paint(opts, " PASS ", ansiBold, ansiGreen) + " all requests met the expected status and body")
+	} else {
+		fmt.Println(paint(opts, " FAIL ", ansiBold, ansiRed) + fmt.Sprintf(" %d of %d requests failed", stats.FailedReqs, stats.TotalRequests))
+	}
+	fmt.Println()
+
+	if stats.StopReason != "" {
+		fmt.Printf("Stopped early: %s (partial results)\n\n", stats.StopReason)
+	}
+
 	// Summary
-	fmt.Printf("Total Requests:     %d\n", stats.TotalRequests)
+	switch {
+	case stats.PlannedRequests == 0:
+		fmt.Printf("Total Requests:     %d completed in %v at %.2f req/s\n",
+			stats.TotalRequests, stats.TestDuration.Round(100*time.Millisecond), stats.RequestsPerSecond)
+	case stats.TotalRequests != stats.PlannedRequests:
+		fmt.Printf("Total Requests:     %d of %d planned\n", stats.TotalRequests, stats.PlannedRequests)
+	default:
+		fmt.Printf("Total Requests:     %d\n", stats.TotalRequests)
+	}
 	fmt.Printf("Successful:         %d (%.2f%%)\n", stats.SuccessfulReqs, stats.SuccessRate)
-	fmt.Printf("Failed:             %d (%.2f%%)\n", stats.FailedReqs, 100-stats.SuccessRate)
+	failed := fmt.Sprintf("Failed:             %d (%.2f%%)", stats.FailedReqs, stats.ErrorRate)
+	if stats.FailedReqs > 0 {
+		failed = paint(opts, failed, ansiRed)
+	}
+	fmt.Println(failed)
+	if stats.AbortedReqs > 0 {
+		fmt.Printf("Aborted:            %d in flight when the run was stopped (not counted)\n", stats.AbortedReqs)
+	}
+	if stats.BodyCheckedReqs > 0 {
+		fmt.Printf("Content valid:      %.2f%% of %d responses with the expected status\n", stats.BodyPassRate, stats.BodyCheckedReqs)
+	}
+	if stats.Retries > 0 {
+		fmt.Printf("Retries:            %d\n", stats.Retries)
+	}
 	fmt.Printf("Test Duration:      %v\n", stats.TestDuration)
-	fmt.Printf("Requests/sec:       %.2f\n", stats.RequestsPerSecond)
+	if stats.WarmupDuration > 0 {
+		fmt.Printf("Warm-up:            first %v excluded (%d requests discarded)\n", stats.WarmupDuration, stats.WarmupDiscarded)
+	}
+	if stats.PeakRequestsPerSecond > 0 {
+		fmt.Printf("Requests/sec:       %.2f (min %d, peak %d in a single second)\n",
+			stats.RequestsPerSecond, stats.MinRequestsPerSecond, stats.PeakRequestsPerSecond)
+	} else {
+		fmt.Printf("Requests/sec:       %.2f\n", stats.RequestsPerSecond)
+	}
+	if stats.EffectiveConcurrency > 0 {
+		if stats.Concurrency > 0 {
+			fmt.Printf("Concurrency:        %.2f effective of %d workers (%.0f%%)\n",
+				stats.EffectiveConcurrency, stats.Concurrency, stats.EffectiveConcurrency/float64(stats.Concurrency)*100)
+		} else {
+			fmt.Printf("Concurrency:        %.2f effective\n", stats.EffectiveConcurrency)
+		}
+	}
+	if stats.TargetRate > 0 {
+		fmt.Printf("Target pacing:      %.2f req/s (actual %.1f%% of target)\n",
+			stats.TargetRate, stats.RequestsPerSecond/stats.TargetRate*100)
+	}
 	fmt.Printf("Data Transferred:   %.2f MB\n", float64(stats.TotalDataTransfer)/(1024*1024))
+	if stats.TotalDataSent > 0 {
+		sent := fmt.Sprintf("Data Sent:          %.2f MB", float64(stats.TotalDataSent)/(1024*1024))
+		if stats.TotalDataSentRaw != stats.TotalDataSent {
+			sent += fmt.Sprintf(" (%.2f MB uncompressed)", float64(stats.TotalDataSentRaw)/(1024*1024))
+		}
+		fmt.Println(sent)
+	}
 
 	// Response Time Statistics
-	fmt.Println("\nResponse Time Statistics:")
+	fmt.Printf("\nResponse Time Statistics (%d samples):\n", len(stats.ResponseTimes))
+	if stats.PercentilesUnreliable {
+		fmt.Printf("  Note: fewer than %d samples, so the 95th/99th percentiles are not reliable\n", MinPercentileSamples)
+	}
 	fmt.Printf("  Average:          %v\n", stats.AverageTime)
 	fmt.Printf("  Median (50th):    %v\n", stats.MedianTime)
 	fmt.Printf("  95th percentile:  %v\n", stats.P95Time)
 	fmt.Printf("  99th percentile:  %v\n", stats.P99Time)
-	fmt.Printf("  Min:              %v\n", stats.MinTime)
-	fmt.Printf("  Max:              %v\n", stats.MaxTime)
+	fmt.Printf("  Min:              %v%s\n", stats.MinTime, describeSample(stats.Fastest))
+	fmt.Printf("  Max:              %v%s\n", stats.MaxTime, describeSample(stats.Slowest))
+	fmt.Printf("  Std deviation:    %v (CV %.2f)\n", stats.StdDevTime, stats.LatencyCV)
+	if stats.FirstRequestTime > 0 {
+		first := fmt.Sprintf("  First request:    %v", stats.FirstRequestTime)
+		if stats.FirstRequestExcluded {
+			first += " (excluded from the figures above)"
+		}
+		fmt.Println(first)
+	}
+	if stats.LatencyTarget > 0 {
+		fmt.Printf("  Within target:    %.2f%% of requests succeeded under %v\n", stats.WithinTargetRate, stats.LatencyTarget)
+	}
+
+	// With failures in the mix, show how each outcome contributes
+	if stats.FailedReqs > 0 {
+		fmt.Printf("  Successful only:  p50=%v p95=%v p99=%v\n", stats.SuccessLatency.Median, stats.SuccessLatency.P95, stats.SuccessLatency.P99)
+		fmt.Printf("  Failed only:      p50=%v p95=%v p99=%v\n", stats.FailureLatency.Median, stats.FailureLatency.P95, stats.FailureLatency.P99)
+	}
+
+	if stats.AdaptiveTimeout > 0 {
+		fmt.Printf("  Adaptive timeout: %v\n", stats.AdaptiveTimeout)
+	}
+
+	if stats.Throughput.Responses > 0 {
+		printThroughput(stats.Throughput)
+	}
+
+	// Queue wait is tester-side throttling, not server latency
+	if stats.MaxWaitTime > 0 {
+		fmt.Println("\nQueue Wait (before send):")
+		fmt.Printf("  Average:          %v\n", stats.AverageWaitTime)
+		fmt.Printf("  Max:              %v\n", stats.MaxWaitTime)
+	}
+
+	if stats.ApdexTarget > 0 {
+		fmt.Printf("\nApdex (T=%v):\n", stats.ApdexTarget)
+		fmt.Printf("  Score:            %.2f (%s)\n", stats.ApdexScore, stats.ApdexRating)
+		fmt.Printf("  Satisfied:        %d\n", stats.ApdexSatisfied)
+		fmt.Printf("  Tolerating:       %d\n", stats.ApdexTolerating)
+		fmt.Printf("  Frustrated:       %d\n", stats.ApdexFrustrated)
+	}
+
+	if stats.ConnectionsOpened > 0 {
+		fmt.Println("\nConnections:")
+		fmt.Printf("  Opened:           %d\n", stats.ConnectionsOpened)
+		fmt.Printf("  Reused:           %d (%.2f%% of requests)\n", stats.ConnectionsReused,
+			float64(stats.ConnectionsReused)/float64(stats.TotalRequests)*100)
+		fmt.Printf("  Requests/conn:    %.2f\n", stats.RequestsPerConnection)
+	}
+	if stats.Connects > 0 {
+		fmt.Printf("\nTCP Connect Time (%d connects):\n", stats.Connects)
+		fmt.Printf("  Median (50th):    %v\n", stats.ConnectLatency.Median)
+		fmt.Printf("  95th percentile:  %v\n", stats.ConnectLatency.P95)
+		fmt.Printf("  99th percentile:  %v\n", stats.ConnectLatency.P99)
+	}
+
+	// Like queue wait, this is the tester holding itself back
+	if stats.ConnectWaits > 0 {
+		fmt.Printf("\nConnect Rate Limit Wait (%d dials):\n", stats.ConnectWaits)
+		fmt.Printf("  Average:          %v\n", stats.AverageConnectWait)
+		fmt.Printf("  Max:              %v\n", stats.MaxConnectWait)
+	}
+	if stats.ThrottledReqs > 0 {
+		fmt.Printf("\nMax RPS Ceiling Wait (%d of %d requests held back at %.2f/sec):\n", stats.ThrottledReqs, stats.TotalRequests, stats.MaxRPS)
+		fmt.Printf("  Average:          %v\n", stats.AverageThrottleWait)
+		fmt.Printf("  Max:              %v\n", stats.MaxThrottleWait)
+	}
+
+	if stats.DNSLookups > 0 {
+		fmt.Println("\nDNS Resolution:")
+		fmt.Printf("  Lookups:          %d\n", stats.DNSLookups)
+		fmt.Printf("  Average:          %v\n", stats.AverageDNSTime)
+		fmt.Printf("  Max:              %v\n", stats.MaxDNSTime)
+	}
+
+	if len(stats.Steps) > 0 {
+		fmt.Println("\nScenario Steps:")
+		for _, step := range stats.Steps {
+			line := fmt.Sprintf("  %s: %d requests, %.2f%% success, avg=%v p95=%v p99=%v",
+				step.Name, step.TotalRequests, step.SuccessRate, step.AverageTime, step.P95Time, step.P99Time)
+			if step.FailedReqs > 0 {
+				line = paint(opts, line, ansiRed)
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(stats.Tags) > 0 {
+		fmt.Println("\nTags:")
+		for _, tag := range stats.Tags {
+			line := fmt.Sprintf("  %s: %d requests, %.2f%% success, avg=%v p50=%v p95=%v p99=%v",
+				tag.Name, tag.TotalRequests, tag.SuccessRate, tag.AverageTime, tag.MedianTime, tag.P95Time, tag.P99Time)
+			if tag.FailedReqs > 0 {
+				line = paint(opts, line, ansiRed)
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(stats.Stages) > 0 {
+		fmt.Println("\nStages:")
+		for _, stage := range stats.Stages {
+			line := fmt.Sprintf("  %s: %d requests in %v with %d workers, %.2f req/s, %.2f%% success, avg=%v p95=%v p99=%v",
+				stage.Name, stage.TotalRequests, stage.TestDuration.Round(100*time.Millisecond), stage.Concurrency,
+				stage.RequestsPerSecond, stage.SuccessRate, stage.AverageTime, stage.P95Time, stage.P99Time)
+			if stage.FailedReqs > 0 {
+				line = paint(opts, line, ansiRed)
+			}
+			fmt.Println(line)
+		}
+	}
 
 	// Status Code Breakdown
 	if len(stats.StatusBreakdown) > 0 {
@@ -46,6 +259,85 @@ func PrintDetailedStats(stats LoadTestStats) {
 		}
 	}
 
+	// Protocol Breakdown
+	if len(stats.ProtocolBreakdown) > 0 {
+		fmt.Println("\nHTTP Protocol Breakdown:")
+		var protocols []string
+		for proto := range stats.ProtocolBreakdown {
+			protocols = append(protocols, proto)
+		}
+		sort.Strings(protocols)
+
+		for _, proto := range protocols {
+			count := stats.ProtocolBreakdown[proto]
+			percentage := float64(count) / float64(stats.TotalRequests) * 100
+			fmt.Printf("  %s: %d (%.2f%%)\n", proto, count, percentage)
+		}
+	}
+
+	// Only worth showing when the host resolved to several addresses
+	if len(stats.RemoteAddrBreakdown) > 1 {
+		fmt.Println("\nRemote Address Breakdown:")
+		var addrs []string
+		for addr := range stats.RemoteAddrBreakdown {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+
+		for _, addr := range addrs {
+			count := stats.RemoteAddrBreakdown[addr]
+			percentage := float64(count) / float64(stats.TotalRequests) * 100
+			fmt.Printf("  %s: %d (%.2f%%)\n", addr, count, percentage)
+		}
+	}
+
+	if stats.RateLimitedReqs > 0 || stats.RetryAfters > 0 {
+		fmt.Println("\nRate Limiting:")
+		fmt.Printf("  429 responses:    %d (%.2f%%)\n", stats.RateLimitedReqs, stats.RateLimitedRate)
+		if stats.RetryAfters > 0 {
+			fmt.Printf("  Retry-After:      min=%v avg=%v max=%v (%d responses)\n",
+				stats.MinRetryAfter, stats.AverageRetryAfter.Round(time.Millisecond), stats.MaxRetryAfter, stats.RetryAfters)
+		}
+	}
+
+	if len(stats.CacheBreakdown) > 0 {
+		fmt.Printf("\nCache (%s):\n", stats.CacheHeader)
+		fmt.Printf("  Hit ratio:        %.2f%%\n", stats.CacheHitRate)
+		var values []string
+		for value := range stats.CacheBreakdown {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			count := stats.CacheBreakdown[value]
+			percentage := float64(count) / float64(stats.TotalRequests) * 100
+			fmt.Printf("  %s: %d (%.2f%%)\n", value, count, percentage)
+		}
+	}
+
+	// TLS Breakdown
+	if len(stats.TLSBreakdown) > 0 {
+		fmt.Println("\nTLS Version/Cipher Breakdown:")
+		var negotiated []string
+		for n := range stats.TLSBreakdown {
+			negotiated = append(negotiated, n)
+		}
+		sort.Strings(negotiated)
+
+		for _, n := range negotiated {
+			count := stats.TLSBreakdown[n]
+			percentage := float64(count) / float64(stats.TotalRequests) * 100
+			fmt.Printf("  %s: %d (%.2f%%)\n", n, count, percentage)
+		}
+	}
+	if !stats.CertExpiry.IsZero() {
+		fmt.Printf("  Certificate expires: %s\n", stats.CertExpiry.UTC().Format(time.RFC3339))
+		if warning := CertExpiryWarning(stats, time.Now()); warning != "" {
+			fmt.Println(paint(opts, "  "+warning, ansiRed))
+		}
+	}
+
 	// Error Breakdown
 	if len(stats.ErrorBreakdown) > 0 {
 		fmt.Println("\nError Type Breakdown:")
@@ -66,11 +358,315 @@ func PrintDetailedStats(stats LoadTestStats) {
 
 		for _, stat := range errorStats {
 			percentage := float64(stat.count) / float64(stats.TotalRequests) * 100
-			fmt.Printf("  %s: %d (%.2f%%)\n", stat.errorType, stat.count, percentage)
+			fmt.Println(paint(opts, fmt.Sprintf("  %s: %d (%.2f%%)", stat.errorType, stat.count, percentage), ansiRed))
 		}
 	}
 
+	printErrorTimeline(stats, opts)
+
+	if len(stats.SizeHistogram) > 0 {
+		printSizeHistogram(stats.SizeHistogram)
+	}
+
+	// Distinct messages can hide inside one error type, e.g. several hosts
+	// failing DNS resolution
+	if len(stats.ErrorMessages) > 0 {
+		fmt.Printf("\nTop Error Messages (%d distinct):\n", len(stats.ErrorMessages))
+		for _, msg := range TopErrorMessages(stats, topErrorMessages) {
+			fmt.Println(paint(opts, fmt.Sprintf("  %d x %s", stats.ErrorMessages[msg], msg), ansiRed))
+		}
+		if stats.OtherErrorMessages > 0 {
+			fmt.Printf("  %d more failures with other messages (not tracked beyond %d distinct)\n", stats.OtherErrorMessages, MaxErrorMessages)
+		}
+	}
+
+	fmt.Println(strings.Repeat("=", 60))
+	fmt.Println(SummaryLine(stats))
+}
+
+// PrintComparison prints the headline figures of runs made side by side, one
+// column per run under its label, followed by each run's summary line.
+func PrintComparison(labels []string, results []LoadTestStats, opts PrintOptions) {
+	width := 14
+	for _, label := range labels {
+		width = max(width, len(label)+2)
+	}
+	rows := []struct {
+		name  string
+		value func(LoadTestStats) string
+	}{
+		{"Requests", func(s LoadTestStats) string { return strconv.Itoa(s.TotalRequests) }},
+		{"Success rate", func(s LoadTestStats) string { return fmt.Sprintf("%.2f%%", s.SuccessRate) }},
+		{"Requests/sec", func(s LoadTestStats) string { return fmt.Sprintf("%.2f", s.RequestsPerSecond) }},
+		{"Average", func(s LoadTestStats) string { return roundLatency(s.AverageTime).String() }},
+		{"Median", func(s LoadTestStats) string { return roundLatency(s.MedianTime).String() }},
+		{"95th percentile", func(s LoadTestStats) string { return roundLatency(s.P95Time).String() }},
+		{"99th percentile", func(s LoadTestStats) string { return roundLatency(s.P99Time).String() }},
+		{"Max", func(s LoadTestStats) string { return roundLatency(s.MaxTime).String() }},
+		{"Data transferred", func(s LoadTestStats) string {
+			return fmt.Sprintf("%.2f MB", float64(s.TotalDataTransfer)/(1024*1024))
+		}},
+	}
+
+	fmt.Println("\n" + strings.Repeat("=", 60))
+	fmt.Println("COMPARISON")
 	fmt.Println(strings.Repeat("=", 60))
+	fmt.Printf("%-18s", "")
+	for _, label := range labels {
+		fmt.Print(padLeft(label, width))
+	}
+	fmt.Println()
+	for _, row := range rows {
+		fmt.Printf("%-18s", row.name)
+		for _, result := range results {
+			fmt.Print(padLeft(row.value(result), width))
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-18s", "Verdict")
+	for _, result := range results {
+		// Pad before painting, so escape codes don't count towards the width
+		if result.Passed() {
+			fmt.Print(paint(opts, padLeft("PASS", width), ansiGreen))
+		} else {
+			fmt.Print(paint(opts, padLeft("FAIL", width), ansiRed))
+		}
+	}
+	fmt.Println()
+
+	fmt.Println(strings.Repeat("=", 60))
+	for i, result := range results {
+		fmt.Printf("%s: %s\n", labels[i], SummaryLine(result))
+	}
+}
+
+// padLeft right-aligns s in width columns, counting runes rather than bytes
+// so durations in µs line up.
+func padLeft(s string, width int) string {
+	return strings.Repeat(" ", max(0, width-utf8.RuneCountInString(s))) + s
+}
+
+// PrintJSONComparison prints the stats of side-by-side runs as one JSON
+// object keyed by label.
+func PrintJSONComparison(labels []string, results []LoadTestStats) {
+	byLabel := make(map[string]LoadTestStats, len(results))
+	for i, result := range results {
+		byLabel[labels[i]] = result
+	}
+	jsonData, err := json.MarshalIndent(byLabel, "", "  ")
+	if err != nil {
+		fmt.Printf("Error creating JSON: %v\n", err)
+		return
+	}
+	fmt.Println(string(jsonData))
+}
+
+// maxTimelineLines caps the error timeline in the text report; JSON output
+// always has the full series.
+const maxTimelineLines = 20
+
+// printErrorTimeline lists the seconds in which requests failed, by error
+// type, to pinpoint when a failure mode began.
+func printErrorTimeline(stats LoadTestStats, opts PrintOptions) {
+	var lines []string
+	for _, window := range stats.TimeSeries {
+		if window.Failures == 0 {
+			continue
+		}
+		types := make([]string, 0, len(window.Errors))
+		for errorType := range window.Errors {
+			types = append(types, string(errorType))
+		}
+		sort.Strings(types)
+		counts := make([]string, len(types))
+		for i, errorType := range types {
+			counts[i] = fmt.Sprintf("%s=%d", errorType, window.Errors[errors.ErrorType(errorType)])
+		}
+		lines = append(lines, fmt.Sprintf("  t=%ds: %d/%d failed %s", window.Second, window.Failures, window.Requests, strings.Join(counts, ", ")))
+	}
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Println("\nErrors Over Time:")
+	for i, line := range lines {
+		if i == maxTimelineLines {
+			fmt.Printf("  ... %d more seconds with failures (see -json)\n", len(lines)-maxTimelineLines)
+			break
+		}
+		fmt.Println(paint(opts, line, ansiRed))
+	}
+}
+
+// topErrorMessages is how many error messages the text report lists.
+const topErrorMessages = 5
+
+// CertExpiryWarning returns a warning when the server certificate expires
+// within stats.CertExpiryWarn of now, or has already expired; otherwise "".
+func CertExpiryWarning(stats LoadTestStats, now time.Time) string {
+	if stats.CertExpiry.IsZero() || stats.CertExpiryWarn <= 0 {
+		return ""
+	}
+	left := stats.CertExpiry.Sub(now)
+	switch {
+	case left <= 0:
+		return fmt.Sprintf("Warning: TLS certificate expired %s ago", days(-left))
+	case left <= stats.CertExpiryWarn:
+		return fmt.Sprintf("Warning: TLS certificate expires in %s", days(left))
+	}
+	return ""
+}
+
+// days formats d as a whole number of days, or hours when under a day.
+func days(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days", int(d.Hours()/24))
+}
+
+// TopErrorMessages returns up to n error messages, most frequent first.
+func TopErrorMessages(stats LoadTestStats, n int) []string {
+	msgs := make([]string, 0, len(stats.ErrorMessages))
+	for msg := range stats.ErrorMessages {
+		msgs = append(msgs, msg)
+	}
+	sort.Slice(msgs, func(i, j int) bool {
+		ci, cj := stats.ErrorMessages[msgs[i]], stats.ErrorMessages[msgs[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return msgs[i] < msgs[j]
+	})
+	if len(msgs) > n {
+		msgs = msgs[:n]
+	}
+	return msgs
+}
+
+// SummaryLine condenses a run into a single grep- and paste-friendly line,
+// e.g. "200 req in 2.1s | 95.2 req/s | p50=12ms p99=85ms | errors=1.2%".
+func SummaryLine(stats LoadTestStats) string {
+	return fmt.Sprintf("%d req in %v | %.1f req/s | p50=%v p99=%v | errors=%.1f%%",
+		stats.TotalRequests, stats.TestDuration.Round(100*time.Millisecond), stats.RequestsPerSecond,
+		roundLatency(stats.MedianTime), roundLatency(stats.P99Time), stats.ErrorRate)
+}
+
+// StatusLine is a fixed-format result line for wrapper scripts, e.g.
+// "RESULT status=fail requests=100 errors=2 p99=85ms rps=95.2". Fields are
+// space-separated key=value pairs and latencies are always in milliseconds.
+func StatusLine(stats LoadTestStats) string {
+	status := "fail"
+	if stats.Passed() {
+		status = "pass"
+	}
+	return fmt.Sprintf("RESULT status=%s requests=%d errors=%d p99=%gms rps=%.1f",
+		status, stats.TotalRequests, stats.FailedReqs,
+		float64(roundLatency(stats.P99Time))/float64(time.Millisecond), stats.RequestsPerSecond)
+}
+
+// roundLatency trims a latency to a readable precision: whole milliseconds,
+// or microseconds for sub-millisecond values.
+func roundLatency(d time.Duration) time.Duration {
+	if d >= time.Millisecond {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// PrintSnapshot prints a one-line interim summary of a test still in progress.
+func PrintSnapshot(stats LoadTestStats) {
+	fmt.Printf("[%v] requests=%d success=%.2f%% rps=%.2f avg=%v p95=%v p99=%v max=%v\n",
+		stats.TestDuration.Round(time.Second), stats.TotalRequests, stats.SuccessRate,
+		stats.RequestsPerSecond, stats.AverageTime, stats.P95Time, stats.P99Time, stats.MaxTime)
+}
+
+// WriteResponseTimes writes every response time in stats to w as integer
+// nanoseconds, one per line, in ascending order.
+func WriteResponseTimes(w io.Writer, stats LoadTestStats) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range stats.ResponseTimes {
+		bw.WriteString(strconv.FormatInt(int64(t), 10))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// compactStats is the subset of LoadTestStats written by WriteCompactJSON,
+// under the same field names as the full JSON output.
+type compactStats struct {
+	TotalRequests     int
+	SuccessfulReqs    int
+	FailedReqs        int
+	SuccessRate       float64
+	ErrorRate         float64
+	AverageTime       time.Duration
+	MinTime           time.Duration
+	MaxTime           time.Duration
+	MedianTime        time.Duration
+	P95Time           time.Duration
+	P99Time           time.Duration
+	RequestsPerSecond float64
+	TotalDataTransfer int64
+	TestDuration      time.Duration
+	StopReason        string         `json:",omitempty"`
+	ErrorCodes        map[string]int `json:",omitempty"`
+	StatusBreakdown   map[int]int    `json:",omitempty"`
+}
+
+// newCompactStats returns the compactStats of stats.
+func newCompactStats(stats LoadTestStats) compactStats {
+	return compactStats{
+		TotalRequests:     stats.TotalRequests,
+		SuccessfulReqs:    stats.SuccessfulReqs,
+		FailedReqs:        stats.FailedReqs,
+		SuccessRate:       stats.SuccessRate,
+		ErrorRate:         stats.ErrorRate,
+		AverageTime:       stats.AverageTime,
+		MinTime:           stats.MinTime,
+		MaxTime:           stats.MaxTime,
+		MedianTime:        stats.MedianTime,
+		P95Time:           stats.P95Time,
+		P99Time:           stats.P99Time,
+		RequestsPerSecond: stats.RequestsPerSecond,
+		TotalDataTransfer: stats.TotalDataTransfer,
+		TestDuration:      stats.TestDuration,
+		StopReason:        stats.StopReason,
+		ErrorCodes:        stats.ErrorCodes,
+		StatusBreakdown:   stats.StatusBreakdown,
+	}
+}
+
+// WriteCompactJSON writes the key metrics of stats to w as a single line of
+// JSON, without the raw response times and other bulky fields, so that runs
+// can be appended to a log one line each.
+func WriteCompactJSON(w io.Writer, stats LoadTestStats) error {
+	jsonData, err := json.Marshal(newCompactStats(stats))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", jsonData)
+	return err
+}
+
+// webhookPayload is the compact JSON plus a one-line text summary, which is
+// what chat incoming webhooks such as Slack's and Teams' display.
+type webhookPayload struct {
+	Text string `json:"text"`
+	compactStats
+}
+
+// WebhookJSON returns the JSON document to post to a webhook when a run
+// finishes: the fields of WriteCompactJSON, plus "text" with the verdict
+// and SummaryLine.
+func WebhookJSON(stats LoadTestStats) ([]byte, error) {
+	verdict := "PASS"
+	if !stats.Passed() {
+		verdict = "FAIL"
+	}
+	return json.Marshal(webhookPayload{
+		Text:         fmt.Sprintf("Load test %s: %s", verdict, SummaryLine(stats)),
+		compactStats: newCompactStats(stats),
+	})
 }
 
 func PrintJSONStats(stats LoadTestStats) {
@@ -0,0 +1,163 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"loadtester/internal/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummaryLine(t *testing.T) {
+	stats := LoadTestStats{
+		TotalRequests:     200,
+		TestDuration:      2100*time.Millisecond + 37*time.Microsecond,
+		RequestsPerSecond: 95.2381,
+		MedianTime:        12*time.Millisecond + 340*time.Microsecond,
+		P99Time:           85*time.Millisecond + 600*time.Microsecond,
+		ErrorRate:         1.25,
+	}
+
+	want := "200 req in 2.1s | 95.2 req/s | p50=12ms p99=86ms | errors=1.2%"
+	if got := SummaryLine(stats); got != want {
+		t.Errorf("Expected summary %q, got %q", want, got)
+	}
+}
+
+func TestStatusLine(t *testing.T) {
+	stats := LoadTestStats{
+		TotalRequests:     100,
+		SuccessfulReqs:    98,
+		FailedReqs:        2,
+		P99Time:           85*time.Millisecond + 300*time.Microsecond,
+		RequestsPerSecond: 95.2381,
+	}
+	if got, want := StatusLine(stats), "RESULT status=fail requests=100 errors=2 p99=85ms rps=95.2"; got != want {
+		t.Errorf("Expected status line %q, got %q", want, got)
+	}
+
+	stats = LoadTestStats{TotalRequests: 10, SuccessfulReqs: 10, P99Time: 450*time.Microsecond + 300*time.Nanosecond}
+	if got, want := StatusLine(stats), "RESULT status=pass requests=10 errors=0 p99=0.45ms rps=0.0"; got != want {
+		t.Errorf("Expected status line %q, got %q", want, got)
+	}
+}
+
+func TestRoundLatency_SubMillisecond(t *testing.T) {
+	if got := roundLatency(450*time.Microsecond + 300*time.Nanosecond); got != 450*time.Microsecond {
+		t.Errorf("Expected 450µs, got %v", got)
+	}
+}
+
+func TestWebhookJSON(t *testing.T) {
+	stats := LoadTestStats{TotalRequests: 4, FailedReqs: 1, ErrorRate: 25, ErrorCodes: map[string]int{"timeout": 1}}
+
+	payload, err := WebhookJSON(stats)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if text, _ := decoded["text"].(string); !strings.HasPrefix(text, "Load test FAIL: 4 req in") {
+		t.Errorf("Expected a text summary for chat webhooks, got %q", text)
+	}
+	if decoded["TotalRequests"] != 4.0 || decoded["FailedReqs"] != 1.0 {
+		t.Errorf("Expected the compact JSON fields alongside the text, got %v", decoded)
+	}
+}
+
+func TestDescribeSample(t *testing.T) {
+	cases := []struct {
+		sample *RequestSample
+		want   string
+	}{
+		{nil, ""},
+		{&RequestSample{Method: "GET", URL: "http://test/a", StatusCode: 200}, " (GET http://test/a, status 200)"},
+		{&RequestSample{Method: "POST", URL: "http://test/b", ErrorType: errors.ErrorTypeTimeout}, " (POST http://test/b, Timeout)"},
+		{&RequestSample{Method: "GET", URL: "http://test/c", Step: "login", StatusCode: 302}, ` (step "login": GET http://test/c, status 302)`},
+	}
+	for _, c := range cases {
+		if got := describeSample(c.sample); got != c.want {
+			t.Errorf("describeSample(%+v) = %q, want %q", c.sample, got, c.want)
+		}
+	}
+}
+
+func TestWriteResponseTimes(t *testing.T) {
+	var buf bytes.Buffer
+	stats := LoadTestStats{ResponseTimes: []time.Duration{1500 * time.Microsecond, 2 * time.Second}}
+
+	if err := WriteResponseTimes(&buf, stats); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := "1500000\n2000000000\n"; buf.String() != want {
+		t.Errorf("Expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestWriteCompactJSON(t *testing.T) {
+	var buf bytes.Buffer
+	stats := LoadTestStats{
+		TotalRequests:   2,
+		FailedReqs:      1,
+		P99Time:         85 * time.Millisecond,
+		ErrorCodes:      map[string]int{"timeout": 1},
+		ResponseTimes:   []time.Duration{time.Millisecond, 85 * time.Millisecond},
+		TimeSeries:      []Window{{Requests: 2, Failures: 1}},
+		StatusBreakdown: map[int]int{},
+	}
+
+	if err := WriteCompactJSON(&buf, stats); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 || !strings.HasSuffix(line, "\n") {
+		t.Errorf("Expected exactly one line, got %q", line)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if decoded["TotalRequests"] != 2.0 || decoded["P99Time"] != float64(85*time.Millisecond) {
+		t.Errorf("Expected key metrics under their full JSON names, got %v", decoded)
+	}
+	for _, bulky := range []string{"ResponseTimes", "TimeSeries", "StatusBreakdown", "StopReason"} {
+		if _, ok := decoded[bulky]; ok {
+			t.Errorf("Expected %s to be omitted, got %v", bulky, decoded[bulky])
+		}
+	}
+}
+
+func TestTopErrorMessages(t *testing.T) {
+	stats := LoadTestStats{ErrorMessages: map[string]int{"b": 2, "a": 2, "c": 5, "d": 1}}
+
+	got := TopErrorMessages(stats, 3)
+
+	if len(got) != 3 || got[0] != "c" || got[1] != "a" || got[2] != "b" {
+		t.Errorf("Expected [c a b], got %v", got)
+	}
+}
+
+func TestCertExpiryWarning(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		expiry time.Time
+		window time.Duration
+		want   string
+	}{
+		{"plain HTTP", time.Time{}, 720 * time.Hour, ""},
+		{"far off", now.Add(90 * 24 * time.Hour), 720 * time.Hour, ""},
+		{"within window", now.Add(10*24*time.Hour + time.Hour), 720 * time.Hour, "Warning: TLS certificate expires in 10 days"},
+		{"expired", now.Add(-5 * time.Hour), 720 * time.Hour, "Warning: TLS certificate expired 5h ago"},
+		{"disabled", now.Add(time.Hour), 0, ""},
+	}
+	for _, c := range cases {
+		stats := LoadTestStats{CertExpiry: c.expiry, CertExpiryWarn: c.window}
+		if got := CertExpiryWarning(stats, now); got != c.want {
+			t.Errorf("%s: expected %q, got %q", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package stats
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Throughput summarizes the transfer rate of individual responses, their
+// size over the time until their body was read, in bytes per second.
+// Responses without a body are left out. Response times end with the
+// headers, so a large response with a fast response time but a low rate was
+// limited by the transfer rather than by how long the server took to start
+// answering.
+type Throughput struct {
+	Responses int
+	Min       float64
+	P5        float64 // the rate 95% of responses beat, the counterpart of a P95 latency
+	Median    float64
+	Average   float64
+	Max       float64
+}
+
+// throughputOf sorts rates in place and summarizes them.
+func throughputOf(rates []float64) Throughput {
+	if len(rates) == 0 {
+		return Throughput{}
+	}
+	slices.Sort(rates)
+	var total float64
+	for _, rate := range rates {
+		total += rate
+	}
+	return Throughput{
+		Responses: len(rates),
+		Min:       rates[0],
+		P5:        Percentile(rates, 5),
+		Median:    Percentile(rates, 50),
+		Average:   total / float64(len(rates)),
+		Max:       rates[len(rates)-1],
+	}
+}
+
+func printThroughput(t Throughput) {
+	fmt.Printf("\nThroughput per Request (%d responses with a body):\n", t.Responses)
+	fmt.Printf("  Min:              %s\n", formatRate(t.Min))
+	fmt.Printf("  5th percentile:   %s\n", formatRate(t.P5))
+	fmt.Printf("  Median (50th):    %s\n", formatRate(t.Median))
+	fmt.Printf("  Average:          %s\n", formatRate(t.Average))
+	fmt.Printf("  Max:              %s\n", formatRate(t.Max))
+}
+
+// formatRate renders a transfer rate, e.g. "12.50 MB/s".
+func formatRate(bytesPerSecond float64) string {
+	switch {
+	case bytesPerSecond >= 1<<20:
+		return fmt.Sprintf("%.2f MB/s", bytesPerSecond/(1<<20))
+	case bytesPerSecond >= 1<<10:
+		return fmt.Sprintf("%.2f KB/s", bytesPerSecond/(1<<10))
+	default:
+		return fmt.Sprintf("%.0f B/s", bytesPerSecond)
+	}
+}
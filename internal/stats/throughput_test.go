@@ -0,0 +1,51 @@
+package stats
+
+import (
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"testing"
+	"time"
+)
+
+func TestCollector_Throughput(t *testing.T) {
+	c := NewCollector(time.Now(), config.RequestConfig{})
+	// 1 MB in 1s, of which 900ms reading the body, 1 MB in 500ms and 512 KB
+	// in 100ms
+	transferBound := makeResult(true, 200, 100*time.Millisecond, errors.ErrorTypeNone, 1<<20)
+	transferBound.BodyTime = 900 * time.Millisecond
+	c.Add(transferBound)
+	c.Add(makeResult(true, 200, 500*time.Millisecond, errors.ErrorTypeNone, 1<<20))
+	c.Add(makeResult(false, 500, 100*time.Millisecond, errors.ErrorTypeServerError, 512<<10))
+	// No body, so no rate
+	c.Add(makeResult(true, 204, time.Millisecond, errors.ErrorTypeNone, 0))
+	c.Add(makeResult(false, 0, time.Second, errors.ErrorTypeTimeout, 0))
+
+	got := c.Snapshot().Throughput
+	const mb = 1 << 20
+	if got.Responses != 3 {
+		t.Fatalf("Expected 3 responses with a body, got %d", got.Responses)
+	}
+	if got.Min != mb || got.P5 != mb || got.Median != 2*mb || got.Max != 5*mb {
+		t.Errorf("Expected 1, 1, 2 and 5 MB/s, got %+v", got)
+	}
+	if got.Average != 8*mb/3.0 {
+		t.Errorf("Expected an average of 8/3 MB/s, got %v", got.Average)
+	}
+
+	if empty := NewCollector(time.Now(), config.RequestConfig{}).Snapshot().Throughput; empty != (Throughput{}) {
+		t.Errorf("Expected no throughput without responses, got %+v", empty)
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	cases := map[float64]string{
+		512:              "512 B/s",
+		1536:             "1.50 KB/s",
+		12.5 * (1 << 20): "12.50 MB/s",
+	}
+	for rate, want := range cases {
+		if got := formatRate(rate); got != want {
+			t.Errorf("formatRate(%v) = %q, want %q", rate, got, want)
+		}
+	}
+}
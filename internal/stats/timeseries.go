@@ -0,0 +1,31 @@
+package stats
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// WriteTimeSeriesCSV writes one CSV row per second of stats.TimeSeries, for
+// charting a run over time. Seconds without completed requests still get a
+// row, so the timeline has no gaps. Timestamps mark the start of each second,
+// counting back from end; latencies are in milliseconds.
+func WriteTimeSeriesCSV(w io.Writer, stats LoadTestStats, end time.Time) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"timestamp", "second", "requests", "errors", "rps", "rolling_p95_ms"})
+	start := end.Add(-stats.TestDuration)
+	for _, window := range stats.TimeSeries {
+		// A window spans one second, so its request count is its rate
+		cw.Write([]string{
+			start.Add(time.Duration(window.Second) * time.Second).UTC().Format(time.RFC3339),
+			strconv.Itoa(window.Second),
+			strconv.Itoa(window.Requests),
+			strconv.Itoa(window.Failures),
+			strconv.Itoa(window.Requests),
+			strconv.FormatFloat(millis(window.P95), 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
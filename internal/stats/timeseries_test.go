@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"loadtester/internal/client"
+	"loadtester/internal/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteTimeSeriesCSV(t *testing.T) {
+	s := LoadTestStats{
+		TestDuration: 3 * time.Second,
+		TimeSeries: []Window{
+			{Second: 0, Requests: 5, P95: 12 * time.Millisecond},
+			{Second: 1, P95: 12 * time.Millisecond},
+			{Second: 2, Requests: 4, Failures: 1, P95: 1500 * time.Microsecond},
+		},
+	}
+
+	var b strings.Builder
+	if err := WriteTimeSeriesCSV(&b, s, time.Unix(103, 0)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := `timestamp,second,requests,errors,rps,rolling_p95_ms
+1970-01-01T00:01:40Z,0,5,0,5,12
+1970-01-01T00:01:41Z,1,0,0,0,12
+1970-01-01T00:01:42Z,2,4,1,4,1.5
+`
+	if b.String() != want {
+		t.Errorf("Unexpected output:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestCollector_RollingP95(t *testing.T) {
+	c := NewCollector(time.Now(), config.RequestConfig{})
+	c.Add(client.TestResult{Success: true, ResponseTime: time.Second})
+	// Move the start back so the next result lands RollingSeconds+1 seconds in
+	c.testStart = time.Now().Add(-(RollingSeconds + 1) * time.Second)
+	c.Add(client.TestResult{Success: true, ResponseTime: time.Millisecond})
+
+	series := c.Snapshot().TimeSeries
+	if len(series) != RollingSeconds+2 {
+		t.Fatalf("Expected %d windows, got %d", RollingSeconds+2, len(series))
+	}
+	if series[1].P95 != time.Second {
+		t.Errorf("Expected an empty second to carry the rolling P95, got %v", series[1].P95)
+	}
+	if last := series[len(series)-1].P95; last != time.Millisecond {
+		t.Errorf("Expected results older than %d seconds to roll off, got %v", RollingSeconds, last)
+	}
+}
@@ -0,0 +1,46 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+)
+
+// VerifyPercentiles cross-checks the reported Median, P95 and P99 against the
+// raw ResponseTimes by brute force, counting the samples under and at each
+// value instead of indexing into the sorted samples as percentile does, and
+// describes each percentile that disagrees. No samples means nothing to check.
+func VerifyPercentiles(stats LoadTestStats) []string {
+	n := len(stats.ResponseTimes)
+	if n == 0 {
+		return nil
+	}
+	var mismatches []string
+	for _, check := range []struct {
+		name  string
+		p     int
+		value time.Duration
+	}{
+		{"Median", 50, stats.MedianTime},
+		{"P95", 95, stats.P95Time},
+		{"P99", 99, stats.P99Time},
+	} {
+		below, atOrBelow := 0, 0
+		for _, t := range stats.ResponseTimes {
+			if t < check.value {
+				below++
+			}
+			if t <= check.value {
+				atOrBelow++
+			}
+		}
+		// The value must be a sample with at most rank samples under it and
+		// more than rank at or under it
+		rank := min(n*check.p/100, n-1)
+		if below > rank || atOrBelow <= rank {
+			mismatches = append(mismatches, fmt.Sprintf(
+				"%s reported as %v, but %d of %d samples are under it and %d at or under it (expected the sample at rank %d)",
+				check.name, check.value, below, n, atOrBelow, rank+1))
+		}
+	}
+	return mismatches
+}
@@ -0,0 +1,37 @@
+package stats
+
+import (
+	"loadtester/internal/config"
+	"loadtester/internal/errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyPercentiles(t *testing.T) {
+	for _, n := range []int{1, 7, 100, 1001} {
+		c := NewCollector(time.Now(), config.RequestConfig{})
+		// Unsorted, with duplicates, so ranks and ties are both exercised
+		for i := 0; i < n; i++ {
+			c.Add(makeResult(true, 200, time.Duration((i*37)%(n/2+1))*time.Millisecond, errors.ErrorTypeNone, 0))
+		}
+		if mismatches := VerifyPercentiles(c.Snapshot()); len(mismatches) > 0 {
+			t.Errorf("%d samples: expected the collector's percentiles to verify, got %v", n, mismatches)
+		}
+	}
+
+	stats := LoadTestStats{
+		ResponseTimes: []time.Duration{10, 20, 30, 40},
+		MedianTime:    30,
+		P95Time:       35, // not a sample
+		P99Time:       30, // a sample, but the wrong one
+	}
+	mismatches := VerifyPercentiles(stats)
+	if len(mismatches) != 2 || !strings.HasPrefix(mismatches[0], "P95 reported as 35ns") || !strings.HasPrefix(mismatches[1], "P99 reported as 30ns") {
+		t.Errorf("Expected P95 and P99 to be reported, got %v", mismatches)
+	}
+
+	if mismatches := VerifyPercentiles(LoadTestStats{}); mismatches != nil {
+		t.Errorf("Expected nothing to verify without samples, got %v", mismatches)
+	}
+}
@@ -0,0 +1,85 @@
+// Package tmpl expands per-request placeholders in request values, such as
+// {{uuid}} for a fresh request ID or {{env "TOKEN"}} for a secret kept out of
+// the command line.
+package tmpl
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// funcs are the generators available inside {{ }}.
+var funcs = template.FuncMap{
+	"uuid":      newUUID,
+	"env":       env,
+	"timestamp": func() string { return strconv.FormatInt(time.Now().UnixMilli(), 10) },
+}
+
+// Funcs returns the placeholder functions, for templates rendered with data
+// of their own, such as request bodies filled in from CSV rows.
+func Funcs() template.FuncMap {
+	return funcs
+}
+
+// Template is a value with placeholders, expanded afresh on every call to
+// String. It is safe for concurrent use.
+type Template struct {
+	tmpl   *template.Template
+	static string // the value itself when it has no placeholders
+}
+
+// Parse compiles s. It is expanded once up front, so unknown functions and
+// unset environment variables are reported here rather than per request.
+func Parse(s string) (*Template, error) {
+	if !strings.Contains(s, "{{") {
+		return &Template{static: s}, nil
+	}
+	tmpl, err := template.New("").Funcs(funcs).Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+	t := &Template{tmpl: tmpl}
+	if _, err := t.expand(); err != nil {
+		return nil, fmt.Errorf("invalid template %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// String returns the expanded value.
+func (t *Template) String() string {
+	s, _ := t.expand() // Parse already proved the template expands
+	return s
+}
+
+func (t *Template) expand() (string, error) {
+	if t.tmpl == nil {
+		return t.static, nil
+	}
+	var b strings.Builder
+	err := t.tmpl.Execute(&b, nil)
+	return b.String(), err
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// env returns the value of an environment variable, failing when it is unset
+// so a missing secret doesn't silently send an empty credential.
+func env(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
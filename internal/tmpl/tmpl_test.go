@@ -0,0 +1,57 @@
+package tmpl
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestParse_Static(t *testing.T) {
+	tmpl, err := Parse("application/json")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tmpl.String(); got != "application/json" {
+		t.Errorf("Expected the value unchanged, got %q", got)
+	}
+}
+
+func TestParse_UUID(t *testing.T) {
+	tmpl, err := Parse("req-{{uuid}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	first, second := tmpl.String(), tmpl.String()
+	if !strings.HasPrefix(first, "req-") || !uuidPattern.MatchString(strings.TrimPrefix(first, "req-")) {
+		t.Errorf("Expected a v4 UUID, got %q", first)
+	}
+	if first == second {
+		t.Errorf("Expected a fresh UUID per expansion, got %q twice", first)
+	}
+}
+
+func TestParse_Env(t *testing.T) {
+	t.Setenv("LOADTESTER_TOKEN", "s3cret")
+	tmpl, err := Parse(`Bearer {{env "LOADTESTER_TOKEN"}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := tmpl.String(); got != "Bearer s3cret" {
+		t.Errorf("Expected the token from the environment, got %q", got)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"unknown function": "{{nonce}}",
+		"unclosed":         "{{uuid",
+		"unset variable":   `{{env "LOADTESTER_SURELY_UNSET"}}`,
+	}
+	for name, s := range cases {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}